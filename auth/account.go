@@ -0,0 +1,776 @@
+package auth
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"Forum/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ID of the placeholder account that receives anonymized content
+const DeletedUserID = "deleted-user"
+
+// Default delay before a requested account deletion is executed
+const defaultDeletionGrace = 7 * 24 * time.Hour
+
+// Function to return the grace period, configurable with ACCOUNT_DELETION_GRACE_HOURS
+func deletionGrace() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_HOURS"))
+	if err != nil || hours < 0 {
+		return defaultDeletionGrace
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Function to create the tables used by account deletion
+func initAccountTables() {
+	DB.Exec(`CREATE TABLE IF NOT EXISTS account_deletions (
+	user_id      TEXT PRIMARY KEY,
+	mode         TEXT CHECK(mode IN ('anonymize', 'delete')) NOT NULL,
+	requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	scheduled_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	// Placeholder account shown as the author of anonymized content
+	DB.Exec("INSERT OR IGNORE INTO users (id, email, username, role) VALUES (?, ?, ?, 'guest')", DeletedUserID, "deleted@forum.local", "deleted user")
+}
+
+// Structures written in the export archive
+type ExportProfile struct {
+	ID         string `json:"id"`
+	Email      string `json:"email"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	Bio        string `json:"bio"`
+	AvatarPath string `json:"avatar_path"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ExportPost struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	CreatedAt   string   `json:"created_at"`
+	Attachments []string `json:"attachments"`
+	Tags        []string `json:"tags"`
+}
+
+type ExportComment struct {
+	ID          string   `json:"id"`
+	PostID      string   `json:"post_id"`
+	Content     string   `json:"content"`
+	CreatedAt   string   `json:"created_at"`
+	Attachments []string `json:"attachments"`
+}
+
+type ExportMessage struct {
+	ID             string   `json:"id"`
+	ConversationID string   `json:"conversation_id"`
+	Content        string   `json:"content"`
+	CreatedAt      string   `json:"created_at"`
+	Attachments    []string `json:"attachments"`
+}
+
+type ExportReaction struct {
+	ID        string `json:"id"`
+	PostID    string `json:"post_id,omitempty"`
+	CommentID string `json:"comment_id,omitempty"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ExportPollVote struct {
+	PostID    string `json:"post_id"`
+	Question  string `json:"question"`
+	Option    string `json:"option"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ExportBookmark struct {
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Collection string `json:"collection,omitempty"`
+	Note       string `json:"note,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ExportNotification struct {
+	ID         string `json:"id"`
+	PostID     string `json:"post_id"`
+	Action     string `json:"action"`
+	Content    string `json:"content"`
+	CreatedAt  string `json:"created_at"`
+	Seen       bool   `json:"seen"`
+	Count      int    `json:"count"`
+	ActorID    string `json:"actor_id"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Template   string `json:"template"`
+}
+
+type ExportSubscription struct {
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ExportNotificationPreference struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+type ExportNotificationSettings struct {
+	Digest      string                         `json:"digest"`
+	Preferences []ExportNotificationPreference `json:"preferences"`
+}
+
+// Function to write a value as an indented JSON file in the archive
+func writeZipJSON(zw *zip.Writer, name string, value any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+// Function to copy an uploaded file in the archive
+func writeZipFile(zw *zip.Writer, name, path string) error {
+	src, _, err := storage.Blobs.Get(storage.KeyFromPath(path))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	return err
+}
+
+// Function to load the posts of a user with their images
+func exportPosts(userID string) ([]ExportPost, error) {
+	rows, err := DB.Query("SELECT id, title, content, created_at FROM posts WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []ExportPost{}
+	for rows.Next() {
+		var post ExportPost
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Attach the file paths and the tags of each post
+	for i := range posts {
+		if posts[i].Attachments, err = exportAttachments("post_id", posts[i].ID); err != nil {
+			return nil, err
+		}
+		if posts[i].Tags, err = exportPostTags(posts[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return posts, nil
+}
+
+// Function to load the names of the tags of a post
+func exportPostTags(postID string) ([]string, error) {
+	rows, err := DB.Query("SELECT t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = ? ORDER BY t.name", postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// Function to load the comments written by a user
+func exportComments(userID string) ([]ExportComment, error) {
+	rows, err := DB.Query("SELECT id, post_id, content, created_at FROM comments WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ExportComment{}
+	for rows.Next() {
+		var comment ExportComment
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i := range comments {
+		if comments[i].Attachments, err = exportAttachments("comment_id", comments[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return comments, nil
+}
+
+// Function to load the private messages sent by a user
+func exportMessages(userID string) ([]ExportMessage, error) {
+	rows, err := DB.Query("SELECT id, conversation_id, content, created_at FROM messages WHERE sender_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []ExportMessage{}
+	for rows.Next() {
+		var message ExportMessage
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.Content, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i := range messages {
+		if messages[i].Attachments, err = exportAttachments("message_id", messages[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// Function to load the attachment paths of a post, a comment or a message ("post_id", "comment_id" or "message_id")
+func exportAttachments(column, id string) ([]string, error) {
+	rows, err := DB.Query("SELECT file_path FROM attachments WHERE "+column+" = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// Function to load the likes and dislikes given by a user
+func exportReactions(userID string) ([]ExportReaction, error) {
+	rows, err := DB.Query("SELECT id, COALESCE(post_id, ''), COALESCE(comment_id, ''), type, created_at FROM likes WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := []ExportReaction{}
+	for rows.Next() {
+		var reaction ExportReaction
+		if err := rows.Scan(&reaction.ID, &reaction.PostID, &reaction.CommentID, &reaction.Type, &reaction.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
+
+// Function to load the choices of a user in the polls
+func exportPollVotes(userID string) ([]ExportPollVote, error) {
+	rows, err := DB.Query(`SELECT v.post_id, COALESCE(p.question, ''), COALESCE(o.label, ''), b.created_at FROM poll_votes v
+		JOIN poll_ballots b ON b.post_id = v.post_id AND b.user_id = v.user_id
+		LEFT JOIN polls p ON p.post_id = v.post_id
+		LEFT JOIN poll_options o ON o.id = v.option_id
+		WHERE v.user_id = ? ORDER BY b.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := []ExportPollVote{}
+	for rows.Next() {
+		var vote ExportPollVote
+		if err := rows.Scan(&vote.PostID, &vote.Question, &vote.Option, &vote.CreatedAt); err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
+}
+
+// Function to load the bookmarks of a user with the name of their collection
+func exportBookmarks(userID string) ([]ExportBookmark, error) {
+	rows, err := DB.Query(`SELECT b.target_type, b.target_id, COALESCE(bc.name, ''), b.note, b.created_at FROM bookmarks b
+		LEFT JOIN bookmark_collections bc ON bc.id = b.collection_id
+		WHERE b.user_id = ? ORDER BY b.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []ExportBookmark{}
+	for rows.Next() {
+		var bookmark ExportBookmark
+		if err := rows.Scan(&bookmark.TargetType, &bookmark.TargetID, &bookmark.Collection, &bookmark.Note, &bookmark.CreatedAt); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, rows.Err()
+}
+
+// Function to load the notifications received by a user
+func exportNotifications(userID string) ([]ExportNotification, error) {
+	rows, err := DB.Query("SELECT id, COALESCE(post_id, ''), action, content, created_at, seen, count, COALESCE(actor_id, ''), COALESCE(target_type, ''), COALESCE(target_id, ''), COALESCE(template, '') FROM notifications WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []ExportNotification{}
+	for rows.Next() {
+		var notif ExportNotification
+		if err := rows.Scan(&notif.ID, &notif.PostID, &notif.Action, &notif.Content, &notif.CreatedAt, &notif.Seen, &notif.Count, &notif.ActorID, &notif.TargetType, &notif.TargetID, &notif.Template); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notif)
+	}
+	return notifications, rows.Err()
+}
+
+// Function to load the notification preferences of a user, only those changed from the defaults
+func exportNotificationSettings(userID string) (ExportNotificationSettings, error) {
+	settings := ExportNotificationSettings{Digest: "daily", Preferences: []ExportNotificationPreference{}}
+	DB.QueryRow("SELECT digest FROM notification_settings WHERE user_id = ?", userID).Scan(&settings.Digest)
+	rows, err := DB.Query("SELECT type, channel, enabled FROM notification_preferences WHERE user_id = ? ORDER BY type, channel", userID)
+	if err != nil {
+		return settings, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pref ExportNotificationPreference
+		if err := rows.Scan(&pref.Type, &pref.Channel, &pref.Enabled); err != nil {
+			return settings, err
+		}
+		settings.Preferences = append(settings.Preferences, pref)
+	}
+	return settings, rows.Err()
+}
+
+// Function to load the posts, categories and users followed by a user
+func exportSubscriptions(userID string) ([]ExportSubscription, error) {
+	rows, err := DB.Query("SELECT target_type, target_id, created_at FROM subscriptions WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []ExportSubscription{}
+	for rows.Next() {
+		var sub ExportSubscription
+		if err := rows.Scan(&sub.TargetType, &sub.TargetID, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions, rows.Err()
+}
+
+// Function to download all the data of the connected user as a ZIP archive
+func ExportAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode de requête non valide", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Utilisateur non authentifié", http.StatusUnauthorized)
+		return
+	}
+	// Load every part of the export before writing the response
+	var profile ExportProfile
+	err = DB.QueryRow("SELECT id, email, username, COALESCE(role, 'user'), COALESCE(bio, ''), COALESCE(avatar_path, ''), created_at FROM users WHERE id = ?", userID).Scan(&profile.ID, &profile.Email, &profile.Username, &profile.Role, &profile.Bio, &profile.AvatarPath, &profile.CreatedAt)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du profil", http.StatusInternalServerError)
+		return
+	}
+	posts, err := exportPosts(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des posts", http.StatusInternalServerError)
+		return
+	}
+	comments, err := exportComments(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des commentaires", http.StatusInternalServerError)
+		return
+	}
+	messages, err := exportMessages(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des messages", http.StatusInternalServerError)
+		return
+	}
+	reactions, err := exportReactions(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des likes", http.StatusInternalServerError)
+		return
+	}
+	pollVotes, err := exportPollVotes(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des votes", http.StatusInternalServerError)
+		return
+	}
+	bookmarks, err := exportBookmarks(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des favoris", http.StatusInternalServerError)
+		return
+	}
+	notifications, err := exportNotifications(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des notifications", http.StatusInternalServerError)
+		return
+	}
+	subscriptions, err := exportSubscriptions(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des abonnements", http.StatusInternalServerError)
+		return
+	}
+	notificationSettings, err := exportNotificationSettings(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des préférences de notification", http.StatusInternalServerError)
+		return
+	}
+
+	// Stream the archive to the client
+	filename := fmt.Sprintf("forum-export-%s.zip", time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"messages.json", messages},
+		{"reactions.json", reactions},
+		{"poll_votes.json", pollVotes},
+		{"bookmarks.json", bookmarks},
+		{"notifications.json", notifications},
+		{"notification_preferences.json", notificationSettings},
+		{"subscriptions.json", subscriptions},
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.value); err != nil {
+			log.Println("Erreur lors de l'export:", err)
+			return
+		}
+	}
+	// Add the uploaded images, a missing file must not break the export
+	if profile.AvatarPath != "" {
+		if err := writeZipFile(zw, "avatar/"+filepath.Base(profile.AvatarPath), profile.AvatarPath); err != nil {
+			log.Println("Avatar ignoré dans l'export:", profile.AvatarPath, err)
+		}
+	}
+	var attachments []string
+	for _, post := range posts {
+		attachments = append(attachments, post.Attachments...)
+	}
+	for _, comment := range comments {
+		attachments = append(attachments, comment.Attachments...)
+	}
+	for _, message := range messages {
+		attachments = append(attachments, message.Attachments...)
+	}
+	for _, path := range attachments {
+		if err := writeZipFile(zw, "attachments/"+filepath.Base(path), path); err != nil {
+			log.Println("Pièce jointe ignorée dans l'export:", path, err)
+		}
+	}
+}
+
+// Function to show, schedule or update the deletion of the connected account
+func RequestAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Utilisateur non authentifié", http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet {
+		// Return the pending deletion if there is one
+		var mode string
+		var scheduledAt time.Time
+		err = DB.QueryRow("SELECT mode, scheduled_at FROM account_deletions WHERE user_id = ?", userID).Scan(&mode, &scheduledAt)
+		w.Header().Set("Content-Type", "application/json")
+		if err == sql.ErrNoRows {
+			json.NewEncoder(w).Encode(map[string]any{"pending": false})
+			return
+		} else if err != nil {
+			http.Error(w, "Erreur de base de données", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"pending": true, "mode": mode, "scheduled_at": scheduledAt})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode de requête non valide", http.StatusMethodNotAllowed)
+		return
+	}
+	if userID == DeletedUserID {
+		http.Error(w, "Accès interdit", http.StatusForbidden)
+		return
+	}
+	// Anonymize keeps the posts and comments under "deleted user", delete removes them
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = "anonymize"
+	}
+	if mode != "anonymize" && mode != "delete" {
+		http.Error(w, "Mode de suppression invalide", http.StatusBadRequest)
+		return
+	}
+	// Accounts with a password must confirm it, OAuth accounts have none
+	var storedPassword sql.NullString
+	err = DB.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&storedPassword)
+	if err != nil {
+		http.Error(w, "Erreur de base de données", http.StatusInternalServerError)
+		return
+	}
+	if storedPassword.Valid && storedPassword.String != "" {
+		if bcrypt.CompareHashAndPassword([]byte(storedPassword.String), []byte(r.FormValue("password"))) != nil {
+			http.Error(w, "Mot de passe incorrect", http.StatusUnauthorized)
+			return
+		}
+	}
+	// Stored in UTC, the text comparison of the worker would break on a change of offset
+	scheduledAt := time.Now().UTC().Add(deletionGrace())
+	_, err = DB.Exec(`INSERT INTO account_deletions (user_id, mode, requested_at, scheduled_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET mode = excluded.mode`, userID, mode, time.Now(), scheduledAt)
+	if err != nil {
+		http.Error(w, "Erreur lors de la demande de suppression", http.StatusInternalServerError)
+		return
+	}
+	// Read back the date in case a deletion was already scheduled
+	DB.QueryRow("SELECT scheduled_at FROM account_deletions WHERE user_id = ?", userID).Scan(&scheduledAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":      "Suppression du compte programmée",
+		"mode":         mode,
+		"scheduled_at": scheduledAt,
+	})
+}
+
+// Function to cancel a pending deletion during the grace period
+func CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode de requête non valide", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Utilisateur non authentifié", http.StatusUnauthorized)
+		return
+	}
+	result, err := DB.Exec("DELETE FROM account_deletions WHERE user_id = ?", userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de l'annulation", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Aucune suppression en attente", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Suppression du compte annulée"})
+}
+
+// Conversations of the user ?1 that have no other member
+const soleConversations = `(SELECT cm.conversation_id FROM conversation_members cm WHERE cm.user_id = ?1
+	AND NOT EXISTS (SELECT 1 FROM conversation_members o WHERE o.conversation_id = cm.conversation_id AND o.user_id != ?1))`
+
+// Statements removing the data that belongs to the user in both modes
+var personalDataQueries = []string{
+	// The conversations where nobody else is left are deleted with their messages
+	"DELETE FROM attachments WHERE message_id IN (SELECT id FROM messages WHERE conversation_id IN " + soleConversations + ")",
+	"DELETE FROM message_reports WHERE message_id IN (SELECT id FROM messages WHERE conversation_id IN " + soleConversations + ")",
+	"DELETE FROM messages WHERE conversation_id IN " + soleConversations,
+	"DELETE FROM conversations WHERE id IN " + soleConversations,
+	"DELETE FROM conversation_members WHERE user_id = ?",
+	"DELETE FROM message_reports WHERE reporter_id = ?",
+	"DELETE FROM blocks WHERE user_id = ?1 OR blocked_id = ?1",
+	"DELETE FROM likes WHERE user_id = ?",
+	"DELETE FROM poll_votes WHERE user_id = ?",
+	"DELETE FROM poll_ballots WHERE user_id = ?",
+	"DELETE FROM drafts WHERE user_id = ?",
+	"DELETE FROM reputation_events WHERE user_id = ?",
+	"DELETE FROM user_badges WHERE user_id = ?",
+	"DELETE FROM bookmarks WHERE user_id = ?",
+	"DELETE FROM bookmark_collections WHERE user_id = ?",
+	"DELETE FROM post_reads WHERE user_id = ?",
+	"DELETE FROM notification_actors WHERE notification_id IN (SELECT id FROM notifications WHERE user_id = ?)",
+	"DELETE FROM notifications WHERE user_id = ?",
+	"DELETE FROM notification_actors WHERE user_id = ?",
+	"UPDATE notifications SET actor_id = NULL WHERE actor_id = ?",
+	"DELETE FROM notification_preferences WHERE user_id = ?",
+	"DELETE FROM notification_settings WHERE user_id = ?",
+	"DELETE FROM mentions WHERE user_id = ?",
+	"DELETE FROM subscriptions WHERE user_id = ?1 OR (target_type = 'user' AND target_id = ?1)",
+	"DELETE FROM sessions WHERE user_id = ?",
+	"DELETE FROM promotion_requests WHERE user_id = ?",
+	"DELETE FROM rate_limit WHERE user_id = ?",
+	"DELETE FROM account_deletions WHERE user_id = ?",
+	"DELETE FROM login_failures WHERE account = (SELECT lower(email) FROM users WHERE id = ?)",
+}
+
+// Statements removing the content written by the user in delete mode
+var contentQueries = []string{
+	"DELETE FROM likes WHERE comment_id IN (SELECT id FROM comments WHERE user_id = ? OR post_id IN (SELECT id FROM posts WHERE user_id = ?))",
+	"DELETE FROM likes WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM notifications WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM reports WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM post_categories WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM post_tags WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM post_pins WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM post_reads WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM poll_votes WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM poll_ballots WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM poll_options WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM polls WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM post_images WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM attachments WHERE user_id = ? OR post_id IN (SELECT id FROM posts WHERE user_id = ?) OR comment_id IN (SELECT id FROM comments WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?))",
+	"DELETE FROM mentions WHERE author_id = ? OR post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM subscriptions WHERE target_type = 'post' AND target_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM bookmarks WHERE (target_type = 'post' AND target_id IN (SELECT id FROM posts WHERE user_id = ?)) OR (target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE user_id = ? OR post_id IN (SELECT id FROM posts WHERE user_id = ?)))",
+	"UPDATE posts SET accepted_comment_id = NULL, accepted_at = NULL WHERE accepted_comment_id IN (SELECT id FROM comments WHERE user_id = ?)",
+	"DELETE FROM comments WHERE user_id = ? OR post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+	"DELETE FROM message_reports WHERE message_id IN (SELECT id FROM messages WHERE sender_id = ?)",
+	"DELETE FROM messages WHERE sender_id = ?",
+	"DELETE FROM posts WHERE user_id = ?",
+}
+
+// Statements moving the content written by the user to the placeholder account
+var anonymizeQueries = []string{
+	"UPDATE posts SET user_id = ? WHERE user_id = ?",
+	"UPDATE comments SET user_id = ? WHERE user_id = ?",
+	"UPDATE attachments SET user_id = ? WHERE user_id = ?",
+	"UPDATE mentions SET author_id = ? WHERE author_id = ?",
+	"UPDATE messages SET sender_id = ? WHERE sender_id = ?",
+}
+
+// Function to fill every placeholder of a query with the same value
+func repeatArg(query, value string) []any {
+	args := []any{}
+	for _, c := range query {
+		if c == '?' {
+			args = append(args, value)
+		}
+	}
+	return args
+}
+
+// Function to delete or anonymize an account
+func DeleteAccount(userID, mode string) error {
+	if userID == DeletedUserID {
+		return fmt.Errorf("the placeholder account cannot be deleted")
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if mode == "delete" {
+		for _, query := range contentQueries {
+			if _, err := tx.Exec(query, repeatArg(query, userID)...); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	} else {
+		for _, query := range anonymizeQueries {
+			if _, err := tx.Exec(query, DeletedUserID, userID); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	for _, query := range personalDataQueries {
+		if _, err := tx.Exec(query, userID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// The uploaded files are removed by the blob garbage collector once nothing references them
+	return tx.Commit()
+}
+
+// Function to execute the deletions whose grace period is over
+func processAccountDeletions() {
+	rows, err := DB.Query("SELECT user_id, mode FROM account_deletions WHERE scheduled_at <= ?", time.Now().UTC())
+	if err != nil {
+		log.Println("Erreur lors de la lecture des suppressions:", err)
+		return
+	}
+	type deletion struct{ userID, mode string }
+	var due []deletion
+	for rows.Next() {
+		var d deletion
+		if rows.Scan(&d.userID, &d.mode) == nil {
+			due = append(due, d)
+		}
+	}
+	rows.Close()
+
+	for _, d := range due {
+		if err := DeleteAccount(d.userID, d.mode); err != nil {
+			log.Println("Erreur lors de la suppression du compte", d.userID, ":", err)
+			continue
+		}
+		log.Println("Compte supprimé:", d.userID, "mode:", d.mode)
+	}
+}
+
+// Function to start the background worker for scheduled account deletions
+func StartAccountDeletionWorker(interval time.Duration) {
+	go func() {
+		for {
+			processAccountDeletions()
+			time.Sleep(interval)
+		}
+	}()
+}
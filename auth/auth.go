@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"time"
@@ -96,6 +97,12 @@ func InitDB() {
     created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
     `)
+	// Public profile fields, ignored if the columns already exist
+	DB.Exec("ALTER TABLE users ADD COLUMN bio TEXT DEFAULT ''")
+	DB.Exec("ALTER TABLE users ADD COLUMN avatar_path TEXT DEFAULT ''")
+	initAccountTables()
+	initFeatureTables()
+	loginLimiter = security.NewLoginLimiter(DB, security.DefaultLoginPolicy())
 }
 
 
@@ -118,6 +125,10 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	username := r.FormValue("username")
 	password := r.FormValue("password")
+	if isReservedUsername(username) {
+		http.Error(w, "Username reserved", http.StatusBadRequest)
+		return
+	}
 
 	var exists string
 	// Check if the email already exists in the database
@@ -143,53 +154,68 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-// loginLimiter for rate limit in login system
-var loginLimiter = security.NewLoginLimiter()
-
 // Function to handles user login
 func LoginUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		http.ServeFile(w, r, "web/html/login.html")
 		return
 	}
-	// Check if the IP is blocked due to too many failed login attempts
-	ip := r.RemoteAddr
-	if blocked, remaining := loginLimiter.CheckLock(ip); blocked {
-		http.Error(w, fmt.Sprintf("Trop de tentatives. Réessayez dans %v secondes.", int(remaining.Seconds())), http.StatusTooManyRequests)
-		return
-	}
 	// Retrieve form data for login
+	ip := ClientIP(r)
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
+	// A solved CAPTCHA lifts the delay of the account, not the one of the IP
+	captchaSolved := false
+	if LoginCaptcha != nil {
+		if response := security.CaptchaResponse(r); response != "" {
+			ok, err := LoginCaptcha.Verify(response, ip)
+			if err != nil {
+				log.Println("Error verifying CAPTCHA:", err)
+			}
+			captchaSolved = ok
+		}
+	}
+	// Refuse the attempt while the account or the IP is delayed by its previous failures
+	check, err := loginLimiter.Check(ip, email, captchaSolved)
+	if err != nil {
+		http.Error(w, "Erreur serveur", http.StatusInternalServerError)
+		return
+	}
+	if check.Stuffing {
+		log.Println("Credential stuffing suspected from", ip)
+	}
+	if check.RetryAfter > 0 {
+		loginError(w, r, "toomany", fmt.Sprintf("Trop de tentatives. Réessayez dans %v secondes.", int(check.RetryAfter.Seconds())+1), http.StatusTooManyRequests, check.RetryAfter)
+		return
+	}
+	if check.CaptchaRequired && LoginCaptcha != nil && !captchaSolved {
+		if err := loginLimiter.Release(check.Attempt); err != nil {
+			log.Println("Error releasing login attempt:", err)
+		}
+		loginError(w, r, "captcha", "Veuillez résoudre le CAPTCHA.", http.StatusUnauthorized, 0)
+		return
+	}
 	// Query the database to get the user’s information
 	var userID, storedPassword string
-	err := DB.QueryRow("SELECT id, password FROM users WHERE email = ?", email).Scan(&userID, &storedPassword)
-	// If there’s an error, simulate a delay to prevent brute force attacks
+	err = DB.QueryRow("SELECT id, password FROM users WHERE email = ?", email).Scan(&userID, &storedPassword)
 	if err != nil {
-		time.Sleep(4 * time.Second)
-		timeout := loginLimiter.FailedAttempt(ip)
-		if timeout > 0 {
-			http.Error(w, fmt.Sprintf("Trop de tentatives. Réessayez dans %v secondes.", int(timeout.Seconds())), http.StatusTooManyRequests)
-		} else {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-		}
+		// Compare with a dummy hash so an unknown email answers as slowly as a wrong password
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		recordLoginFailure(check.Attempt, ip, email, "")
+		loginError(w, r, "invalid", "Invalid credentials", http.StatusUnauthorized, 0)
 		return
 	}
 	// Compare the provided password with the stored password
 	if err = bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)); err != nil {
-		time.Sleep(4 * time.Second)
-		timeout := loginLimiter.FailedAttempt(ip)
-		// If the password is incorrect, simulate a delay and handle the login rate limit
-		if timeout > 0 {
-			http.Error(w, fmt.Sprintf("Trop de tentatives. Réessayez dans %v secondes.", int(timeout.Seconds())), http.StatusTooManyRequests)
-		} else {
-			http.Error(w, "Invalid Password", http.StatusUnauthorized)
-		}
+		recordLoginFailure(check.Attempt, ip, email, userID)
+		loginError(w, r, "invalid", "Invalid Password", http.StatusUnauthorized, 0)
 		return
 	}
-	// Reset the login attempt counter for the user
-	loginLimiter.Reset(ip)
+	// Forget the failures of the account
+	if err := loginLimiter.Success(ip, email); err != nil {
+		log.Println("Error resetting login failures:", err)
+	}
 	// Remove any rate limit data associated with the user
 	DB.Exec("DELETE FROM rate_limit WHERE user_id = ?", userID)
 
@@ -260,6 +286,25 @@ func GetUserFromSessionRole(r *http.Request) (string, string, error) {
     return "", "", fmt.Errorf("No valid session found")
 }
 
+// Function to identify the user of a request from a session stored in the database only,
+// used where a forged cookie must not give a new identity (rate limiting)
+func SessionIdentity(r *http.Request) (string, string) {
+	cookie, err := r.Cookie("session_token")
+	if err != nil {
+		return "", ""
+	}
+	var userID, role string
+	err = DB.QueryRow(`
+		SELECT users.id, COALESCE(users.role, 'user')
+		FROM users
+		JOIN sessions ON users.id = sessions.user_id
+		WHERE sessions.id = ? AND sessions.expires_at > ?`, cookie.Value, time.Now()).Scan(&userID, &role)
+	if err != nil {
+		return "", ""
+	}
+	return userID, role
+}
+
 // Function to deletes expired sessions from the database
 func CleanupExpiredSessions() {
 	DB.Exec("DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP")
@@ -380,28 +425,38 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Utilisateur non authentifié", http.StatusUnauthorized)
         return
     }
-    var activity Activity
-
 	// Check if the database is initialized
 	if DB == nil {
         http.Error(w, "Erreur interne : base de données non initialisée", http.StatusInternalServerError)
         return
     }
-    // Fetch posts created by the user
-    rows, err := DB.Query("SELECT id, title, content, created_at FROM posts WHERE user_id = ?", userID)
+    activity, err := LoadUserActivity(userID, -1)
     if err != nil {
-        http.Error(w, "Erreur lors de la récupération des posts", http.StatusInternalServerError)
+        http.Error(w, "Erreur lors de la récupération de l'activité", http.StatusInternalServerError)
         return
     }
+	 // Set the response header in JSON
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(activity)
+}
 
+// Function to load the posts, likes and comments of a user, most recent first, at most
+// limit of each kind. SQLite reads a negative limit as no limit.
+func LoadUserActivity(userID string, limit int) (Activity, error) {
+    var activity Activity
+
+    // Fetch posts created by the user
+    rows, err := DB.Query("SELECT id, title, content, created_at FROM posts WHERE user_id = ? AND deleted_at IS NULL AND scheduled_at IS NULL ORDER BY created_at DESC LIMIT ?", userID, limit)
+    if err != nil {
+        return activity, err
+    }
     defer rows.Close()
 
 	// Loop through the fetched posts
     for rows.Next() {
         var post Post
         if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CreatedAt); err != nil {
-			http.Error(w, "Erreur lors de la lecture des posts", http.StatusInternalServerError)
-			return
+			return activity, err
 		}
         activity.Posts = append(activity.Posts, post)
     }
@@ -409,11 +464,12 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
     rows, err = DB.Query(`
         SELECT p.id, p.title, l.type
         FROM likes l
-        JOIN posts p ON l.post_id = p.id
-        WHERE l.user_id = ?`, userID)
+        JOIN posts p ON l.post_id = p.id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL
+        WHERE l.user_id = ?
+        ORDER BY l.created_at DESC
+        LIMIT ?`, userID, limit)
     if err != nil {
-        http.Error(w, "Erreur lors de la récupération des likes", http.StatusInternalServerError)
-        return
+        return activity, err
     }
     defer rows.Close()
 
@@ -421,8 +477,7 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
     for rows.Next() {
         var like LikeInfo
         if err := rows.Scan(&like.PostID, &like.Title, &like.Type); err != nil {
-            http.Error(w, "Erreur lors de la lecture des likes", http.StatusInternalServerError)
-            return
+            return activity, err
         }
         activity.Likes = append(activity.Likes, like)
     }
@@ -430,11 +485,12 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
     rows, err = DB.Query(`
         SELECT c.post_id, p.title, c.content, c.created_at
         FROM comments c
-        JOIN posts p ON c.post_id = p.id
-        WHERE c.user_id = ?`, userID)
+        JOIN posts p ON c.post_id = p.id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL
+        WHERE c.user_id = ?
+        ORDER BY c.created_at DESC
+        LIMIT ?`, userID, limit)
     if err != nil {
-        http.Error(w, "Erreur lors de la récupération des commentaires", http.StatusInternalServerError)
-        return
+        return activity, err
     }
     defer rows.Close()
 
@@ -442,8 +498,7 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
     for rows.Next() {
         var comment CommentInfo
         if err := rows.Scan(&comment.PostID, &comment.Title, &comment.Comment, &comment.CreatedAt); err != nil {
-            http.Error(w, "Erreur lors de la lecture des commentaires", http.StatusInternalServerError)
-            return
+            return activity, err
         }
         activity.Comments = append(activity.Comments, comment)
     }
@@ -452,27 +507,39 @@ func GetUserActivity(w http.ResponseWriter, r *http.Request) {
     SELECT c.id, c.content, p.title, l.type
     FROM likes l
     JOIN comments c ON l.comment_id = c.id
-    JOIN posts p ON c.post_id = p.id
-    WHERE l.user_id = ? AND l.comment_id IS NOT NULL`, userID)
+    JOIN posts p ON c.post_id = p.id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL
+    WHERE l.user_id = ? AND l.comment_id IS NOT NULL
+    ORDER BY l.created_at DESC
+    LIMIT ?`, userID, limit)
     if err != nil {
-    http.Error(w, "Erreur lors de la récupération des likes des commentaires", http.StatusInternalServerError)
-    return
+        return activity, err
     }
-
     defer rows.Close()
     
     // // Loop through the fetched likes/dislike comments
     for rows.Next() {
     var commentLike CommentLikeInfo
     if err := rows.Scan(&commentLike.CommentID, &commentLike.Comment, &commentLike.PostTitle, &commentLike.Type); err != nil {
-        http.Error(w, "Erreur lors de la lecture des likes des commentaires", http.StatusInternalServerError)
-        return
+        return activity, err
     }
     activity.CommentLikes = append(activity.CommentLikes, commentLike)
     }
-	 // Set the response header in JSON
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(activity)
+    return activity, nil
+}
+
+// Set the role hierarchie
+var roleHierarchy = map[string]int{
+    "guest":     0,
+    "user":      1,
+    "moderator": 2,
+    "admin":     3,
+}
+
+// Function to check whether a role is known and reaches the required one
+func HasRole(role, requiredRole string) bool {
+    level, ok := roleHierarchy[role]
+    requiredLevel, requiredOk := roleHierarchy[requiredRole]
+    return ok && requiredOk && level >= requiredLevel
 }
 
 // Function to check if the user has the correct role to connect
@@ -486,13 +553,6 @@ func RoleMiddleware(requiredRole string, next http.HandlerFunc) http.HandlerFunc
         }
         fmt.Println("👤 Utilisateur:", userID, "| Rôle:", userRole, "| Rôle requis:", requiredRole)
 
-        // Set the role hierarchie
-        roleHierarchy := map[string]int{
-            "guest":     0,
-            "user":      1,
-            "moderator": 2,
-            "admin":     3,
-        }
         userLevel, userExists := roleHierarchy[userRole]
         requiredLevel, requiredExists := roleHierarchy[requiredRole]
 
@@ -5,13 +5,12 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"strings"
 	"time"
 	"html/template"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var tmpl = template.Must(template.ParseFiles("web/html/edit_user.html"))
-
 // Function to handles editing user information
 func EditUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
@@ -37,6 +36,13 @@ func EditUser(w http.ResponseWriter, r *http.Request) {
 			UserID: userID,
 			Role:   role,
 		}
+		// Loaded on request like the forum page, so that the package loads outside the project root
+		tmpl, err := template.ParseFiles("web/html/edit_user.html")
+		if err != nil {
+			log.Println("Erreur lors du chargement du template:", err)
+			http.Error(w, "Erreur interne du serveur", http.StatusInternalServerError)
+			return
+		}
 		// Served the template with the role and email
 		err = tmpl.Execute(w, tmplData)
 		if err != nil {
@@ -61,6 +67,10 @@ func EditUser(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
+	if isReservedUsername(username) {
+		http.Error(w, "Ce pseudo est réservé", http.StatusBadRequest)
+		return
+	}
 	// Validate the email of the user
 	if !isValidEmail(email) {
 		http.Error(w, "Format d'email invalide", http.StatusBadRequest)
@@ -136,6 +146,14 @@ func updateSession(w http.ResponseWriter, r *http.Request, userID, email string)
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
+// Names of the routes under /user/, a user with one of them would have no profile page
+var reservedUsernames = map[string]bool{"activity": true}
+
+// Function to check whether a username is reserved for a route
+func isReservedUsername(username string) bool {
+	return reservedUsernames[strings.ToLower(strings.TrimSpace(username))]
+}
+
 //Function to validates whether the provided email has a valid format
 func isValidEmail(email string) bool {
 	// Using regex for matching a valid email format
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"Forum/security"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Failed logins tracked per account and per IP, created by InitDB
+var loginLimiter *security.LoginLimiter
+
+// CAPTCHA asked after repeated failures, no challenge when nil
+var LoginCaptcha security.CaptchaVerifier
+
+// Widget settings of LoginCaptcha sent to the login page
+var CaptchaWidget security.CaptchaConfig
+
+// Function returning the client IP of a request, replaced by main to honour the trusted proxies
+var ClientIP = func(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Function called when an account receives a burst of failed logins, used to warn its owner
+var OnSuspiciousLogin func(userID, ip string, failures int)
+
+// Hash compared when the email is unknown, so both failures take the same time
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("forum-dummy-password"), bcrypt.DefaultCost)
+
+// Function to answer a refused login: the login form is sent back with an error code
+// for browsers, other clients get the status and the message
+func loginError(w http.ResponseWriter, r *http.Request, code, message string, status int, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/login?error="+code, http.StatusSeeOther)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// Function to record the failure of a reserved login attempt and warn the owner of the
+// account on a burst
+func recordLoginFailure(attempt int64, ip, email, userID string) {
+	failure, err := loginLimiter.Failure(attempt, ip, email)
+	if err != nil {
+		log.Println("Error recording login failure:", err)
+		return
+	}
+	if failure.Suspicious && userID != "" && OnSuspiciousLogin != nil {
+		OnSuspiciousLogin(userID, ip, failure.AccountFailures)
+	}
+}
+
+// Function to send the CAPTCHA widget settings to the login page
+func CaptchaSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": LoginCaptcha != nil,
+		"widget":  CaptchaWidget,
+	})
+}
@@ -0,0 +1,385 @@
+package auth
+
+import "strings"
+
+// Function to create the tables and columns added by the forum features,
+// errors are ignored so it can run on every start like InitDB
+func initFeatureTables() {
+	// Image pipeline: dimensions of the post images and their thumbnails
+	DB.Exec("ALTER TABLE post_images ADD COLUMN width INTEGER DEFAULT 0")
+	DB.Exec("ALTER TABLE post_images ADD COLUMN height INTEGER DEFAULT 0")
+	DB.Exec(`CREATE TABLE IF NOT EXISTS image_thumbnails (
+	image_path TEXT NOT NULL,
+	size       INTEGER NOT NULL,
+	thumb_path TEXT NOT NULL,
+	width      INTEGER NOT NULL,
+	height     INTEGER NOT NULL,
+	PRIMARY KEY (image_path, size)
+	)`)
+
+	// Attachments: ordered files of posts and comments, replacing post_images
+	DB.Exec(`CREATE TABLE IF NOT EXISTS attachments (
+	id            TEXT PRIMARY KEY,
+	user_id       TEXT NOT NULL,
+	post_id       TEXT,
+	comment_id    TEXT,
+	file_path     TEXT NOT NULL,
+	original_name TEXT NOT NULL DEFAULT '',
+	mime_type     TEXT NOT NULL,
+	size          INTEGER NOT NULL DEFAULT 0,
+	width         INTEGER NOT NULL DEFAULT 0,
+	height        INTEGER NOT NULL DEFAULT 0,
+	position      INTEGER NOT NULL DEFAULT 0,
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	// Private attachments are only served through signed URLs
+	DB.Exec("ALTER TABLE attachments ADD COLUMN private INTEGER NOT NULL DEFAULT 0")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_attachments_post ON attachments(post_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_attachments_comment ON attachments(comment_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_attachments_user ON attachments(user_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_attachments_file ON attachments(file_path)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_image_thumbnails_thumb ON image_thumbnails(thumb_path)")
+	// Copy the images uploaded before attachments existed
+	DB.Exec(`INSERT INTO attachments (id, user_id, post_id, file_path, original_name, mime_type, width, height, created_at)
+	SELECT lower(hex(randomblob(16))), p.user_id, pi.post_id, pi.image_path, replace(pi.image_path, 'uploads/', ''),
+		CASE lower(substr(pi.image_path, -4)) WHEN '.png' THEN 'image/png' WHEN '.gif' THEN 'image/gif' WHEN 'webp' THEN 'image/webp' ELSE 'image/jpeg' END,
+		COALESCE(pi.width, 0), COALESCE(pi.height, 0), p.created_at
+	FROM post_images pi JOIN posts p ON p.id = pi.post_id
+	WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.file_path = pi.image_path)`)
+	// Allow-list of the attachment types with their size limit, editable by the admin
+	DB.Exec(`CREATE TABLE IF NOT EXISTS attachment_types (
+	mime_type TEXT PRIMARY KEY,
+	extension TEXT NOT NULL,
+	max_size  INTEGER NOT NULL
+	)`)
+	DB.Exec(`INSERT OR IGNORE INTO attachment_types (mime_type, extension, max_size) VALUES
+	('image/jpeg', '.jpg', 20971520),
+	('image/png', '.png', 20971520),
+	('image/gif', '.gif', 20971520),
+	('application/pdf', '.pdf', 10485760),
+	('text/plain', '.txt', 2097152),
+	('application/zip', '.zip', 26214400)`)
+
+	// Notifications: channels chosen at creation, coalescing of repeated events and digest state
+	DB.Exec("ALTER TABLE notifications ADD COLUMN count INTEGER NOT NULL DEFAULT 1")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN in_app INTEGER NOT NULL DEFAULT 1")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN email INTEGER NOT NULL DEFAULT 0")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN emailed INTEGER NOT NULL DEFAULT 0")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, post_id, action)")
+	DB.Exec(`CREATE TABLE IF NOT EXISTS notification_actors (
+	notification_id TEXT NOT NULL,
+	user_id         TEXT NOT NULL,
+	PRIMARY KEY (notification_id, user_id)
+	)`)
+	// Preferences only store what differs from the defaults
+	DB.Exec(`CREATE TABLE IF NOT EXISTS notification_preferences (
+	user_id TEXT NOT NULL,
+	type    TEXT NOT NULL,
+	channel TEXT CHECK(channel IN ('in_app', 'email')) NOT NULL,
+	enabled INTEGER NOT NULL,
+	PRIMARY KEY (user_id, type, channel)
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS notification_settings (
+	user_id        TEXT PRIMARY KEY,
+	digest         TEXT CHECK(digest IN ('none', 'daily', 'weekly')) NOT NULL DEFAULT 'daily',
+	last_digest_at TIMESTAMP
+	)`)
+	// Structured notifications: who caused them, on what, and the template of their text
+	DB.Exec("ALTER TABLE notifications ADD COLUMN actor_id TEXT")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN target_type TEXT")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN target_id TEXT")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN template TEXT")
+	DB.Exec("ALTER TABLE notifications ADD COLUMN params TEXT NOT NULL DEFAULT '{}'")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_notifications_target ON notifications(user_id, template, target_type, target_id)")
+	// The older notifications kept the liked comment in post_id and had no actor
+	DB.Exec(`UPDATE notifications SET
+		template = CASE WHEN action = 'like' AND content = 'dislike' THEN 'dislike' ELSE action END,
+		target_type = CASE WHEN post_id IN (SELECT id FROM comments) THEN 'comment' ELSE 'post' END,
+		target_id = post_id
+	WHERE template IS NULL`)
+	DB.Exec("UPDATE notifications SET post_id = (SELECT post_id FROM comments WHERE id = notifications.target_id) WHERE target_type = 'comment' AND post_id = target_id")
+	// Mentions of users in posts and comments
+	DB.Exec(`CREATE TABLE IF NOT EXISTS mentions (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	author_id  TEXT NOT NULL,
+	post_id    TEXT NOT NULL,
+	comment_id TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_mentions_user ON mentions(user_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_mentions_post ON mentions(post_id)")
+	DB.Exec("ALTER TABLE notification_settings ADD COLUMN allow_mentions INTEGER NOT NULL DEFAULT 1")
+	// Subscriptions to posts, categories and users, the commenters of a post follow it
+	DB.Exec(`CREATE TABLE IF NOT EXISTS subscriptions (
+	user_id     TEXT NOT NULL,
+	target_type TEXT CHECK(target_type IN ('post', 'category', 'user', 'tag')) NOT NULL,
+	target_id   TEXT NOT NULL,
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, target_type, target_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_subscriptions_target ON subscriptions(target_type, target_id)")
+	DB.Exec(`INSERT OR IGNORE INTO subscriptions (user_id, target_type, target_id, created_at)
+	SELECT c.user_id, 'post', c.post_id, MIN(c.created_at) FROM comments c
+	WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id AND p.user_id = c.user_id)
+	GROUP BY c.user_id, c.post_id`)
+	// Reporter of a report, told about the moderation outcome
+	DB.Exec("ALTER TABLE reports ADD COLUMN reporter_id TEXT")
+
+	// Private messages: 1:1 and group conversations, the members keep when they last read them
+	DB.Exec(`CREATE TABLE IF NOT EXISTS conversations (
+	id         TEXT PRIMARY KEY,
+	title      TEXT NOT NULL DEFAULT '',
+	is_group   INTEGER NOT NULL DEFAULT 0,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS conversation_members (
+	conversation_id TEXT NOT NULL,
+	user_id         TEXT NOT NULL,
+	joined_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_read_at    TIMESTAMP,
+	PRIMARY KEY (conversation_id, user_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversation_members_user ON conversation_members(user_id)")
+	DB.Exec(`CREATE TABLE IF NOT EXISTS messages (
+	id              TEXT PRIMARY KEY,
+	conversation_id TEXT NOT NULL,
+	sender_id       TEXT NOT NULL,
+	content         TEXT NOT NULL,
+	created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, created_at)")
+	DB.Exec("ALTER TABLE attachments ADD COLUMN message_id TEXT")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_attachments_message ON attachments(message_id)")
+	// Reports of private messages, only the reported message is shown to the admin
+	DB.Exec(`CREATE TABLE IF NOT EXISTS message_reports (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id  TEXT NOT NULL,
+	reporter_id TEXT NOT NULL,
+	reason      TEXT NOT NULL,
+	status      TEXT NOT NULL DEFAULT 'pending',
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	// Users blocked or muted by a user, their content is hidden from the user
+	DB.Exec(`CREATE TABLE IF NOT EXISTS blocks (
+	user_id    TEXT NOT NULL,
+	blocked_id TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, blocked_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_blocks_blocked ON blocks(blocked_id)")
+	// A muted user is only hidden, a blocked one cannot reach the user at all
+	DB.Exec("ALTER TABLE blocks ADD COLUMN kind TEXT NOT NULL DEFAULT 'block'")
+
+	// Categories: tree of categories with their presentation, order and posting rules
+	DB.Exec("ALTER TABLE categories ADD COLUMN parent_id INTEGER")
+	DB.Exec("ALTER TABLE categories ADD COLUMN slug TEXT")
+	DB.Exec("ALTER TABLE categories ADD COLUMN description TEXT NOT NULL DEFAULT ''")
+	DB.Exec("ALTER TABLE categories ADD COLUMN icon TEXT NOT NULL DEFAULT ''")
+	DB.Exec("ALTER TABLE categories ADD COLUMN position INTEGER NOT NULL DEFAULT 0")
+	// Older databases already have a nullable position column
+	DB.Exec("UPDATE categories SET position = 0 WHERE position IS NULL")
+	// A read-only category takes no new posts, an archived one no comments either
+	DB.Exec("ALTER TABLE categories ADD COLUMN status TEXT NOT NULL DEFAULT 'open'")
+	// Lowest role allowed to post in the category
+	DB.Exec("ALTER TABLE categories ADD COLUMN post_role TEXT NOT NULL DEFAULT 'user'")
+	DB.Exec("ALTER TABLE categories ADD COLUMN qa BOOLEAN NOT NULL DEFAULT 0")
+	// The categories created before get their slug from forum.InitCategorySlugs
+	DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_post_categories_category ON post_categories(category_id)")
+
+	// Tags: free-form labels of the posts, stored normalized
+	DB.Exec(`CREATE TABLE IF NOT EXISTS tags (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS post_tags (
+	post_id TEXT NOT NULL,
+	tag_id  INTEGER NOT NULL,
+	PRIMARY KEY (post_id, tag_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_post_tags_tag ON post_tags(tag_id)")
+	// Other names of a tag, replaced by the tag when applied, including the merged tags
+	DB.Exec(`CREATE TABLE IF NOT EXISTS tag_synonyms (
+	synonym    TEXT PRIMARY KEY,
+	tag_id     INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_tag_synonyms_tag ON tag_synonyms(tag_id)")
+	// Tags can be followed, the subscriptions created before accept them once rebuilt
+	var subscriptionsSQL string
+	DB.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'subscriptions'").Scan(&subscriptionsSQL)
+	if subscriptionsSQL != "" && !strings.Contains(subscriptionsSQL, "'tag'") {
+		rebuildTable("subscriptions", `CREATE TABLE subscriptions_new (
+		user_id     TEXT NOT NULL,
+		target_type TEXT CHECK(target_type IN ('post', 'category', 'user', 'tag')) NOT NULL,
+		target_id   TEXT NOT NULL,
+		created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, target_type, target_id)
+		)`, "user_id, target_type, target_id, created_at")
+		DB.Exec("CREATE INDEX IF NOT EXISTS idx_subscriptions_target ON subscriptions(target_type, target_id)")
+	}
+
+	// Post lifecycle: locked threads refuse comments, archived ones are read-only
+	// and deleted posts stay in the trash until restored or purged
+	DB.Exec("ALTER TABLE posts ADD COLUMN locked_at TIMESTAMP")
+	DB.Exec("ALTER TABLE posts ADD COLUMN locked_by TEXT")
+	DB.Exec("ALTER TABLE posts ADD COLUMN archived_at TIMESTAMP")
+	DB.Exec("ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP")
+	DB.Exec("ALTER TABLE posts ADD COLUMN deleted_by TEXT")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_posts_deleted ON posts(deleted_at)")
+	// Comment accepted as the answer of a post in a questions and answers category
+	DB.Exec("ALTER TABLE posts ADD COLUMN accepted_comment_id TEXT")
+	DB.Exec("ALTER TABLE posts ADD COLUMN accepted_at TIMESTAMP")
+	// Pinned posts, the scope is empty for the whole forum or the ID of a category
+	DB.Exec(`CREATE TABLE IF NOT EXISTS post_pins (
+	post_id   TEXT NOT NULL,
+	scope     TEXT NOT NULL DEFAULT '',
+	pinned_by TEXT,
+	pinned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (post_id, scope)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_post_pins_scope ON post_pins(scope)")
+
+	// Polls: at most one per post, with its options and the choices of each voter
+	DB.Exec(`CREATE TABLE IF NOT EXISTS polls (
+	post_id            TEXT PRIMARY KEY,
+	question           TEXT NOT NULL,
+	multiple           BOOLEAN NOT NULL DEFAULT 0,
+	anonymous          BOOLEAN NOT NULL DEFAULT 0,
+	results_visibility TEXT CHECK(results_visibility IN ('always', 'after_vote', 'after_close')) NOT NULL DEFAULT 'always',
+	closes_at          TIMESTAMP,
+	created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS poll_options (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id  TEXT NOT NULL,
+	label    TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_poll_options_post ON poll_options(post_id)")
+	// One ballot per user and poll, holding the options they chose
+	DB.Exec(`CREATE TABLE IF NOT EXISTS poll_ballots (
+	post_id    TEXT NOT NULL,
+	user_id    TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (post_id, user_id)
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS poll_votes (
+	post_id   TEXT NOT NULL,
+	user_id   TEXT NOT NULL,
+	option_id INTEGER NOT NULL,
+	PRIMARY KEY (post_id, user_id, option_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_poll_votes_option ON poll_votes(option_id)")
+
+	// Scheduled posts stay hidden until the scheduler publishes them at scheduled_at
+	DB.Exec("ALTER TABLE posts ADD COLUMN scheduled_at TIMESTAMP")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts(scheduled_at)")
+	// The scheduled dates were stored in local time, they are compared in UTC now
+	DB.Exec(`UPDATE posts SET scheduled_at = strftime('%Y-%m-%d %H:%M:%f+00:00', scheduled_at)
+	WHERE scheduled_at IS NOT NULL AND scheduled_at NOT LIKE '%+00:00'`)
+	// Same for the dates of the account deletions
+	DB.Exec(`UPDATE account_deletions SET scheduled_at = strftime('%Y-%m-%d %H:%M:%f+00:00', scheduled_at)
+	WHERE scheduled_at NOT LIKE '%+00:00'`)
+	// Drafts saved while a post is written, the categories and tags are kept as typed
+	DB.Exec(`CREATE TABLE IF NOT EXISTS drafts (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	title      TEXT NOT NULL DEFAULT '',
+	content    TEXT NOT NULL DEFAULT '',
+	categories TEXT NOT NULL DEFAULT '',
+	tags       TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_drafts_user ON drafts(user_id, updated_at)")
+
+	// Trust levels: the last level reached, to tell the users their promotions
+	DB.Exec("ALTER TABLE users ADD COLUMN trust_level INTEGER NOT NULL DEFAULT 0")
+	// The posts of the new users are held until a moderator reviews them
+	DB.Exec("ALTER TABLE posts ADD COLUMN held_at TIMESTAMP")
+	// Moderation outcomes changing the reputation of a user
+	DB.Exec(`CREATE TABLE IF NOT EXISTS reputation_events (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    TEXT NOT NULL,
+	post_id    TEXT,
+	kind       TEXT NOT NULL,
+	points     INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_reputation_events_user ON reputation_events(user_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_reputation_events_post ON reputation_events(post_id)")
+	// Badges configured by the admins, awarded by rule
+	DB.Exec(`CREATE TABLE IF NOT EXISTS badges (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	name        TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	icon        TEXT NOT NULL DEFAULT '',
+	rule        TEXT NOT NULL,
+	threshold   INTEGER NOT NULL DEFAULT 0,
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS user_badges (
+	user_id    TEXT NOT NULL,
+	badge_id   INTEGER NOT NULL,
+	awarded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, badge_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_user_badges_badge ON user_badges(badge_id)")
+
+	// Private bookmarks of posts and comments, each one in at most one collection of its owner
+	DB.Exec(`CREATE TABLE IF NOT EXISTS bookmark_collections (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_id, name)
+	)`)
+	DB.Exec(`CREATE TABLE IF NOT EXISTS bookmarks (
+	user_id       TEXT NOT NULL,
+	target_type   TEXT NOT NULL CHECK(target_type IN ('post', 'comment')),
+	target_id     TEXT NOT NULL,
+	collection_id INTEGER,
+	note          TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, target_type, target_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookmarks_collection ON bookmarks(collection_id)")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookmarks_target ON bookmarks(target_type, target_id)")
+
+	// Read tracking: the date each user last read a post, and the date they marked everything read
+	DB.Exec(`CREATE TABLE IF NOT EXISTS post_reads (
+	user_id      TEXT NOT NULL,
+	post_id      TEXT NOT NULL,
+	last_read_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, post_id)
+	)`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_post_reads_post ON post_reads(post_id)")
+	DB.Exec("ALTER TABLE users ADD COLUMN read_all_at TIMESTAMP")
+}
+
+// Function to replace a table by the one created as <table>_new, keeping the given columns,
+// used when a constraint changes since SQLite cannot alter it
+func rebuildTable(table, createNew, columns string) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return
+	}
+	for _, query := range []string{
+		createNew,
+		"INSERT INTO " + table + "_new (" + columns + ") SELECT " + columns + " FROM " + table,
+		"DROP TABLE " + table,
+		"ALTER TABLE " + table + "_new RENAME TO " + table,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	tx.Commit()
+}
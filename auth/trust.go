@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Names of the trust levels, a user climbs them with their activity and reputation
+var TrustLevels = []string{"new", "basic", "member", "regular"}
+
+// Highest trust level, the moderators and admins always have it
+var MaxTrustLevel = len(TrustLevels) - 1
+
+// Trust level needed for each capability
+var trustCapabilities = map[string]int{
+	"post_links":         1,
+	"post_images":        1,
+	"skip_premoderation": 1,
+	"start_conversation": 1,
+	"retag_posts":        3,
+}
+
+// Function giving the trust level of a user, the one the forum stores from their activity
+// and reputation. The moderators and admins always have the highest.
+func TrustLevelOf(userID, role string) int {
+	if HasRole(role, "moderator") {
+		return MaxTrustLevel
+	}
+	var level int
+	DB.QueryRow("SELECT COALESCE(trust_level, 0) FROM users WHERE id = ?", userID).Scan(&level)
+	return min(level, MaxTrustLevel)
+}
+
+// Function to list the capabilities with the trust level they need
+func TrustCapabilities() map[string]int {
+	capabilities := make(map[string]int, len(trustCapabilities))
+	for capability, level := range trustCapabilities {
+		capabilities[capability] = level
+	}
+	return capabilities
+}
+
+// Function to check whether a user has the trust level of a capability, unknown
+// capabilities are refused
+func HasTrust(userID, role, capability string) bool {
+	level, ok := trustCapabilities[capability]
+	return ok && TrustLevelOf(userID, role) >= level
+}
+
+// Function to check if the user has the trust level of a capability to access a route
+func TrustMiddleware(capability string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, userRole, err := GetUserFromSessionRole(r)
+		if err != nil {
+			fmt.Println("❌ TrustMiddleware: Aucun utilisateur authentifié")
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if _, ok := trustCapabilities[capability]; !ok {
+			fmt.Println("Capacité inconnue:", capability)
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+		if !HasTrust(userID, userRole, capability) {
+			fmt.Println("Accès interdit: Niveau de confiance insuffisant pour", capability)
+			http.Error(w, "Niveau de confiance insuffisant", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
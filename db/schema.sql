@@ -6,6 +6,10 @@ CREATE TABLE users (
     username    TEXT UNIQUE NOT NULL,
     password    TEXT NULL,
     role        TEXT CHECK(role IN ('guest', 'user', 'moderator', 'admin')) DEFAULT 'user',
+    bio         TEXT DEFAULT '',
+    avatar_path TEXT DEFAULT '',
+    trust_level INTEGER NOT NULL DEFAULT 0,
+    read_all_at TIMESTAMP,
     created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -15,6 +19,15 @@ CREATE TABLE posts (
     title       TEXT NOT NULL,
     content     TEXT NOT NULL,
     created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    locked_at   TIMESTAMP,
+    locked_by   TEXT,
+    archived_at TIMESTAMP,
+    deleted_at  TIMESTAMP,
+    deleted_by  TEXT,
+    accepted_comment_id TEXT,
+    accepted_at TIMESTAMP,
+    scheduled_at TIMESTAMP,
+    held_at TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
@@ -30,7 +43,16 @@ CREATE TABLE comments (
 
 CREATE TABLE categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,  
-    name TEXT UNIQUE NOT NULL              
+    name TEXT UNIQUE NOT NULL,
+    parent_id INTEGER,
+    slug TEXT UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    icon TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    status TEXT CHECK(status IN ('open', 'read_only', 'archived')) NOT NULL DEFAULT 'open',
+    post_role TEXT CHECK(post_role IN ('user', 'moderator', 'admin')) NOT NULL DEFAULT 'user',
+    qa BOOLEAN NOT NULL DEFAULT 0,
+    FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
 );
 
 CREATE TABLE post_categories (
@@ -69,9 +91,20 @@ CREATE TABLE IF NOT EXISTS ip_rate_limit (
 CREATE TABLE post_images (
     post_id TEXT NOT NULL,
     image_path TEXT NOT NULL,
+    width INTEGER DEFAULT 0,
+    height INTEGER DEFAULT 0,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS image_thumbnails (
+    image_path TEXT NOT NULL,
+    size       INTEGER NOT NULL,
+    thumb_path TEXT NOT NULL,
+    width      INTEGER NOT NULL,
+    height     INTEGER NOT NULL,
+    PRIMARY KEY (image_path, size)
+);
+
 CREATE TABLE IF NOT EXISTS notifications (
     id          TEXT PRIMARY KEY,
     user_id     TEXT NOT NULL,
@@ -80,6 +113,15 @@ CREATE TABLE IF NOT EXISTS notifications (
     content     TEXT NOT NULL,
     created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     seen        BOOLEAN DEFAULT FALSE,
+    count       INTEGER NOT NULL DEFAULT 1,
+    in_app      INTEGER NOT NULL DEFAULT 1,
+    email       INTEGER NOT NULL DEFAULT 0,
+    emailed     INTEGER NOT NULL DEFAULT 0,
+    actor_id    TEXT,
+    target_type TEXT,
+    target_id   TEXT,
+    template    TEXT,
+    params      TEXT NOT NULL DEFAULT '{}',
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
@@ -88,6 +130,7 @@ CREATE TABLE IF NOT EXISTS reports (
     post_id TEXT NOT NULL,
     reason TEXT NOT NULL,
     status TEXT NOT NULL DEFAULT 'pending',
+    reporter_id TEXT,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
 );
 
@@ -111,4 +154,279 @@ CREATE TABLE IF NOT EXISTS promotion_requests (
     approved_at TIMESTAMP NULL,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (approved_by) REFERENCES users(id)
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id      TEXT PRIMARY KEY,
+    mode         TEXT CHECK(mode IN ('anonymize', 'delete')) NOT NULL,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    scheduled_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id            TEXT PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    post_id       TEXT,
+    comment_id    TEXT,
+    file_path     TEXT NOT NULL,
+    original_name TEXT NOT NULL DEFAULT '',
+    mime_type     TEXT NOT NULL,
+    size          INTEGER NOT NULL DEFAULT 0,
+    width         INTEGER NOT NULL DEFAULT 0,
+    height        INTEGER NOT NULL DEFAULT 0,
+    position      INTEGER NOT NULL DEFAULT 0,
+    private       INTEGER NOT NULL DEFAULT 0,
+    message_id    TEXT,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS attachment_types (
+    mime_type TEXT PRIMARY KEY,
+    extension TEXT NOT NULL,
+    max_size  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    account    TEXT NOT NULL,
+    ip         TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_failures_account ON login_failures(account, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_failures_ip ON login_failures(ip, created_at);
+
+CREATE TABLE IF NOT EXISTS notification_actors (
+    notification_id TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    PRIMARY KEY (notification_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT NOT NULL,
+    type    TEXT NOT NULL,
+    channel TEXT CHECK(channel IN ('in_app', 'email')) NOT NULL,
+    enabled INTEGER NOT NULL,
+    PRIMARY KEY (user_id, type, channel)
+);
+
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id        TEXT PRIMARY KEY,
+    digest         TEXT CHECK(digest IN ('none', 'daily', 'weekly')) NOT NULL DEFAULT 'daily',
+    last_digest_at TIMESTAMP,
+    allow_mentions INTEGER NOT NULL DEFAULT 1,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    author_id  TEXT NOT NULL,
+    post_id    TEXT NOT NULL,
+    comment_id TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id     TEXT NOT NULL,
+    target_type TEXT CHECK(target_type IN ('post', 'category', 'user', 'tag')) NOT NULL,
+    target_id   TEXT NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, target_type, target_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id         TEXT PRIMARY KEY,
+    title      TEXT NOT NULL DEFAULT '',
+    is_group   INTEGER NOT NULL DEFAULT 0,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    joined_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_read_at    TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id              TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    sender_id       TEXT NOT NULL,
+    content         TEXT NOT NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS message_reports (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id  TEXT NOT NULL,
+    reporter_id TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'pending',
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    user_id    TEXT NOT NULL,
+    blocked_id TEXT NOT NULL,
+    kind       TEXT CHECK(kind IN ('block', 'mute')) NOT NULL DEFAULT 'block',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, blocked_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id TEXT NOT NULL,
+    tag_id  INTEGER NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tag_synonyms (
+    synonym    TEXT PRIMARY KEY,
+    tag_id     INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_pins (
+    post_id   TEXT NOT NULL,
+    scope     TEXT NOT NULL DEFAULT '',
+    pinned_by TEXT,
+    pinned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, scope),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS polls (
+    post_id            TEXT PRIMARY KEY,
+    question           TEXT NOT NULL,
+    multiple           BOOLEAN NOT NULL DEFAULT 0,
+    anonymous          BOOLEAN NOT NULL DEFAULT 0,
+    results_visibility TEXT CHECK(results_visibility IN ('always', 'after_vote', 'after_close')) NOT NULL DEFAULT 'always',
+    closes_at          TIMESTAMP,
+    created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id  TEXT NOT NULL,
+    label    TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (post_id) REFERENCES polls(post_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    post_id    TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES polls(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    post_id   TEXT NOT NULL,
+    user_id   TEXT NOT NULL,
+    option_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, user_id, option_id),
+    FOREIGN KEY (post_id, user_id) REFERENCES poll_ballots(post_id, user_id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES poll_options(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS drafts (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    title      TEXT NOT NULL DEFAULT '',
+    content    TEXT NOT NULL DEFAULT '',
+    categories TEXT NOT NULL DEFAULT '',
+    tags       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS reputation_events (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT NOT NULL,
+    post_id    TEXT,
+    kind       TEXT NOT NULL,
+    points     INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS badges (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    icon        TEXT NOT NULL DEFAULT '',
+    rule        TEXT NOT NULL,
+    threshold   INTEGER NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_badges (
+    user_id    TEXT NOT NULL,
+    badge_id   INTEGER NOT NULL,
+    awarded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, badge_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (badge_id) REFERENCES badges(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bookmark_collections (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id       TEXT NOT NULL,
+    target_type   TEXT NOT NULL CHECK(target_type IN ('post', 'comment')),
+    target_id     TEXT NOT NULL,
+    collection_id INTEGER,
+    note          TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, target_type, target_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (collection_id) REFERENCES bookmark_collections(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS post_reads (
+    user_id      TEXT NOT NULL,
+    post_id      TEXT NOT NULL,
+    last_read_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
@@ -0,0 +1,98 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Reputation earned by the author of a comment accepted as an answer, a like earns one
+const acceptedAnswerReputation = 10
+
+// Condition true when the post p is in a questions and answers category
+const qaPost = `EXISTS (SELECT 1 FROM post_categories qpc JOIN categories qc ON CAST(qc.id AS TEXT) = qpc.category_id
+	WHERE qpc.post_id = p.id AND qc.qa)`
+
+// Function to check whether the user may accept an answer to a post: the post must be in a
+// questions and answers category and the user must be its author or a moderator
+func canAcceptAnswer(postID, userID, role string) bool {
+	var authorID string
+	var qa bool
+	err := auth.DB.QueryRow("SELECT p.user_id, "+qaPost+" FROM posts p WHERE p.id = ? AND p.deleted_at IS NULL", postID).Scan(&authorID, &qa)
+	if err != nil || !qa {
+		return false
+	}
+	return authorID == userID || auth.HasRole(role, "moderator")
+}
+
+// Function to mark a comment as the accepted answer of its post, replacing the previous
+// one, or to remove it with accepted=false
+func AcceptAnswer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, role, err := auth.GetUserFromSessionRole(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	commentID := r.FormValue("comment_id")
+	var postID, answererID string
+	err = auth.DB.QueryRow("SELECT post_id, user_id FROM comments WHERE id = ?", commentID).Scan(&postID, &answererID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving comment", http.StatusInternalServerError)
+		return
+	}
+	if status, err := postStatus(postID); err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	} else if status == "deleted" {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if status == "archived" {
+		http.Error(w, "This post is archived", http.StatusForbidden)
+		return
+	}
+	if !canAcceptAnswer(postID, userID, role) {
+		http.Error(w, "Only the author of a question or a moderator can accept its answer", http.StatusForbidden)
+		return
+	}
+	if r.FormValue("accepted") == "false" {
+		_, err = auth.DB.Exec("UPDATE posts SET accepted_comment_id = NULL, accepted_at = NULL WHERE id = ? AND accepted_comment_id = ?", postID, commentID)
+		if err != nil {
+			http.Error(w, "Error updating answer", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Answer unaccepted successfully"})
+		return
+	}
+	var previousID sql.NullString
+	auth.DB.QueryRow("SELECT accepted_comment_id FROM posts WHERE id = ?", postID).Scan(&previousID)
+	_, err = auth.DB.Exec("UPDATE posts SET accepted_comment_id = ?, accepted_at = ? WHERE id = ?", commentID, time.Now(), postID)
+	if err != nil {
+		http.Error(w, "Error updating answer", http.StatusInternalServerError)
+		return
+	}
+	// The answerer is told, unless they accepted their own answer or it was already accepted
+	if answererID != userID && previousID.String != commentID {
+		CreateNotification(NotificationEvent{
+			UserID:     answererID,
+			ActorID:    userID,
+			Action:     "accepted_answer",
+			PostID:     postID,
+			TargetType: "post",
+			TargetID:   postID,
+			Template:   "accepted_answer",
+			Params:     map[string]string{"comment_id": commentID},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Answer accepted successfully"})
+}
@@ -0,0 +1,213 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the routes of the public API, a new version gets a new prefix
+const apiPrefix = "/api/v1"
+
+// Limits of the API
+const (
+	maxAPIBodySize      = 1 << 20
+	defaultAPIPageLimit = 20
+	maxAPIPageLimit     = 100
+)
+
+// Machine-readable codes of the errors, by HTTP status
+var apiErrorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// Error answered by the API, inside the error envelope
+type apiError struct {
+	Code    string `json:"code" description:"Machine-readable code of the error" enum:"invalid_request,unauthorized,forbidden,not_found,method_not_allowed,conflict,payload_too_large,unsupported_media_type,rate_limited,internal_error"`
+	Message string `json:"message" description:"Description of the error for a human"`
+}
+
+// Envelope of the errors
+type apiErrorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+// Page of a list, the next one starts at offset + limit while has_more is true
+type apiPage struct {
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"has_more"`
+}
+
+// User calling the API, empty for a visitor
+type apiCaller struct {
+	UserID string
+	Role   string
+}
+
+// Query parameter of a route
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Route of the API. The OpenAPI document is generated from the routes, the request and
+// response fields give the types of the bodies.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// The route needs a session
+	Auth  bool
+	Query []apiParam
+	// Type of the JSON body, nil without body
+	Request any
+	// Type of the data answered, nil for an empty answer, with List for a page of them
+	Response any
+	List     bool
+	Status   int
+	Handle   func(w http.ResponseWriter, r *http.Request, caller apiCaller)
+}
+
+// Function to answer data in the success envelope
+func writeAPIData(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// Function to answer a page of a list in the success envelope
+func writeAPIList(w http.ResponseWriter, data any, page apiPage) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": data, "page": page})
+}
+
+// Function to answer an error in the error envelope, its code comes from the status
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	code, ok := apiErrorCodes[status]
+	if !ok {
+		code = apiErrorCodes[http.StatusInternalServerError]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiErrorEnvelope{Error: apiError{Code: code, Message: message}})
+}
+
+// Function to refuse a request of the API over its rate limit with the error envelope
+func RefuseAPIRequest(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusTooManyRequests, "Too many requests, wait a moment")
+}
+
+// Function to read the JSON body of a request, unknown fields are refused
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, body any) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "The body must be JSON")
+		return false
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "The body is too large")
+		case errors.Is(err, io.EOF):
+			writeAPIError(w, http.StatusBadRequest, "The body is empty")
+		default:
+			writeAPIError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+// Function to read the limit and offset of a list
+func apiPageOf(w http.ResponseWriter, r *http.Request) (apiPage, bool) {
+	page := apiPage{Limit: defaultAPIPageLimit}
+	var err error
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if page.Limit, err = strconv.Atoi(value); err != nil || page.Limit <= 0 || page.Limit > maxAPIPageLimit {
+			writeAPIError(w, http.StatusBadRequest, "The limit must be between 1 and "+strconv.Itoa(maxAPIPageLimit))
+			return page, false
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		if page.Offset, err = strconv.Atoi(value); err != nil || page.Offset < 0 {
+			writeAPIError(w, http.StatusBadRequest, "The offset must be a positive number")
+			return page, false
+		}
+	}
+	return page, true
+}
+
+// Function to wrap the handler of a route: the caller is identified, and refused when the
+// route needs a session they do not have
+func (route apiRoute) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var caller apiCaller
+		if userID, role, err := auth.GetUserFromSessionRole(r); err == nil {
+			caller = apiCaller{UserID: userID, Role: role}
+		}
+		if route.Auth && caller.UserID == "" {
+			writeAPIError(w, http.StatusUnauthorized, "A session is required")
+			return
+		}
+		route.Handle(w, r, caller)
+	}
+}
+
+// Function to add the routes of the API and its OpenAPI document to the mux. The unknown
+// routes and methods under the prefix are answered with the error envelope too.
+func RegisterAPI(mux *http.ServeMux) {
+	paths := http.NewServeMux()
+	methods := map[string][]string{}
+	for _, route := range apiRoutes() {
+		mux.Handle(route.Method+" "+apiPrefix+route.Path, route.handler())
+		if methods[route.Path] == nil {
+			paths.Handle(apiPrefix+route.Path, http.NotFoundHandler())
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.json", GetOpenAPIDocument)
+	paths.Handle(apiPrefix+"/openapi.json", http.NotFoundHandler())
+	methods["/openapi.json"] = []string{http.MethodGet}
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := paths.Handler(r); pattern != "" {
+			w.Header().Set("Allow", strings.Join(methods[strings.TrimPrefix(pattern, apiPrefix)], ", "))
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed on this route")
+			return
+		}
+		writeAPIError(w, http.StatusNotFound, "Route not found")
+	})
+}
+
+// Function to list the patterns of the routes of the API reading data, or of those changing it
+func APIPatterns(write bool) []string {
+	var patterns []string
+	if !write {
+		patterns = append(patterns, "GET "+apiPrefix+"/openapi.json")
+	}
+	for _, route := range apiRoutes() {
+		if (route.Method != http.MethodGet) == write {
+			patterns = append(patterns, route.Method+" "+apiPrefix+route.Path)
+		}
+	}
+	return slices.Compact(patterns)
+}
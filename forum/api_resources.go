@@ -0,0 +1,609 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Author of a post or comment in the API
+type apiAuthor struct {
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url" description:"Empty without avatar"`
+}
+
+// Category of a post in the API
+type apiCategoryRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Post of the API
+type apiPost struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Content    string           `json:"content"`
+	Author     apiAuthor        `json:"author"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Categories []apiCategoryRef `json:"categories"`
+	Tags       []string         `json:"tags"`
+	Status     string           `json:"status" description:"Locked posts refuse comments, archived ones are read-only" enum:"open,locked,archived"`
+	Pinned     bool             `json:"pinned" description:"Pinned to the whole forum"`
+	Question   bool             `json:"question" description:"Posted in a questions and answers category"`
+	// The accepted answer of a question
+	AcceptedCommentID *string `json:"accepted_comment_id"`
+	CommentCount      int     `json:"comment_count"`
+	Likes             int     `json:"likes"`
+	Dislikes          int     `json:"dislikes"`
+	Reaction          *string `json:"reaction" description:"Reaction of the caller, null without one" enum:"like,dislike"`
+}
+
+// Comment of the API
+type apiComment struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"post_id"`
+	Author    apiAuthor `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	Accepted  bool      `json:"accepted" description:"Accepted answer of the question"`
+	Likes     int       `json:"likes"`
+	Dislikes  int       `json:"dislikes"`
+	Reaction  *string   `json:"reaction" description:"Reaction of the caller, null without one" enum:"like,dislike"`
+}
+
+// Category of the API, each one is listed after its parent
+type apiCategory struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Slug        string  `json:"slug"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon"`
+	ParentID    *string `json:"parent_id"`
+	Depth       int     `json:"depth"`
+	Status      string  `json:"status" enum:"open,read_only,archived"`
+	PostRole    string  `json:"post_role" description:"Lowest role allowed to post" enum:"user,moderator,admin"`
+	Questions   bool    `json:"questions" description:"Questions and answers category"`
+}
+
+// Tag of the API
+type apiTag struct {
+	Name      string   `json:"name"`
+	PostCount int      `json:"post_count"`
+	Synonyms  []string `json:"synonyms"`
+}
+
+// Badge of a user in the API
+type apiBadge struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	AwardedAt   time.Time `json:"awarded_at"`
+}
+
+// Public profile of a user in the API
+type apiUser struct {
+	Username     string     `json:"username"`
+	AvatarURL    string     `json:"avatar_url"`
+	Bio          string     `json:"bio"`
+	Role         string     `json:"role" enum:"user,moderator,admin"`
+	JoinedAt     time.Time  `json:"joined_at"`
+	PostCount    int        `json:"post_count"`
+	CommentCount int        `json:"comment_count"`
+	Reputation   int        `json:"reputation"`
+	TrustLevel   int        `json:"trust_level"`
+	Badges       []apiBadge `json:"badges"`
+}
+
+// Connected user in the API
+type apiMe struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Role       string `json:"role" enum:"user,moderator,admin"`
+	TrustLevel int    `json:"trust_level"`
+}
+
+// Body of a new post
+type apiNewPost struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Categories []string `json:"categories" description:"IDs of the categories, at least one"`
+	Tags       []string `json:"tags,omitempty"`
+	PublishAt  string   `json:"publish_at,omitempty" description:"Publication date in the future, the post is published now without it" format:"date-time"`
+}
+
+// New post, it is only visible once published
+type apiPostCreated struct {
+	ID    string `json:"id"`
+	State string `json:"state" description:"Held posts wait for the review of a moderator" enum:"published,scheduled,held"`
+}
+
+// Body of a new comment
+type apiNewComment struct {
+	Content string `json:"content"`
+}
+
+// Body of a reaction
+type apiReaction struct {
+	Type string `json:"type" enum:"like,dislike"`
+}
+
+// Reactions to a post or comment after a change
+type apiReactions struct {
+	Likes    int     `json:"likes"`
+	Dislikes int     `json:"dislikes"`
+	Reaction *string `json:"reaction" enum:"like,dislike"`
+}
+
+// Columns of a post of the API, the ID of the caller is given once for their reaction
+const apiPostColumns = `p.id, p.title, p.content, p.created_at, COALESCE(u.username, 'deleted user'), COALESCE(u.avatar_path, ''),
+	` + postFlags + `, EXISTS (SELECT 1 FROM post_pins pp WHERE pp.post_id = p.id AND pp.scope = ''), ` + qaPost + `,
+	COALESCE(p.accepted_comment_id, ''), (SELECT COUNT(*) FROM comments ac WHERE ac.post_id = p.id),
+	(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.type = 'like'),
+	(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.type = 'dislike'),
+	COALESCE((SELECT l.type FROM likes l WHERE l.post_id = p.id AND l.user_id = ?), '')`
+
+// Columns of a comment of the API, the ID of the caller is given once for their reaction
+const apiCommentColumns = `c.id, c.post_id, COALESCE(u.username, 'deleted user'), COALESCE(u.avatar_path, ''), c.content, c.created_at,
+	c.id = COALESCE(p.accepted_comment_id, ''),
+	(SELECT COUNT(*) FROM likes l WHERE l.comment_id = c.id AND l.type = 'like'),
+	(SELECT COUNT(*) FROM likes l WHERE l.comment_id = c.id AND l.type = 'dislike'),
+	COALESCE((SELECT l.type FROM likes l WHERE l.comment_id = c.id AND l.user_id = ?), '')`
+
+// Function to return the public address of an avatar, empty without one
+func avatarURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	return publicURL() + "/" + path
+}
+
+// Function to return a text as a nullable value of the API
+func nullable(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// Function to load a page of the visible posts matching the conditions, the newest first
+func loadAPIPosts(caller apiCaller, conditions []string, args []any, page apiPage) ([]apiPost, bool, error) {
+	conditions = append([]string{"p.deleted_at IS NULL", "p.scheduled_at IS NULL"}, conditions...)
+	// The posts of the users blocked or muted by the caller are hidden, as on the site
+	if caller.UserID != "" {
+		conditions = append(conditions, "p.user_id NOT IN "+hiddenUsers)
+		args = append(args, caller.UserID)
+	}
+	// One more post tells whether there is a next page
+	rows, err := auth.DB.Query(`SELECT `+apiPostColumns+` FROM posts p LEFT JOIN users u ON u.id = p.user_id
+		WHERE `+strings.Join(conditions, " AND ")+` ORDER BY p.created_at DESC, p.id LIMIT ? OFFSET ?`,
+		append(append([]any{caller.UserID}, args...), page.Limit+1, page.Offset)...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	posts := []apiPost{}
+	var postIDs []string
+	for rows.Next() {
+		var post apiPost
+		var avatarPath, acceptedCommentID, reaction string
+		var locked, archived bool
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CreatedAt, &post.Author.Username, &avatarPath,
+			&locked, &archived, &post.Pinned, &post.Question, &acceptedCommentID, &post.CommentCount,
+			&post.Likes, &post.Dislikes, &reaction); err != nil {
+			return nil, false, err
+		}
+		post.Author.AvatarURL = avatarURL(avatarPath)
+		post.AcceptedCommentID = nullable(acceptedCommentID)
+		post.Reaction = nullable(reaction)
+		post.Status = "open"
+		if archived {
+			post.Status = "archived"
+		} else if locked {
+			post.Status = "locked"
+		}
+		posts = append(posts, post)
+		postIDs = append(postIDs, post.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	rows.Close()
+	hasMore := len(posts) > page.Limit
+	if hasMore {
+		posts, postIDs = posts[:page.Limit], postIDs[:page.Limit]
+	}
+	// The categories and tags of every post at once
+	categories, err := loadAPIPostCategories(postIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	tags := loadPostTags(postIDs)
+	for i := range posts {
+		posts[i].Categories = append([]apiCategoryRef{}, categories[posts[i].ID]...)
+		posts[i].Tags = []string{}
+		for _, tag := range tags[posts[i].ID] {
+			posts[i].Tags = append(posts[i].Tags, tag.Name)
+		}
+	}
+	return posts, hasMore, nil
+}
+
+// Function to load the categories of posts by post ID
+func loadAPIPostCategories(postIDs []string) (map[string][]apiCategoryRef, error) {
+	result := map[string][]apiCategoryRef{}
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+	args := make([]any, len(postIDs))
+	for i, postID := range postIDs {
+		args[i] = postID
+	}
+	rows, err := auth.DB.Query(`SELECT pc.post_id, CAST(c.id AS TEXT), c.name FROM post_categories pc
+		JOIN categories c ON CAST(c.id AS TEXT) = pc.category_id
+		WHERE pc.post_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")+`) ORDER BY c.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID string
+		var category apiCategoryRef
+		if err := rows.Scan(&postID, &category.ID, &category.Name); err != nil {
+			return nil, err
+		}
+		result[postID] = append(result[postID], category)
+	}
+	return result, rows.Err()
+}
+
+// Function to load a page of the comments matching the conditions, the oldest first
+func loadAPIComments(caller apiCaller, conditions []string, args []any, page apiPage) ([]apiComment, bool, error) {
+	if caller.UserID != "" {
+		conditions = append(conditions, "c.user_id NOT IN "+hiddenUsers)
+		args = append(args, caller.UserID)
+	}
+	rows, err := auth.DB.Query(`SELECT `+apiCommentColumns+` FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		JOIN posts p ON p.id = c.post_id
+		WHERE `+strings.Join(conditions, " AND ")+` ORDER BY c.created_at, c.id LIMIT ? OFFSET ?`,
+		append(append([]any{caller.UserID}, args...), page.Limit+1, page.Offset)...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	comments := []apiComment{}
+	for rows.Next() {
+		var comment apiComment
+		var avatarPath, reaction string
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.Author.Username, &avatarPath, &comment.Content, &comment.CreatedAt,
+			&comment.Accepted, &comment.Likes, &comment.Dislikes, &reaction); err != nil {
+			return nil, false, err
+		}
+		comment.Author.AvatarURL = avatarURL(avatarPath)
+		comment.Reaction = nullable(reaction)
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	hasMore := len(comments) > page.Limit
+	if hasMore {
+		comments = comments[:page.Limit]
+	}
+	return comments, hasMore, nil
+}
+
+// Function to check that a post is visible, answering 404 otherwise
+func apiVisiblePost(w http.ResponseWriter, postID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(subscriptionTargets["post"], postID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "Post not found")
+		return false
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving post")
+		return false
+	}
+	return true
+}
+
+// Function to list the posts, filtered by category, tag or author
+func apiListPosts(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	page, ok := apiPageOf(w, r)
+	if !ok {
+		return
+	}
+	var conditions []string
+	var args []any
+	query := r.URL.Query()
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		// The posts of the subcategories are listed with those of the category
+		conditions = append(conditions, "p.id IN (SELECT post_id FROM post_categories WHERE category_id IN "+categoryTree+")")
+		args = append(args, categoryID)
+	}
+	if name := query.Get("tag"); name != "" {
+		// An unknown tag matches no post
+		tag, _ := resolveTag(normalizeTag(name))
+		conditions = append(conditions, "p.id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)")
+		args = append(args, tag.ID)
+	}
+	if author := query.Get("author"); author != "" {
+		conditions = append(conditions, "u.username = ?")
+		args = append(args, author)
+	}
+	posts, hasMore, err := loadAPIPosts(caller, conditions, args, page)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving posts")
+		return
+	}
+	page.HasMore = hasMore
+	writeAPIList(w, posts, page)
+}
+
+// Function to return a post
+func apiGetPost(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	posts, _, err := loadAPIPosts(caller, []string{"p.id = ?"}, []any{r.PathValue("id")}, apiPage{Limit: 1})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving post")
+		return
+	}
+	if len(posts) == 0 {
+		writeAPIError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	writeAPIData(w, http.StatusOK, posts[0])
+}
+
+// Function to create a post, held for review or scheduled like on the site
+func apiCreatePost(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	var body apiNewPost
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+	publishAt, err := parsePublishAt(body.PublishAt)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	postID, state, status, err := createPost(caller.UserID, caller.Role, postInput{
+		Title:      strings.TrimSpace(body.Title),
+		Content:    body.Content,
+		Categories: body.Categories,
+		Tags:       strings.Join(body.Tags, ","),
+		PublishAt:  publishAt,
+	})
+	if err != nil {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/posts/"+postID)
+	writeAPIData(w, http.StatusCreated, apiPostCreated{ID: postID, State: state})
+}
+
+// Function to move a post of the caller to the trash
+func apiDeletePost(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	if status, err := deleteOwnPost(caller.UserID, r.PathValue("id")); err != nil {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function to list the comments of a post, the oldest first
+func apiListComments(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	page, ok := apiPageOf(w, r)
+	if !ok {
+		return
+	}
+	postID := r.PathValue("id")
+	if !apiVisiblePost(w, postID) {
+		return
+	}
+	comments, hasMore, err := loadAPIComments(caller, []string{"c.post_id = ?"}, []any{postID}, page)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving comments")
+		return
+	}
+	page.HasMore = hasMore
+	writeAPIList(w, comments, page)
+}
+
+// Function to comment a post
+func apiCreateComment(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	var body apiNewComment
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		writeAPIError(w, http.StatusBadRequest, "Content is required")
+		return
+	}
+	commentID, status, err := createComment(caller.UserID, caller.Role, r.PathValue("id"), body.Content, nil)
+	if err != nil {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	comments, _, err := loadAPIComments(caller, []string{"c.id = ?"}, []any{commentID}, apiPage{Limit: 1})
+	if err != nil || len(comments) == 0 {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving comment")
+		return
+	}
+	writeAPIData(w, http.StatusCreated, comments[0])
+}
+
+// Function to delete a comment of the caller
+func apiDeleteComment(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	if status, err := deleteOwnComment(caller.UserID, r.PathValue("id")); err != nil {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function to build the handlers setting and removing the reaction of the caller to a post or comment
+func apiReactionHandlers(contentType string) (set, remove func(http.ResponseWriter, *http.Request, apiCaller)) {
+	column := contentType + "_id"
+	answer := func(w http.ResponseWriter, caller apiCaller, contentID, typeLike string) {
+		if status, err := react(caller.UserID, contentType, contentID, typeLike); err != nil {
+			writeAPIError(w, status, err.Error())
+			return
+		}
+		var reactions apiReactions
+		auth.DB.QueryRow(`SELECT COUNT(CASE WHEN type = 'like' THEN 1 END), COUNT(CASE WHEN type = 'dislike' THEN 1 END)
+			FROM likes WHERE `+column+` = ?`, contentID).Scan(&reactions.Likes, &reactions.Dislikes)
+		reactions.Reaction = nullable(typeLike)
+		writeAPIData(w, http.StatusOK, reactions)
+	}
+	set = func(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+		var body apiReaction
+		if !decodeAPIBody(w, r, &body) {
+			return
+		}
+		if body.Type != "like" && body.Type != "dislike" {
+			writeAPIError(w, http.StatusBadRequest, "The type must be like or dislike")
+			return
+		}
+		answer(w, caller, r.PathValue("id"), body.Type)
+	}
+	remove = func(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+		answer(w, caller, r.PathValue("id"), "")
+	}
+	return set, remove
+}
+
+// Function to list the categories, each one after its parent
+func apiListCategories(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	all, err := loadCategories()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving categories")
+		return
+	}
+	categories := []apiCategory{}
+	for _, category := range all {
+		categories = append(categories, apiCategory{
+			ID:          category.ID,
+			Name:        category.Name,
+			Slug:        category.Slug,
+			Description: category.Description,
+			Icon:        category.Icon,
+			ParentID:    nullable(category.ParentID),
+			Depth:       category.Depth,
+			Status:      category.Status,
+			PostRole:    category.PostRole,
+			Questions:   category.QA,
+		})
+	}
+	writeAPIData(w, http.StatusOK, categories)
+}
+
+// Function to list the tags, the most used first, whose name or a synonym starts with q
+func apiListTags(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	page, ok := apiPageOf(w, r)
+	if !ok {
+		return
+	}
+	// One more tag than the page tells whether another page follows
+	all, err := listTags(r.URL.Query().Get("q"), page.Limit+1, page.Offset)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving tags")
+		return
+	}
+	if len(all) > page.Limit {
+		all, page.HasMore = all[:page.Limit], true
+	}
+	tags := []apiTag{}
+	for _, tag := range all {
+		tags = append(tags, apiTag{Name: tag.Name, PostCount: tag.PostCount, Synonyms: append([]string{}, tag.Synonyms...)})
+	}
+	writeAPIList(w, tags, page)
+}
+
+// Function to return the public profile of a user
+func apiGetUser(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	var user apiUser
+	var userID, avatarPath string
+	err := auth.DB.QueryRow("SELECT id, username, COALESCE(avatar_path, ''), COALESCE(bio, ''), COALESCE(role, 'user'), created_at FROM users WHERE username = ?",
+		r.PathValue("username")).Scan(&userID, &user.Username, &avatarPath, &user.Bio, &user.Role, &user.JoinedAt)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving user")
+		return
+	}
+	user.AvatarURL = avatarURL(avatarPath)
+	auth.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id = ? AND deleted_at IS NULL AND scheduled_at IS NULL", userID).Scan(&user.PostCount)
+	auth.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE user_id = ?", userID).Scan(&user.CommentCount)
+	user.Reputation = userReputation(userID)
+	user.TrustLevel = auth.TrustLevelOf(userID, user.Role)
+	user.Badges = []apiBadge{}
+	for _, badge := range userBadges(userID) {
+		user.Badges = append(user.Badges, apiBadge(badge))
+	}
+	writeAPIData(w, http.StatusOK, user)
+}
+
+// Function to return the connected user
+func apiGetMe(w http.ResponseWriter, r *http.Request, caller apiCaller) {
+	me := apiMe{ID: caller.UserID}
+	err := auth.DB.QueryRow("SELECT username, email, COALESCE(role, 'user') FROM users WHERE id = ?", caller.UserID).Scan(&me.Username, &me.Email, &me.Role)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "Error retrieving user")
+		return
+	}
+	me.TrustLevel = auth.TrustLevelOf(caller.UserID, me.Role)
+	writeAPIData(w, http.StatusOK, me)
+}
+
+// Function to list the routes of the API
+func apiRoutes() []apiRoute {
+	pageParams := []apiParam{
+		{Name: "limit", Type: "integer", Description: "Size of the page, 20 by default and 100 at most"},
+		{Name: "offset", Type: "integer", Description: "Position of the first item"},
+	}
+	setPostReaction, removePostReaction := apiReactionHandlers("post")
+	setCommentReaction, removeCommentReaction := apiReactionHandlers("comment")
+	return []apiRoute{
+		{Method: http.MethodGet, Path: "/me", Tag: "users", Summary: "Get the connected user", Auth: true,
+			Response: apiMe{}, Status: http.StatusOK, Handle: apiGetMe},
+		{Method: http.MethodGet, Path: "/posts", Tag: "posts", Summary: "List the published posts, the newest first",
+			Query: append([]apiParam{
+				{Name: "category_id", Type: "string", Description: "Posts of the category and of its subcategories"},
+				{Name: "tag", Type: "string", Description: "Posts with the tag, given by name or synonym"},
+				{Name: "author", Type: "string", Description: "Posts of the user with this username"},
+			}, pageParams...),
+			Response: apiPost{}, List: true, Status: http.StatusOK, Handle: apiListPosts},
+		{Method: http.MethodPost, Path: "/posts", Tag: "posts", Summary: "Create a post", Auth: true,
+			Request: apiNewPost{}, Response: apiPostCreated{}, Status: http.StatusCreated, Handle: apiCreatePost},
+		{Method: http.MethodGet, Path: "/posts/{id}", Tag: "posts", Summary: "Get a published post",
+			Response: apiPost{}, Status: http.StatusOK, Handle: apiGetPost},
+		{Method: http.MethodDelete, Path: "/posts/{id}", Tag: "posts", Summary: "Move a post of the connected user to the trash", Auth: true,
+			Status: http.StatusNoContent, Handle: apiDeletePost},
+		{Method: http.MethodPut, Path: "/posts/{id}/reaction", Tag: "posts", Summary: "Like or dislike a post", Auth: true,
+			Request: apiReaction{}, Response: apiReactions{}, Status: http.StatusOK, Handle: setPostReaction},
+		{Method: http.MethodDelete, Path: "/posts/{id}/reaction", Tag: "posts", Summary: "Remove the reaction to a post", Auth: true,
+			Response: apiReactions{}, Status: http.StatusOK, Handle: removePostReaction},
+		{Method: http.MethodGet, Path: "/posts/{id}/comments", Tag: "comments", Summary: "List the comments of a post, the oldest first",
+			Query: pageParams, Response: apiComment{}, List: true, Status: http.StatusOK, Handle: apiListComments},
+		{Method: http.MethodPost, Path: "/posts/{id}/comments", Tag: "comments", Summary: "Comment a post", Auth: true,
+			Request: apiNewComment{}, Response: apiComment{}, Status: http.StatusCreated, Handle: apiCreateComment},
+		{Method: http.MethodDelete, Path: "/comments/{id}", Tag: "comments", Summary: "Delete a comment of the connected user", Auth: true,
+			Status: http.StatusNoContent, Handle: apiDeleteComment},
+		{Method: http.MethodPut, Path: "/comments/{id}/reaction", Tag: "comments", Summary: "Like or dislike a comment", Auth: true,
+			Request: apiReaction{}, Response: apiReactions{}, Status: http.StatusOK, Handle: setCommentReaction},
+		{Method: http.MethodDelete, Path: "/comments/{id}/reaction", Tag: "comments", Summary: "Remove the reaction to a comment", Auth: true,
+			Response: apiReactions{}, Status: http.StatusOK, Handle: removeCommentReaction},
+		{Method: http.MethodGet, Path: "/categories", Tag: "categories", Summary: "List the categories, each one after its parent",
+			Response: []apiCategory{}, Status: http.StatusOK, Handle: apiListCategories},
+		{Method: http.MethodGet, Path: "/tags", Tag: "tags", Summary: "List the tags, the most used first",
+			Query: append([]apiParam{
+				{Name: "q", Type: "string", Description: "Tags whose name or a synonym starts with this text"},
+			}, pageParams...),
+			Response: apiTag{}, List: true, Status: http.StatusOK, Handle: apiListTags},
+		{Method: http.MethodGet, Path: "/users/{username}", Tag: "users", Summary: "Get the public profile of a user",
+			Response: apiUser{}, Status: http.StatusOK, Handle: apiGetUser},
+	}
+}
@@ -0,0 +1,394 @@
+package forum
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Forum/auth"
+	"Forum/storage"
+
+	"github.com/google/uuid"
+)
+
+// Maximum number of files attached to one post or comment
+const maxAttachmentsPerItem = 10
+
+// Default storage quota of a user (200mb), configurable with UPLOAD_QUOTA_BYTES
+const defaultUploadQuota = 200 * 1024 * 1024
+
+// File attached to a post, a comment or a private message
+type Attachment struct {
+	ID         string            `json:"id"`
+	Path       string            `json:"path"`
+	Name       string            `json:"name"`
+	MimeType   string            `json:"mime_type"`
+	Size       int64             `json:"size"`
+	Width      int               `json:"width,omitempty"`
+	Height     int               `json:"height,omitempty"`
+	Position   int               `json:"position"`
+	Thumbnails map[string]string `json:"thumbnails,omitempty"`
+	URL        string            `json:"url,omitempty"` // signed URL of a private file
+}
+
+// Entry of the allow-list of attachment types
+type AttachmentType struct {
+	MimeType  string `json:"mime_type"`
+	Extension string `json:"extension"`
+	MaxSize   int64  `json:"max_size"`
+}
+
+// Function to return the storage quota of a user
+func uploadQuota() int64 {
+	quota, err := strconv.ParseInt(os.Getenv("UPLOAD_QUOTA_BYTES"), 10, 64)
+	if err != nil || quota <= 0 {
+		return defaultUploadQuota
+	}
+	return quota
+}
+
+// Function to return the space used by the attachments of a user
+func usedStorage(userID string) int64 {
+	var used int64
+	auth.DB.QueryRow("SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?", userID).Scan(&used)
+	return used
+}
+
+// Function to retrieves an allowed attachment type
+func attachmentType(mimeType string) (AttachmentType, bool) {
+	t := AttachmentType{MimeType: mimeType}
+	err := auth.DB.QueryRow("SELECT extension, max_size FROM attachment_types WHERE mime_type = ?", mimeType).Scan(&t.Extension, &t.MaxSize)
+	return t, err == nil
+}
+
+// Function to collect the uploaded files of a form, the legacy "image" field first
+func uploadedFiles(r *http.Request) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	var files []*multipart.FileHeader
+	files = append(files, r.MultipartForm.File["image"]...)
+	files = append(files, r.MultipartForm.File["attachments"]...)
+	return files
+}
+
+// Function to sniff, check and store one uploaded file, a private one in its own blob.
+// pending is the size of the files of the same request, not recorded yet.
+func saveAttachment(userID string, fileHeader *multipart.FileHeader, position int, private bool, pending int64) (Attachment, error) {
+	attachment := Attachment{
+		ID:       uuid.New().String(),
+		Name:     filepath.Base(fileHeader.Filename),
+		Position: position,
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return attachment, err
+	}
+	defer file.Close()
+
+	// Trust the magic bytes, not the file name
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	allowed, ok := attachmentType(mimeType)
+	if !ok {
+		return attachment, fmt.Errorf("unsupported file type")
+	}
+	if fileHeader.Size > allowed.MaxSize {
+		return attachment, fmt.Errorf("file too large for type %s", mimeType)
+	}
+	if usedStorage(userID)+pending+fileHeader.Size > uploadQuota() {
+		return attachment, fmt.Errorf("storage quota exceeded")
+	}
+	attachment.MimeType = mimeType
+
+	// Images go through the processing pipeline
+	if _, isImage := allowedImageTypes[mimeType]; isImage {
+		image, err := saveImage(fileHeader, private)
+		if err != nil {
+			return attachment, err
+		}
+		attachment.Path, attachment.Size = image.Path, image.Size
+		attachment.Width, attachment.Height = image.Width, image.Height
+		return attachment, nil
+	}
+
+	// Other files are stored as they are, a refused blob is left to the garbage collector
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return attachment, err
+	}
+	key, size, err := storage.Save(io.LimitReader(file, allowed.MaxSize+1), allowed.Extension, mimeType, private)
+	if err != nil {
+		return attachment, fmt.Errorf("failed to store file: %v", err)
+	}
+	if size > allowed.MaxSize {
+		return attachment, fmt.Errorf("file too large for type %s", mimeType)
+	}
+	attachment.Path, attachment.Size = storage.PathFromKey(key), size
+	return attachment, nil
+}
+
+// Function to store the files of a request for an owner column ("post_id", "comment_id" or
+// "message_id"), nothing is kept if one of them is refused
+func saveAttachments(userID, column string, files []*multipart.FileHeader) ([]Attachment, error) {
+	if len(files) > maxAttachmentsPerItem {
+		return nil, fmt.Errorf("too many attachments (maximum %d)", maxAttachmentsPerItem)
+	}
+	var saved []Attachment
+	// The files are recorded together at the end, the quota counts those already saved
+	var pending int64
+	for i, fileHeader := range files {
+		attachment, err := saveAttachment(userID, fileHeader, i, privateAttachmentColumns[column], pending)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(fileHeader.Filename), err)
+		}
+		pending += attachment.Size
+		saved = append(saved, attachment)
+	}
+	return saved, nil
+}
+
+// Owners whose attachments are private, they are only served through signed URLs
+var privateAttachmentColumns = map[string]bool{"message_id": true}
+
+// Function to record attachments for a post, a comment or a message ("post_id", "comment_id" or "message_id")
+func insertAttachments(userID, column, ownerID string, attachments []Attachment) error {
+	query := fmt.Sprintf(`INSERT INTO attachments (id, user_id, %s, file_path, original_name, mime_type, size, width, height, position, private, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, column)
+	for _, a := range attachments {
+		_, err := auth.DB.Exec(query, a.ID, userID, ownerID, a.Path, a.Name, a.MimeType, a.Size, a.Width, a.Height, a.Position, privateAttachmentColumns[column], time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to load the attachments of several posts or comments, grouped by owner ID
+func loadAttachments(column string, ids []string) map[string][]Attachment {
+	result := map[string][]Attachment{}
+	if len(ids) == 0 {
+		return result
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := fmt.Sprintf(`SELECT %s, id, file_path, original_name, mime_type, size, width, height, position
+		FROM attachments WHERE %s IN (%s) ORDER BY position`, column, column, placeholders)
+	rows, err := auth.DB.Query(query, args...)
+	if err != nil {
+		return result
+	}
+	var attachments []Attachment
+	var owners []string
+	for rows.Next() {
+		var owner string
+		var a Attachment
+		if err := rows.Scan(&owner, &a.ID, &a.Path, &a.Name, &a.MimeType, &a.Size, &a.Width, &a.Height, &a.Position); err != nil {
+			continue
+		}
+		owners = append(owners, owner)
+		attachments = append(attachments, a)
+	}
+	rows.Close()
+	for i, a := range attachments {
+		if a.Width > 0 {
+			a.Thumbnails = loadThumbnails(a.Path)
+		}
+		result[owners[i]] = append(result[owners[i]], a)
+	}
+	return result
+}
+
+// Function to return the first image of a list of attachments
+func firstImage(attachments []Attachment) (Attachment, bool) {
+	for _, a := range attachments {
+		if _, ok := allowedImageTypes[a.MimeType]; ok {
+			return a, true
+		}
+	}
+	return Attachment{}, false
+}
+
+// Function to show the storage used by the connected user
+func GetStorageQuota(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"used": usedStorage(userID), "quota": uploadQuota()})
+}
+
+// Function to retrieves the allow-list of attachment types
+func GetAttachmentTypes(w http.ResponseWriter, r *http.Request) {
+	rows, err := auth.DB.Query("SELECT mime_type, extension, max_size FROM attachment_types ORDER BY mime_type")
+	if err != nil {
+		http.Error(w, "Error retrieving attachment types", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	types := []AttachmentType{}
+	for rows.Next() {
+		var t AttachmentType
+		if err := rows.Scan(&t.MimeType, &t.Extension, &t.MaxSize); err != nil {
+			http.Error(w, "Error reading attachment types", http.StatusInternalServerError)
+			return
+		}
+		types = append(types, t)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types)
+}
+
+// Function to allows the admin to add or update an attachment type
+func SaveAttachmentType(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	mimeType := strings.ToLower(strings.TrimSpace(r.FormValue("mime_type")))
+	extension := strings.ToLower(strings.TrimSpace(r.FormValue("extension")))
+	maxSize, err := strconv.ParseInt(r.FormValue("max_size"), 10, 64)
+	if mimeType == "" || err != nil || maxSize <= 0 {
+		http.Error(w, "mime_type, extension and a positive max_size are required", http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(extension, ".") || strings.ContainsAny(extension, "/\\") {
+		http.Error(w, "Invalid extension", http.StatusBadRequest)
+		return
+	}
+	// Images can never be bigger than what the pipeline accepts
+	if _, isImage := allowedImageTypes[mimeType]; isImage && maxSize > maxImageSize {
+		maxSize = maxImageSize
+	}
+	_, err = auth.DB.Exec(`INSERT INTO attachment_types (mime_type, extension, max_size) VALUES (?, ?, ?)
+		ON CONFLICT(mime_type) DO UPDATE SET extension = excluded.extension, max_size = excluded.max_size`, mimeType, extension, maxSize)
+	if err != nil {
+		http.Error(w, "Error saving attachment type", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attachment type saved successfully"})
+}
+
+// Function to allows the admin to remove an attachment type from the allow-list
+func DeleteAttachmentType(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	mimeType := r.FormValue("mime_type")
+	if mimeType == "" {
+		http.Error(w, "mime_type is required", http.StatusBadRequest)
+		return
+	}
+	_, err := auth.DB.Exec("DELETE FROM attachment_types WHERE mime_type = ?", mimeType)
+	if err != nil {
+		http.Error(w, "Error deleting attachment type", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attachment type deleted successfully"})
+}
+
+// What the attachments referencing an upload say about it
+type uploadInfo struct {
+	Found    bool
+	Name     string
+	MimeType string
+	Private  bool
+}
+
+// Function to find the download name, type and visibility of an upload, thumbnails follow their image
+func uploadAccess(path string) uploadInfo {
+	var info uploadInfo
+	var imagePath string
+	if auth.DB.QueryRow("SELECT image_path FROM image_thumbnails WHERE thumb_path = ?", path).Scan(&imagePath) == nil {
+		path = imagePath
+	}
+	// The private attachments have their own blobs, a blob referenced by a private one
+	// before they had is private too
+	var count, private int
+	err := auth.DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN private = 1 THEN 1 ELSE 0 END), 0), COALESCE(MIN(original_name), ''), COALESCE(MIN(mime_type), '')
+		FROM attachments WHERE file_path = ?`, path).Scan(&count, &private, &info.Name, &info.MimeType)
+	if err != nil || count == 0 {
+		return info
+	}
+	info.Found = true
+	info.Private = private > 0
+	return info
+}
+
+// Function to check whether a user may read an attachment, the members of a
+// conversation read the files of its messages
+func canAccessAttachment(userID, ownerID, messageID string, private bool) bool {
+	if !private || (userID != "" && userID == ownerID) {
+		return true
+	}
+	return userID != "" && messageID != "" && isMessageMember(messageID, userID)
+}
+
+// Function to replace the paths of a private attachment by signed URLs
+func signAttachment(a Attachment) Attachment {
+	ttl := storage.SignedURLTTL()
+	if url, err := storage.Blobs.SignedURL(storage.KeyFromPath(a.Path), ttl); err == nil {
+		a.URL = url
+	}
+	thumbnails := map[string]string{}
+	for size, path := range a.Thumbnails {
+		if url, err := storage.Blobs.SignedURL(storage.KeyFromPath(path), ttl); err == nil {
+			thumbnails[size] = url
+		}
+	}
+	a.Thumbnails = thumbnails
+	return a
+}
+
+// Function to return a time-limited URL of an attachment
+func GetAttachmentURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	attachmentID := r.URL.Query().Get("id")
+	if attachmentID == "" {
+		http.Error(w, "Attachment ID is required", http.StatusBadRequest)
+		return
+	}
+	var ownerID, messageID, path string
+	var private bool
+	err := auth.DB.QueryRow("SELECT user_id, COALESCE(message_id, ''), file_path, private FROM attachments WHERE id = ?", attachmentID).Scan(&ownerID, &messageID, &path, &private)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving attachment", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := auth.GetUserFromSession(r)
+	if !canAccessAttachment(userID, ownerID, messageID, private) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	ttl := storage.SignedURLTTL()
+	url, err := storage.Blobs.SignedURL(storage.KeyFromPath(path), ttl)
+	if err != nil {
+		http.Error(w, "Error signing URL", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"url": url, "expires_at": time.Now().Add(ttl)})
+}
@@ -0,0 +1,268 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the badges
+const (
+	maxBadgeNameLength        = 50
+	maxBadgeDescriptionLength = 200
+	maxBadgeIconLength        = 16
+)
+
+// Rules awarding a badge: each one gives the condition a user u must meet for a threshold
+var badgeRules = map[string]func(threshold int) (string, any){
+	"reputation": func(threshold int) (string, any) {
+		return reputationOf("u.id") + " >= ?", threshold
+	},
+	"posts": func(threshold int) (string, any) {
+		return "(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL) >= ?", threshold
+	},
+	"comments": func(threshold int) (string, any) {
+		return "(SELECT COUNT(*) FROM comments c WHERE c.user_id = u.id) >= ?", threshold
+	},
+	"likes_received": func(threshold int) (string, any) {
+		return `(SELECT COUNT(*) FROM likes l
+			LEFT JOIN posts lp ON l.post_id = lp.id
+			LEFT JOIN comments lc ON l.comment_id = lc.id
+			WHERE l.type = 'like' AND (lp.user_id = u.id OR lc.user_id = u.id) AND l.user_id != u.id) >= ?`, threshold
+	},
+	"accepted_answers": func(threshold int) (string, any) {
+		return `(SELECT COUNT(*) FROM posts ap JOIN comments ac ON ac.id = ap.accepted_comment_id
+			WHERE ac.user_id = u.id AND ap.user_id != ac.user_id AND ap.deleted_at IS NULL) >= ?`, threshold
+	},
+	"trust_level": func(threshold int) (string, any) {
+		return "COALESCE(u.trust_level, 0) >= ?", threshold
+	},
+	"account_days": func(threshold int) (string, any) {
+		return "u.created_at <= ?", time.Now().AddDate(0, 0, -threshold)
+	},
+}
+
+// Badge awarded to the users who meet its rule
+type Badge struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	Rule        string    `json:"rule"`
+	Threshold   int       `json:"threshold"`
+	Holders     int       `json:"holders"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Badge of a user
+type UserBadge struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	AwardedAt   time.Time `json:"awarded_at"`
+}
+
+// Function to award a badge to the users who meet its rule and do not have it yet
+func awardBadge(badge Badge) {
+	rule, ok := badgeRules[badge.Rule]
+	if !ok {
+		return
+	}
+	condition, arg := rule(badge.Threshold)
+	rows, err := auth.DB.Query(`SELECT u.id FROM users u WHERE `+condition+`
+		AND NOT EXISTS (SELECT 1 FROM user_badges ub WHERE ub.user_id = u.id AND ub.badge_id = ?)`, arg, badge.ID)
+	if err != nil {
+		log.Println("Error evaluating badge rule:", err)
+		return
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if rows.Scan(&userID) == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	rows.Close()
+	for _, userID := range userIDs {
+		result, err := auth.DB.Exec("INSERT INTO user_badges (user_id, badge_id, awarded_at) VALUES (?, ?, ?) ON CONFLICT(user_id, badge_id) DO NOTHING",
+			userID, badge.ID, time.Now())
+		if err != nil {
+			log.Println("Error awarding badge:", err)
+			continue
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			continue
+		}
+		CreateNotification(NotificationEvent{
+			UserID:     userID,
+			Action:     "achievement",
+			TargetType: "user",
+			TargetID:   userID,
+			Template:   "badge_awarded",
+			Params:     map[string]string{"badge": badge.Name, "icon": badge.Icon},
+		})
+	}
+}
+
+// Function to award all the badges, a badge once awarded is kept
+func awardBadges() {
+	badges, err := loadBadges()
+	if err != nil {
+		log.Println("Error loading badges:", err)
+		return
+	}
+	for _, badge := range badges {
+		awardBadge(badge)
+	}
+}
+
+// Function to load the badges with the number of users holding them
+func loadBadges() ([]Badge, error) {
+	rows, err := auth.DB.Query(`SELECT b.id, b.name, b.description, b.icon, b.rule, b.threshold, b.created_at,
+		(SELECT COUNT(*) FROM user_badges ub WHERE ub.badge_id = b.id)
+		FROM badges b ORDER BY b.rule, b.threshold, b.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	badges := []Badge{}
+	for rows.Next() {
+		var badge Badge
+		if err := rows.Scan(&badge.ID, &badge.Name, &badge.Description, &badge.Icon, &badge.Rule, &badge.Threshold, &badge.CreatedAt, &badge.Holders); err != nil {
+			return nil, err
+		}
+		badges = append(badges, badge)
+	}
+	return badges, nil
+}
+
+// Function to load the badges of a user, the most recent first
+func userBadges(userID string) []UserBadge {
+	badges := []UserBadge{}
+	rows, err := auth.DB.Query(`SELECT b.name, b.description, b.icon, ub.awarded_at FROM user_badges ub
+		JOIN badges b ON b.id = ub.badge_id WHERE ub.user_id = ? ORDER BY ub.awarded_at DESC`, userID)
+	if err != nil {
+		return badges
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var badge UserBadge
+		if rows.Scan(&badge.Name, &badge.Description, &badge.Icon, &badge.AwardedAt) == nil {
+			badges = append(badges, badge)
+		}
+	}
+	return badges
+}
+
+// Function to list the badges and their rules
+func GetBadges(w http.ResponseWriter, r *http.Request) {
+	badges, err := loadBadges()
+	if err != nil {
+		http.Error(w, "Error retrieving badges", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(badges)
+}
+
+// Function to create a badge, or update it with an id, then award it to the users who
+// already meet its rule. The users holding it keep it when its rule changes.
+func SaveBadge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	badge := Badge{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Icon:        strings.TrimSpace(r.FormValue("icon")),
+		Rule:        r.FormValue("rule"),
+	}
+	if badge.Name == "" || len(badge.Name) > maxBadgeNameLength {
+		http.Error(w, "The name of a badge is required and limited to 50 characters", http.StatusBadRequest)
+		return
+	}
+	if len(badge.Description) > maxBadgeDescriptionLength || len(badge.Icon) > maxBadgeIconLength {
+		http.Error(w, "Description or icon too long", http.StatusBadRequest)
+		return
+	}
+	if _, ok := badgeRules[badge.Rule]; !ok {
+		http.Error(w, "Unknown badge rule", http.StatusBadRequest)
+		return
+	}
+	threshold, err := strconv.Atoi(r.FormValue("threshold"))
+	if err != nil || threshold < 0 {
+		http.Error(w, "Invalid threshold", http.StatusBadRequest)
+		return
+	}
+	badge.Threshold = threshold
+	if id := r.FormValue("id"); id != "" {
+		badge.ID, err = strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid badge ID", http.StatusBadRequest)
+			return
+		}
+		result, err := auth.DB.Exec("UPDATE badges SET name = ?, description = ?, icon = ?, rule = ?, threshold = ? WHERE id = ?",
+			badge.Name, badge.Description, badge.Icon, badge.Rule, badge.Threshold, badge.ID)
+		if err != nil {
+			http.Error(w, "A badge with this name already exists", http.StatusConflict)
+			return
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			http.Error(w, "Badge not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		result, err := auth.DB.Exec("INSERT INTO badges (name, description, icon, rule, threshold, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			badge.Name, badge.Description, badge.Icon, badge.Rule, badge.Threshold, time.Now())
+		if err != nil {
+			http.Error(w, "A badge with this name already exists", http.StatusConflict)
+			return
+		}
+		badge.ID, _ = result.LastInsertId()
+	}
+	awardBadge(badge)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"message": "Badge saved successfully", "id": badge.ID})
+}
+
+// Function to delete a badge, the users holding it lose it
+func DeleteBadge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	badgeID := r.FormValue("id")
+	var exists int
+	err := auth.DB.QueryRow("SELECT 1 FROM badges WHERE id = ?", badgeID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Badge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving badge", http.StatusInternalServerError)
+		return
+	}
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		http.Error(w, "Error deleting badge", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, query := range []string{"DELETE FROM user_badges WHERE badge_id = ?", "DELETE FROM badges WHERE id = ?"} {
+		if _, err := tx.Exec(query, badgeID); err != nil {
+			http.Error(w, "Error deleting badge", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error deleting badge", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Badge deleted successfully"})
+}
@@ -0,0 +1,93 @@
+package forum
+
+import (
+	"log"
+	"time"
+
+	"Forum/auth"
+	"Forum/storage"
+)
+
+// Statements removing the rows whose post, comment or message no longer exists
+var danglingUploadQueries = []string{
+	`DELETE FROM attachments WHERE (post_id IS NOT NULL AND post_id NOT IN (SELECT id FROM posts))
+		OR (comment_id IS NOT NULL AND comment_id NOT IN (SELECT id FROM comments))
+		OR (message_id IS NOT NULL AND message_id NOT IN (SELECT id FROM messages))`,
+	"DELETE FROM post_images WHERE post_id NOT IN (SELECT id FROM posts)",
+	// Thumbnails go with their image
+	`DELETE FROM image_thumbnails WHERE image_path NOT IN (SELECT file_path FROM attachments)
+		AND image_path NOT IN (SELECT image_path FROM post_images)
+		AND image_path NOT IN (SELECT avatar_path FROM users WHERE avatar_path IS NOT NULL)`,
+}
+
+// Queries listing every upload path still referenced by the database
+var uploadReferenceQueries = []string{
+	"SELECT file_path FROM attachments",
+	"SELECT image_path FROM post_images",
+	"SELECT thumb_path FROM image_thumbnails",
+	"SELECT avatar_path FROM users WHERE avatar_path IS NOT NULL AND avatar_path != ''",
+}
+
+// Function to load the keys of every referenced blob
+func referencedBlobs() (map[string]bool, error) {
+	referenced := map[string]bool{}
+	for _, query := range uploadReferenceQueries {
+		rows, err := auth.DB.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var path string
+			if rows.Scan(&path) == nil {
+				referenced[storage.KeyFromPath(path)] = true
+			}
+		}
+		rows.Close()
+	}
+	return referenced, nil
+}
+
+// Function to delete the blobs that nothing references anymore
+func collectOrphanBlobs() {
+	for _, query := range danglingUploadQueries {
+		if _, err := auth.DB.Exec(query); err != nil {
+			log.Println("Error cleaning upload references:", err)
+			return
+		}
+	}
+	referenced, err := referencedBlobs()
+	if err != nil {
+		log.Println("Error loading upload references:", err)
+		return
+	}
+	// Recent blobs may belong to an upload whose row is not written yet
+	var orphans []string
+	err = storage.Blobs.List(func(blob storage.BlobInfo) error {
+		if !referenced[blob.Key] && time.Since(blob.ModTime) > storage.OrphanGrace {
+			orphans = append(orphans, blob.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error listing blobs:", err)
+		return
+	}
+	for _, key := range orphans {
+		if err := storage.Blobs.Delete(key); err != nil {
+			log.Println("Error deleting blob:", key, err)
+		}
+	}
+	if len(orphans) > 0 {
+		log.Printf("Blob garbage collector removed %d files", len(orphans))
+	}
+}
+
+// Function to start the background worker removing unreferenced blobs
+func StartBlobCollector(interval time.Duration) {
+	go func() {
+		for {
+			collectOrphanBlobs()
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,160 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Kinds of block: the content of a muted user is hidden, a blocked user cannot
+// reach the user either and the user cannot reach them
+var blockKinds = map[string]string{
+	"block": "User blocked successfully",
+	"mute":  "User muted successfully",
+}
+
+// Users blocked or muted by the user given as argument, their content is hidden from them
+const hiddenUsers = "(SELECT blocked_id FROM blocks WHERE user_id = ?)"
+
+// User blocked or muted by the connected user
+type BlockedUser struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Function to check whether one of two users blocked the other
+func isBlocked(userID, otherID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(`SELECT 1 FROM blocks WHERE kind = 'block'
+		AND ((user_id = ?1 AND blocked_id = ?2) OR (user_id = ?2 AND blocked_id = ?1)) LIMIT 1`, userID, otherID).Scan(&exists)
+	return err == nil
+}
+
+// Function to check whether the actions of a user may be notified to another one,
+// which is not the case when the other user muted them or when one blocked the other
+func canReach(actorID, userID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(`SELECT 1 FROM blocks WHERE (user_id = ?2 AND blocked_id = ?1)
+		OR (user_id = ?1 AND blocked_id = ?2 AND kind = 'block') LIMIT 1`, actorID, userID).Scan(&exists)
+	return err != nil
+}
+
+// Function to find the user given by ID or by username in a form
+func formUser(r *http.Request) (string, bool) {
+	if r.FormValue("user_id") == "" && r.FormValue("username") == "" {
+		return "", false
+	}
+	var userID string
+	err := auth.DB.QueryRow("SELECT id FROM users WHERE id = ? OR username = ?", r.FormValue("user_id"), r.FormValue("username")).Scan(&userID)
+	return userID, err == nil
+}
+
+// Function to list the users blocked or muted by the connected user, of one kind when given
+func GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	if _, ok := blockKinds[kind]; kind != "" && !ok {
+		http.Error(w, "Invalid block kind", http.StatusBadRequest)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT b.blocked_id, COALESCE(u.username, ''), b.kind, b.created_at
+		FROM blocks b LEFT JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = ? AND (? = '' OR b.kind = ?) ORDER BY b.created_at DESC`, userID, kind, kind)
+	if err != nil {
+		http.Error(w, "Error retrieving blocked users", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	blocked := []BlockedUser{}
+	for rows.Next() {
+		var user BlockedUser
+		if err := rows.Scan(&user.UserID, &user.Username, &user.Kind, &user.CreatedAt); err != nil {
+			http.Error(w, "Error reading blocked users", http.StatusInternalServerError)
+			return
+		}
+		blocked = append(blocked, user)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocked)
+}
+
+// Function to block or mute a user, given by ID or by username, the kind of an
+// existing block is replaced
+func BlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	blockedID, ok := formUser(r)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if blockedID == userID {
+		http.Error(w, "You cannot block yourself", http.StatusBadRequest)
+		return
+	}
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = "block"
+	}
+	message, ok := blockKinds[kind]
+	if !ok {
+		http.Error(w, "Invalid block kind", http.StatusBadRequest)
+		return
+	}
+	_, err = auth.DB.Exec(`INSERT INTO blocks (user_id, blocked_id, kind, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, blocked_id) DO UPDATE SET kind = excluded.kind`, userID, blockedID, kind, time.Now())
+	if err != nil {
+		http.Error(w, "Error blocking user", http.StatusInternalServerError)
+		return
+	}
+	// Blocked users stop following each other
+	if kind == "block" {
+		_, err = auth.DB.Exec(`DELETE FROM subscriptions WHERE target_type = 'user'
+			AND ((user_id = ?1 AND target_id = ?2) OR (user_id = ?2 AND target_id = ?1))`, userID, blockedID)
+		if err != nil {
+			http.Error(w, "Error removing subscriptions", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// Function to unblock or unmute a user, given by ID or by username
+func UnblockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	blockedID, ok := formUser(r)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	_, err = auth.DB.Exec("DELETE FROM blocks WHERE user_id = ? AND blocked_id = ?", userID, blockedID)
+	if err != nil {
+		http.Error(w, "Error unblocking user", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unblocked successfully"})
+}
@@ -0,0 +1,320 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the bookmarks
+const (
+	maxCollectionNameLength = 60
+	maxBookmarkNoteLength   = 1000
+	maxCollectionsPerUser   = 50
+)
+
+// Posts bookmarked by the user, the user ID is given once
+const bookmarkedCondition = "p.id IN (SELECT target_id FROM bookmarks WHERE user_id = ? AND target_type = 'post')"
+
+// Posts bookmarked by the user in a collection, the user ID then the collection ID are given
+const collectionCondition = "p.id IN (SELECT target_id FROM bookmarks WHERE user_id = ? AND target_type = 'post' AND collection_id = ?)"
+
+// Query checking that the target of a bookmark is visible, by type
+var bookmarkTargets = map[string]string{
+	"post": "SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL AND scheduled_at IS NULL",
+	"comment": `SELECT 1 FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND p.deleted_at IS NULL AND p.scheduled_at IS NULL`,
+}
+
+// Bookmark of a post or a comment, with the title of the post to find it again
+type Bookmark struct {
+	TargetType     string    `json:"target_type"`
+	TargetID       string    `json:"target_id"`
+	PostID         string    `json:"post_id"`
+	Title          string    `json:"title"`
+	Excerpt        string    `json:"excerpt"`
+	CollectionID   *int64    `json:"collection_id"`
+	CollectionName string    `json:"collection_name,omitempty"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Named collection of bookmarks
+type BookmarkCollection struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Function to check that a collection belongs to the user, an empty ID means no collection
+func ownCollection(userID, collectionID string) (*int64, bool) {
+	if collectionID == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseInt(collectionID, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	var exists int
+	if auth.DB.QueryRow("SELECT 1 FROM bookmark_collections WHERE id = ? AND user_id = ?", id, userID).Scan(&exists) != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+// Function to validate the name of a collection
+func collectionName(r *http.Request) (string, bool) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	return name, name != "" && len([]rune(name)) <= maxCollectionNameLength
+}
+
+// Function to bookmark a post or a comment, or to change the note and collection of a bookmark
+func SaveBookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetType := r.FormValue("target_type")
+	targetID := r.FormValue("target_id")
+	query, ok := bookmarkTargets[targetType]
+	if !ok || targetID == "" {
+		http.Error(w, "Invalid bookmark target", http.StatusBadRequest)
+		return
+	}
+	var exists int
+	if auth.DB.QueryRow(query, targetID).Scan(&exists) != nil {
+		http.Error(w, "Bookmark target not found", http.StatusNotFound)
+		return
+	}
+	note := strings.TrimSpace(r.FormValue("note"))
+	if len([]rune(note)) > maxBookmarkNoteLength {
+		http.Error(w, "Note is too long", http.StatusBadRequest)
+		return
+	}
+	collectionID, ok := ownCollection(userID, r.FormValue("collection_id"))
+	if !ok {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	_, err = auth.DB.Exec(`INSERT INTO bookmarks (user_id, target_type, target_id, collection_id, note, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, target_type, target_id) DO UPDATE SET collection_id = excluded.collection_id, note = excluded.note`,
+		userID, targetType, targetID, collectionID, note, time.Now())
+	if err != nil {
+		http.Error(w, "Error saving bookmark", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Bookmark saved successfully"})
+}
+
+// Function to remove a bookmark
+func RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	result, err := auth.DB.Exec("DELETE FROM bookmarks WHERE user_id = ? AND target_type = ? AND target_id = ?",
+		userID, r.FormValue("target_type"), r.FormValue("target_id"))
+	if err != nil {
+		http.Error(w, "Error removing bookmark", http.StatusInternalServerError)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Bookmark removed successfully"})
+}
+
+// Function to list the bookmarks of the user, of a collection with collection_id or
+// outside any collection with collection_id=none. The bookmarks of the posts in the trash
+// are kept but not listed.
+func GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conditions := []string{"b.user_id = ?", "p.deleted_at IS NULL", "p.scheduled_at IS NULL"}
+	args := []any{userID}
+	switch collectionID := r.URL.Query().Get("collection_id"); collectionID {
+	case "":
+	case "none":
+		conditions = append(conditions, "b.collection_id IS NULL")
+	default:
+		conditions = append(conditions, "b.collection_id = ?")
+		args = append(args, collectionID)
+	}
+	rows, err := auth.DB.Query(`SELECT b.target_type, b.target_id, p.id, p.title, COALESCE(c.content, p.content),
+		b.collection_id, COALESCE(bc.name, ''), b.note, b.created_at
+		FROM bookmarks b
+		LEFT JOIN comments c ON b.target_type = 'comment' AND c.id = b.target_id
+		JOIN posts p ON p.id = CASE b.target_type WHEN 'comment' THEN c.post_id ELSE b.target_id END
+		LEFT JOIN bookmark_collections bc ON bc.id = b.collection_id
+		WHERE `+strings.Join(conditions, " AND ")+` ORDER BY b.created_at DESC`, args...)
+	if err != nil {
+		http.Error(w, "Error retrieving bookmarks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var bookmark Bookmark
+		var collectionID sql.NullInt64
+		if err := rows.Scan(&bookmark.TargetType, &bookmark.TargetID, &bookmark.PostID, &bookmark.Title, &bookmark.Excerpt,
+			&collectionID, &bookmark.CollectionName, &bookmark.Note, &bookmark.CreatedAt); err != nil {
+			http.Error(w, "Error reading bookmarks", http.StatusInternalServerError)
+			return
+		}
+		if collectionID.Valid {
+			bookmark.CollectionID = &collectionID.Int64
+		}
+		if excerpt := []rune(bookmark.Excerpt); len(excerpt) > 200 {
+			bookmark.Excerpt = string(excerpt[:200]) + "…"
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmarks)
+}
+
+// Function to list the collections of the user with their number of bookmarks
+func GetBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT bc.id, bc.name, bc.created_at,
+		(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id)
+		FROM bookmark_collections bc WHERE bc.user_id = ? ORDER BY bc.name COLLATE NOCASE`, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving collections", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var collection BookmarkCollection
+		if err := rows.Scan(&collection.ID, &collection.Name, &collection.CreatedAt, &collection.Count); err != nil {
+			http.Error(w, "Error reading collections", http.StatusInternalServerError)
+			return
+		}
+		collections = append(collections, collection)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collections)
+}
+
+// Function to create a collection of bookmarks, the names are unique per user
+func CreateBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name, ok := collectionName(r)
+	if !ok {
+		http.Error(w, "The name of a collection is required and limited to 60 characters", http.StatusBadRequest)
+		return
+	}
+	var count int
+	auth.DB.QueryRow("SELECT COUNT(*) FROM bookmark_collections WHERE user_id = ?", userID).Scan(&count)
+	if count >= maxCollectionsPerUser {
+		http.Error(w, "Too many collections", http.StatusConflict)
+		return
+	}
+	result, err := auth.DB.Exec("INSERT INTO bookmark_collections (user_id, name, created_at) VALUES (?, ?, ?)", userID, name, time.Now())
+	if err != nil {
+		http.Error(w, "A collection with this name already exists", http.StatusConflict)
+		return
+	}
+	id, _ := result.LastInsertId()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"message": "Collection created successfully", "id": id})
+}
+
+// Function to rename a collection of the user
+func RenameBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name, ok := collectionName(r)
+	if !ok {
+		http.Error(w, "The name of a collection is required and limited to 60 characters", http.StatusBadRequest)
+		return
+	}
+	result, err := auth.DB.Exec("UPDATE bookmark_collections SET name = ? WHERE id = ? AND user_id = ?", name, r.FormValue("id"), userID)
+	if err != nil {
+		http.Error(w, "A collection with this name already exists", http.StatusConflict)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Collection renamed successfully"})
+}
+
+// Function to delete a collection of the user, its bookmarks are kept outside any collection
+func DeleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	collectionID, ok := ownCollection(userID, r.FormValue("id"))
+	if !ok || collectionID == nil {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		http.Error(w, "Error deleting collection", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, query := range []string{"UPDATE bookmarks SET collection_id = NULL WHERE collection_id = ?", "DELETE FROM bookmark_collections WHERE id = ?"} {
+		if _, err := tx.Exec(query, *collectionID); err != nil {
+			http.Error(w, "Error deleting collection", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error deleting collection", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Collection deleted successfully"})
+}
@@ -0,0 +1,514 @@
+package forum
+
+import (
+	"Forum/auth"
+	"Forum/db"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of a category: a read-only category takes no new posts, an archived
+// one takes no comments on its posts either
+var categoryStatuses = map[string]bool{"open": true, "read_only": true, "archived": true}
+
+// Roles which may be required to post in a category
+var categoryPostRoles = map[string]bool{"user": true, "moderator": true, "admin": true}
+
+// IDs, as stored in post_categories, of a category given as argument and of its subcategories
+const categoryTree = `(WITH RECURSIVE tree(id) AS (SELECT ? UNION SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id)
+	SELECT CAST(id AS TEXT) FROM tree)`
+
+// Queries giving the posts and the followers of a removed category (?1) to another one (?2)
+var categoryRehomeQueries = []string{
+	"INSERT OR IGNORE INTO post_categories (post_id, category_id) SELECT post_id, ?2 FROM post_categories WHERE category_id = ?1",
+	`INSERT OR IGNORE INTO subscriptions (user_id, target_type, target_id, created_at)
+	SELECT user_id, 'category', ?2, created_at FROM subscriptions WHERE target_type = 'category' AND target_id = ?1`,
+}
+
+// Queries removing a category (?1) once its posts were given to another one
+var categoryCleanupQueries = []string{
+	"DELETE FROM post_categories WHERE category_id = ?1",
+	"DELETE FROM subscriptions WHERE target_type = 'category' AND target_id = ?1",
+	"DELETE FROM categories WHERE id = ?1",
+}
+
+// Queries giving the subcategories of a removed category (?1) to the category
+// taking its posts (?2) or to its own parent
+const (
+	childrenToTarget = "UPDATE categories SET parent_id = ?2 WHERE parent_id = ?1"
+	childrenToParent = "UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = ?1) WHERE parent_id = ?1"
+)
+
+// Category of the forum, listed with its subcategories after it
+type Category struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	ParentID    string `json:"parent_id"`
+	Position    int    `json:"position"`
+	Status      string `json:"status"`
+	PostRole    string `json:"post_role"`
+	// Questions and answers mode: a comment of each post can be accepted as the answer
+	QA    bool `json:"qa"`
+	Depth int  `json:"depth"`
+	// Posts of the category and of its subcategories, and the date of the last one or of their last comment
+	PostCount      int        `json:"post_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+	// Whether the connected user may post in the category
+	CanPost bool `json:"can_post"`
+}
+
+// Accented letters replaced in slugs and tags
+var accentReplacer = strings.NewReplacer("à", "a", "â", "a", "ä", "a", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "ô", "o", "ö", "o", "ù", "u", "û", "u", "ü", "u", "ç", "c", "œ", "oe", "æ", "ae")
+
+// Function to build the slug of a category from its name
+func slugify(name string) string {
+	name = accentReplacer.Replace(strings.ToLower(name))
+	var slug strings.Builder
+	dash := false
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			slug.WriteRune(c)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(slug.String(), "-")
+}
+
+// Function to give a slug to the categories created before slugs existed, made
+// unique with the category ID when needed
+func InitCategorySlugs() {
+	rows, err := auth.DB.Query("SELECT CAST(id AS TEXT), name FROM categories WHERE slug IS NULL OR slug = ''")
+	if err != nil {
+		log.Println("Error reading categories:", err)
+		return
+	}
+	type category struct{ id, name string }
+	var missing []category
+	for rows.Next() {
+		var c category
+		if err := rows.Scan(&c.id, &c.name); err == nil {
+			missing = append(missing, c)
+		}
+	}
+	rows.Close()
+	for _, c := range missing {
+		slug := slugify(c.name)
+		var exists int
+		if slug == "" || auth.DB.QueryRow("SELECT 1 FROM categories WHERE slug = ?", slug).Scan(&exists) == nil {
+			slug = strings.TrimPrefix(slug+"-"+c.id, "-")
+		}
+		if _, err := auth.DB.Exec("UPDATE categories SET slug = ? WHERE id = ?", slug, c.id); err != nil {
+			log.Println("Error saving category slug:", err)
+		}
+	}
+}
+
+// Function to check whether a category is the root category or one of its subcategories
+func inCategoryTree(rootID, categoryID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(`WITH RECURSIVE tree(id) AS (SELECT id FROM categories WHERE id = ?1
+		UNION SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id)
+		SELECT 1 FROM tree WHERE id = ?2`, rootID, categoryID).Scan(&exists)
+	return err == nil
+}
+
+// Function to check that a role may post in the given categories, it returns the
+// categories without duplicates and the HTTP status of the refusal
+func checkPostCategories(role string, categoryIDs []string) ([]string, int, error) {
+	var checked []string
+	for _, categoryID := range categoryIDs {
+		categoryID = strings.TrimSpace(categoryID)
+		if categoryID == "" || slices.Contains(checked, categoryID) {
+			continue
+		}
+		var name, status, postRole string
+		err := auth.DB.QueryRow("SELECT name, status, post_role FROM categories WHERE id = ?", categoryID).Scan(&name, &status, &postRole)
+		if err == sql.ErrNoRows {
+			return nil, http.StatusBadRequest, fmt.Errorf("Category %s not found", categoryID)
+		} else if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Error checking categories")
+		}
+		if status != "open" {
+			return nil, http.StatusForbidden, fmt.Errorf("Category %s is closed to new posts", name)
+		}
+		if !auth.HasRole(role, postRole) {
+			return nil, http.StatusForbidden, fmt.Errorf("You are not allowed to post in category %s", name)
+		}
+		checked = append(checked, categoryID)
+	}
+	if len(checked) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("At least one category is required")
+	}
+	return checked, http.StatusOK, nil
+}
+
+// Function to check whether a post belongs to an archived category, where it cannot be commented
+func isPostArchived(postID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(`SELECT 1 FROM post_categories pc JOIN categories c ON CAST(c.id AS TEXT) = pc.category_id
+		WHERE pc.post_id = ? AND c.status = 'archived' LIMIT 1`, postID).Scan(&exists)
+	return err == nil
+}
+
+// Function to read the categories, each one followed by its subcategories
+func loadCategories() ([]Category, error) {
+	rows, err := auth.DB.Query(`SELECT id, name, COALESCE(slug, ''), description, icon, COALESCE(CAST(parent_id AS TEXT), ''),
+		COALESCE(position, 0), status, post_role, qa FROM categories ORDER BY COALESCE(position, 0), name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all []Category
+	known := map[string]bool{}
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.Icon,
+			&category.ParentID, &category.Position, &category.Status, &category.PostRole, &category.QA); err != nil {
+			return nil, err
+		}
+		all = append(all, category)
+		known[category.ID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Categories whose parent is missing are shown at the root
+	children := map[string][]Category{}
+	for _, category := range all {
+		parentID := category.ParentID
+		if !known[parentID] {
+			parentID = ""
+		}
+		children[parentID] = append(children[parentID], category)
+	}
+	categories := []Category{}
+	visited := map[string]bool{}
+	var walk func(parentID string, depth int)
+	walk = func(parentID string, depth int) {
+		for _, category := range children[parentID] {
+			if visited[category.ID] {
+				continue
+			}
+			visited[category.ID] = true
+			category.Depth = depth
+			categories = append(categories, category)
+			walk(category.ID, depth+1)
+		}
+	}
+	walk("", 0)
+	return categories, nil
+}
+
+// Function to count the posts of each category with its subcategories and find their last activity
+func categoryActivity() (map[string]int, map[string]time.Time, error) {
+	rows, err := auth.DB.Query(`WITH RECURSIVE tree(root, id) AS (SELECT id, id FROM categories
+		UNION SELECT t.root, c.id FROM categories c JOIN tree t ON c.parent_id = t.id)
+		SELECT CAST(t.root AS TEXT), COUNT(DISTINCT p.id), MAX(p.created_at), MAX(cm.created_at)
+		FROM tree t
+		JOIN post_categories pc ON pc.category_id = CAST(t.id AS TEXT)
+		JOIN posts p ON p.id = pc.post_id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL
+		LEFT JOIN comments cm ON cm.post_id = p.id
+		GROUP BY t.root`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	lastActivity := map[string]time.Time{}
+	for rows.Next() {
+		var categoryID string
+		var count int
+		var lastPost, lastComment sql.NullString
+		if err := rows.Scan(&categoryID, &count, &lastPost, &lastComment); err != nil {
+			return nil, nil, err
+		}
+		counts[categoryID] = count
+		// The last activity is the last post or the last comment on one of them
+		for _, last := range []sql.NullString{lastPost, lastComment} {
+			if t, err := db.ParseTime(last.String); last.Valid && err == nil && t.After(lastActivity[categoryID]) {
+				lastActivity[categoryID] = t
+			}
+		}
+	}
+	return counts, lastActivity, rows.Err()
+}
+
+// Function to retrieves all categories from the database
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := loadCategories()
+	if err != nil {
+		http.Error(w, "Error retrieving categories", http.StatusInternalServerError)
+		return
+	}
+	counts, lastActivity, err := categoryActivity()
+	if err != nil {
+		http.Error(w, "Error reading categories", http.StatusInternalServerError)
+		return
+	}
+	_, role, err := auth.GetUserFromSessionRole(r)
+	for i := range categories {
+		category := &categories[i]
+		category.PostCount = counts[category.ID]
+		if last, ok := lastActivity[category.ID]; ok {
+			category.LastActivityAt = &last
+		}
+		category.CanPost = err == nil && category.Status == "open" && auth.HasRole(role, category.PostRole)
+	}
+	//Return a JSON respons
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// Function to read the fields of a category sent in a form, the missing ones keep their value
+func categoryFromForm(r *http.Request, category *Category) (int, error) {
+	fields := map[string]*string{
+		"name":        &category.Name,
+		"slug":        &category.Slug,
+		"description": &category.Description,
+		"icon":        &category.Icon,
+		"parent_id":   &category.ParentID,
+		"status":      &category.Status,
+		"post_role":   &category.PostRole,
+	}
+	for key, field := range fields {
+		if _, ok := r.Form[key]; ok {
+			*field = strings.TrimSpace(r.FormValue(key))
+		}
+	}
+	if _, ok := r.Form["qa"]; ok {
+		category.QA = r.FormValue("qa") == "true"
+	}
+	if _, ok := r.Form["position"]; ok {
+		position, err := strconv.Atoi(r.FormValue("position"))
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("Invalid position")
+		}
+		category.Position = position
+	}
+	if category.Name == "" {
+		return http.StatusBadRequest, fmt.Errorf("Category name is required")
+	}
+	if category.Slug == "" {
+		category.Slug = slugify(category.Name)
+	} else {
+		category.Slug = slugify(category.Slug)
+	}
+	if category.Slug == "" {
+		return http.StatusBadRequest, fmt.Errorf("Invalid category slug")
+	}
+	if !categoryStatuses[category.Status] {
+		return http.StatusBadRequest, fmt.Errorf("Invalid category status")
+	}
+	if !categoryPostRoles[category.PostRole] {
+		return http.StatusBadRequest, fmt.Errorf("Invalid posting role")
+	}
+	// The parent must exist and cannot be the category or one of its subcategories
+	if category.ParentID != "" {
+		var exists int
+		if err := auth.DB.QueryRow("SELECT 1 FROM categories WHERE id = ?", category.ParentID).Scan(&exists); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("Parent category not found")
+		}
+		if category.ID != "" && inCategoryTree(category.ID, category.ParentID) {
+			return http.StatusBadRequest, fmt.Errorf("A category cannot be moved under itself")
+		}
+	}
+	// The name and the slug are unique
+	var existing string
+	err := auth.DB.QueryRow("SELECT id FROM categories WHERE (name = ? OR slug = ?) AND CAST(id AS TEXT) != ?",
+		category.Name, category.Slug, category.ID).Scan(&existing)
+	if err == nil {
+		return http.StatusBadRequest, fmt.Errorf("Category already exists")
+	} else if err != sql.ErrNoRows {
+		return http.StatusInternalServerError, fmt.Errorf("Error checking category existence")
+	}
+	return http.StatusOK, nil
+}
+
+// Function to allows the admin to create a new category
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	category := Category{Status: "open", PostRole: "user"}
+	if status, err := categoryFromForm(r, &category); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// Insert the new category into the database
+	result, err := auth.DB.Exec(`INSERT INTO categories (name, slug, description, icon, parent_id, position, status, post_role, qa)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)`, category.Name, category.Slug, category.Description, category.Icon,
+		category.ParentID, category.Position, category.Status, category.PostRole, category.QA)
+	if err != nil {
+		http.Error(w, "Error creating category", http.StatusInternalServerError)
+		return
+	}
+	// Retrieve the ID of the inserted category
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		http.Error(w, "Error retrieving category ID", http.StatusInternalServerError)
+		return
+	}
+	// Respond with the new category ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Category created successfully",
+		"id":      lastInsertID,
+		"slug":    category.Slug,
+	})
+}
+
+// Function to allows the admin to edit a category, including its parent and its position
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	var category Category
+	err := auth.DB.QueryRow(`SELECT CAST(id AS TEXT), name, COALESCE(slug, ''), description, icon, COALESCE(CAST(parent_id AS TEXT), ''),
+		COALESCE(position, 0), status, post_role, qa FROM categories WHERE id = ?`, r.FormValue("id")).Scan(&category.ID, &category.Name,
+		&category.Slug, &category.Description, &category.Icon, &category.ParentID, &category.Position, &category.Status, &category.PostRole, &category.QA)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving category", http.StatusInternalServerError)
+		return
+	}
+	if status, err := categoryFromForm(r, &category); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	_, err = auth.DB.Exec(`UPDATE categories SET name = ?, slug = ?, description = ?, icon = ?, parent_id = NULLIF(?, ''),
+		position = ?, status = ?, post_role = ?, qa = ? WHERE id = ?`, category.Name, category.Slug, category.Description, category.Icon,
+		category.ParentID, category.Position, category.Status, category.PostRole, category.QA, category.ID)
+	if err != nil {
+		http.Error(w, "Error updating category", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Category updated successfully"})
+}
+
+// Function to remove a category, its posts and followers go to the target category
+// when one is given, its subcategories are moved by the children query
+func removeCategory(categoryID, targetID, childrenQuery string) error {
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		return err
+	}
+	queries := append([]string{}, categoryCleanupQueries...)
+	if targetID != "" {
+		queries = append(append([]string{}, categoryRehomeQueries...), queries...)
+	}
+	queries = append([]string{childrenQuery}, queries...)
+	for _, query := range queries {
+		if _, err := tx.Exec(query, numberedArgs(query, categoryID, targetID)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Function to check that the category and the target category of a form exist and differ
+func categoryAndTarget(w http.ResponseWriter, r *http.Request, targetKey string) (string, string, bool) {
+	categoryID := r.FormValue("id")
+	targetID := r.FormValue(targetKey)
+	if categoryID == "" {
+		http.Error(w, "Category ID is required", http.StatusBadRequest)
+		return "", "", false
+	}
+	var exists int
+	if err := auth.DB.QueryRow("SELECT 1 FROM categories WHERE id = ?", categoryID).Scan(&exists); err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return "", "", false
+	}
+	if targetID == "" {
+		return categoryID, "", true
+	}
+	if err := auth.DB.QueryRow("SELECT 1 FROM categories WHERE id = ?", targetID).Scan(&exists); err != nil {
+		http.Error(w, "Target category not found", http.StatusNotFound)
+		return "", "", false
+	}
+	if targetID == categoryID {
+		http.Error(w, "The target category must be another category", http.StatusBadRequest)
+		return "", "", false
+	}
+	return categoryID, targetID, true
+}
+
+// Function to allows the admin to merge a category into another one, which takes its
+// posts, its followers and its subcategories
+func MergeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	categoryID, targetID, ok := categoryAndTarget(w, r, "target_id")
+	if !ok {
+		return
+	}
+	if targetID == "" {
+		http.Error(w, "Target category ID is required", http.StatusBadRequest)
+		return
+	}
+	if inCategoryTree(categoryID, targetID) {
+		http.Error(w, "A category cannot be merged into one of its subcategories", http.StatusBadRequest)
+		return
+	}
+	if err := removeCategory(categoryID, targetID, childrenToTarget); err != nil {
+		http.Error(w, "Error merging category", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Category merged successfully"})
+}
+
+// Function to allows the admin to delete a category, its posts are moved to the
+// category given by move_to and its subcategories go to its parent
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	categoryID, targetID, ok := categoryAndTarget(w, r, "move_to")
+	if !ok {
+		return
+	}
+	// The posts are never left without their category
+	if targetID == "" {
+		var posts int
+		if err := auth.DB.QueryRow("SELECT COUNT(*) FROM post_categories WHERE category_id = ?", categoryID).Scan(&posts); err != nil {
+			http.Error(w, "Error counting posts", http.StatusInternalServerError)
+			return
+		}
+		if posts > 0 {
+			http.Error(w, "The category still has posts, give a category to move them to", http.StatusConflict)
+			return
+		}
+	}
+	if err := removeCategory(categoryID, targetID, childrenToParent); err != nil {
+		http.Error(w, "Error deleting category", http.StatusInternalServerError)
+		return
+	}
+	// Respond with a success message
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted successfully"})
+}
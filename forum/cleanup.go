@@ -0,0 +1,100 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Statements removing what belongs to a purged post and to its comments, ?1 is the post ID.
+// The files of the attachments are removed later by the blob garbage collector.
+var postCleanupQueries = []string{
+	"DELETE FROM attachments WHERE post_id = ?1 OR comment_id IN (SELECT id FROM comments WHERE post_id = ?1)",
+	"DELETE FROM mentions WHERE post_id = ?1 OR comment_id IN (SELECT id FROM comments WHERE post_id = ?1)",
+	"DELETE FROM likes WHERE post_id = ?1 OR comment_id IN (SELECT id FROM comments WHERE post_id = ?1)",
+	"DELETE FROM subscriptions WHERE target_type = 'post' AND target_id = ?1",
+	"DELETE FROM bookmarks WHERE (target_type = 'post' AND target_id = ?1) OR (target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE post_id = ?1))",
+	"DELETE FROM post_tags WHERE post_id = ?1",
+	"DELETE FROM post_categories WHERE post_id = ?1",
+	"DELETE FROM post_pins WHERE post_id = ?1",
+	"DELETE FROM post_reads WHERE post_id = ?1",
+	"DELETE FROM poll_votes WHERE post_id = ?1",
+	"DELETE FROM poll_ballots WHERE post_id = ?1",
+	"DELETE FROM poll_options WHERE post_id = ?1",
+	"DELETE FROM polls WHERE post_id = ?1",
+	"DELETE FROM reports WHERE post_id = ?1",
+	"DELETE FROM comments WHERE post_id = ?1",
+}
+
+// Statements removing what belongs to a deleted comment, ?1 is the comment ID
+var commentCleanupQueries = []string{
+	"UPDATE posts SET accepted_comment_id = NULL, accepted_at = NULL WHERE accepted_comment_id = ?1",
+	"DELETE FROM attachments WHERE comment_id = ?1",
+	"DELETE FROM mentions WHERE comment_id = ?1",
+	"DELETE FROM bookmarks WHERE target_type = 'comment' AND target_id = ?1",
+}
+
+// Statements removing what belongs to a deleted message, ?1 is the message ID
+var messageCleanupQueries = []string{
+	"DELETE FROM attachments WHERE message_id = ?1",
+	"DELETE FROM message_reports WHERE message_id = ?1",
+}
+
+// Statements deleting a conversation with its messages, ?1 is the conversation ID
+var conversationCleanupQueries = []string{
+	"DELETE FROM attachments WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?1)",
+	"DELETE FROM message_reports WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?1)",
+	"DELETE FROM notifications WHERE target_type = 'conversation' AND target_id = ?1",
+	"DELETE FROM messages WHERE conversation_id = ?1",
+	"DELETE FROM conversation_members WHERE conversation_id = ?1",
+	"DELETE FROM conversations WHERE id = ?1",
+}
+
+// Function to remove the data attached to a purged post within its transaction
+func deletePostData(tx *sql.Tx, postID string) error {
+	for _, query := range postCleanupQueries {
+		if _, err := tx.Exec(query, postID); err != nil {
+			return fmt.Errorf("Error cleaning post data: %v", err)
+		}
+	}
+	return nil
+}
+
+// Function to remove the data attached to a deleted comment
+func deleteCommentData(commentID string) {
+	for _, query := range commentCleanupQueries {
+		if _, err := auth.DB.Exec(query, commentID); err != nil {
+			log.Println("Error cleaning comment data:", err)
+		}
+	}
+}
+
+// Function to remove the data attached to a deleted message
+func deleteMessageData(messageID string) {
+	for _, query := range messageCleanupQueries {
+		if _, err := auth.DB.Exec(query, messageID); err != nil {
+			log.Println("Error cleaning message data:", err)
+		}
+	}
+}
+
+// Function to delete a conversation that has no member left
+func deleteConversationData(conversationID string) {
+	for _, query := range conversationCleanupQueries {
+		if _, err := auth.DB.Exec(query, conversationID); err != nil {
+			log.Println("Error cleaning conversation data:", err)
+		}
+	}
+}
+
+// Function to keep the arguments a query numbers, ?1 alone or ?1 and ?2, since the
+// driver refuses more arguments than the query uses
+func numberedArgs(query string, args ...interface{}) []interface{} {
+	for len(args) > 1 && !strings.Contains(query, "?"+strconv.Itoa(len(args))) {
+		args = args[:len(args)-1]
+	}
+	return args
+}
@@ -4,7 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,40 +17,126 @@ import (
 )
 // Function for the creation of a new comment
 func CreateComment(w http.ResponseWriter, r *http.Request) {
-	userID, err := auth.GetUserFromSession(r)
+	userID, role, err := auth.GetUserFromSessionRole(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	postID := r.FormValue("post_id")
-	content := r.FormValue("content")
+	// Reject oversized uploads before reading the form
+	if !parseUploadForm(w, r) {
+		return
+	}
+	if _, status, err := createComment(userID, role, r.FormValue("post_id"), r.FormValue("content"), uploadedFiles(r)); err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Function to add a comment to a post, it returns the ID of the comment and the HTTP
+// status of the refusal
+func createComment(userID, role, postID, content string, files []*multipart.FileHeader) (string, int, error) {
+	// Links need a trust level, the new users are a target of spammers
+	if containsLink(content) && !auth.HasTrust(userID, role, "post_links") {
+		return "", http.StatusForbidden, fmt.Errorf("Your trust level does not allow links yet")
+	}
+	// Comments are refused on the posts in the trash, locked or archived
+	status, err := postStatus(postID)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Error retrieving post")
+	}
+	switch status {
+	case "deleted":
+		return "", http.StatusNotFound, fmt.Errorf("Post not found")
+	case "archived":
+		return "", http.StatusForbidden, fmt.Errorf("This post is archived and cannot be commented")
+	case "locked":
+		return "", http.StatusForbidden, fmt.Errorf("This post is locked and cannot be commented")
+	}
 
+	// Save the attached files
+	attachments, err := saveAttachments(userID, "comment_id", files)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
 	commentID := uuid.New().String()
 	_, err = auth.DB.Exec("INSERT INTO comments (id, user_id, post_id, content, created_at) VALUES (?, ?, ?, ?, ?)", commentID, userID, postID, content, time.Now())
 	if err != nil {
-		http.Error(w, "Error creating comment", http.StatusInternalServerError)
-		return
+		return "", http.StatusInternalServerError, fmt.Errorf("Error creating comment")
+	}
+	if err := insertAttachments(userID, "comment_id", commentID, attachments); err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Error saving attachments")
 	}
+	// Notify the users mentioned with @username, they are not told about the reply twice
+	mentioned := recordMentions(userID, postID, commentID, content)
 	var postOwner string
 	err = auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", postID).Scan(&postOwner)
 
-	// Create a notification for the owner of the post
-	if err == nil && postOwner != userID {
-		CreateNotification(postOwner, postID, "comment", content)
+	// Create a notification for the owner of the post, unless they were mentioned
+	if err == nil && postOwner != userID && !slices.Contains(mentioned, postOwner) {
+		CreateNotification(NotificationEvent{
+			UserID:     postOwner,
+			ActorID:    userID,
+			Action:     "comment",
+			PostID:     postID,
+			TargetType: "post",
+			TargetID:   postID,
+			Template:   "comment",
+			Content:    content,
+			Params:     map[string]string{"comment_id": commentID},
+		})
 	}
+	// The subscribers of the post are told about the reply, then the author follows the post
+	// and has read it up to their reply
+	notifyNewComment(userID, postID, commentID, content, append(mentioned, postOwner))
+	if err := subscribe(userID, "post", postID); err != nil {
+		log.Println("Error subscribing to post:", err)
+	}
+	if err := markPostRead(userID, postID); err != nil {
+		log.Println("Error marking post as read:", err)
+	}
+	return commentID, http.StatusOK, nil
 }
 
 // Function to retrieves all comments associated with a specific post
 func GetComments(w http.ResponseWriter, r *http.Request) {
 	// Get the post ID
     postID := r.URL.Query().Get("post_id")
+    userID, _ := auth.GetUserFromSession(r)
 
-	// Query the database for comments
+	// Query the database for comments, without those of the users blocked or muted by the reader
     if postID == "" {
         http.Error(w, "Post ID is required", http.StatusBadRequest)
         return
     }
-    rows, err := auth.DB.Query("SELECT id, user_id, content, created_at FROM comments WHERE post_id = ? ORDER BY created_at ASC", postID)
+    // The comments of a post in the trash or not published yet are hidden with it
+    if status, err := postStatus(postID); err != nil {
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return
+    } else if status == "deleted" {
+        http.Error(w, "Post not found", http.StatusNotFound)
+        return
+    }
+    // Only the comments after the cursor when one is given, those not read yet with since=last_read
+    cursor, cursorArgs, ok := commentCursor(userID, r.URL.Query().Get("since"))
+    if !ok {
+        http.Error(w, "Invalid since cursor", http.StatusBadRequest)
+        return
+    }
+    conditions := []string{"c.post_id = ?", "c.user_id NOT IN " + hiddenUsers}
+    // The user ID for the bookmark and the unread columns, then the conditions
+    args := append(readArgs(userID, 5), postID, userID)
+    if cursor != "" {
+        conditions = append(conditions, cursor)
+        args = append(args, cursorArgs...)
+    }
+    rows, err := auth.DB.Query(`
+        SELECT c.id, c.user_id, COALESCE(u.username, 'deleted user'), COALESCE(u.avatar_path, ''), c.content, c.created_at,
+        c.id = COALESCE(p.accepted_comment_id, '') AS accepted,
+        EXISTS (SELECT 1 FROM bookmarks bm WHERE bm.user_id = ? AND bm.target_type = 'comment' AND bm.target_id = c.id),
+        ? != '' AND c.user_id != ? AND c.created_at > `+readMarker+`
+        FROM comments c
+        LEFT JOIN users u ON c.user_id = u.id
+        LEFT JOIN posts p ON p.id = c.post_id
+        WHERE `+strings.Join(conditions, " AND ")+` ORDER BY accepted DESC, c.created_at ASC`, args...)
     if err != nil {
         http.Error(w, "Error retrieving comments", http.StatusInternalServerError)
         return
@@ -57,8 +147,16 @@ func GetComments(w http.ResponseWriter, r *http.Request) {
     type Comment struct {
         ID        string    `json:"id"`
         UserID    string    `json:"user_id"`
+        Username  string    `json:"username"`
+        AvatarPath string   `json:"avatar_path"`
         Content   string    `json:"content"`
         CreatedAt time.Time `json:"created_at"`
+        Attachments []Attachment `json:"attachments"`
+        // Accepted answer of a question, listed first
+        Accepted  bool      `json:"accepted"`
+        Bookmarked bool     `json:"bookmarked"`
+        // Written by another user since the reader last read the post
+        New       bool      `json:"new"`
     }
 	// Initialize a slice to store the comments
     var comments []Comment
@@ -66,13 +164,23 @@ func GetComments(w http.ResponseWriter, r *http.Request) {
 	// Iterate through the rows and scan the data
     for rows.Next() {
         var comment Comment
-        if err := rows.Scan(&comment.ID, &comment.UserID, &comment.Content, &comment.CreatedAt); err != nil {
+        if err := rows.Scan(&comment.ID, &comment.UserID, &comment.Username, &comment.AvatarPath, &comment.Content, &comment.CreatedAt, &comment.Accepted, &comment.Bookmarked, &comment.New); err != nil {
             http.Error(w, "Error at reading comment", http.StatusInternalServerError)
             return
         }
 		// Append the comment to the slice
         comments = append(comments, comment)
     }
+    rows.Close()
+    // Load the attachments of every comment at once
+    commentIDs := make([]string, len(comments))
+    for i := range comments {
+        commentIDs[i] = comments[i].ID
+    }
+    attachments := loadAttachments("comment_id", commentIDs)
+    for i := range comments {
+        comments[i].Attachments = attachments[comments[i].ID]
+    }
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(comments)
 }
@@ -95,28 +203,34 @@ func DeleteComment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "comment ID is required", http.StatusBadRequest)
 		return
 	}
+	if status, err := deleteOwnComment(userID, commentID); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	fmt.Fprintf(w, "comment deleted successfully!")
+}
+
+// Function to delete a comment of the user with what belongs to it, it returns the HTTP
+// status of the refusal
+func deleteOwnComment(userID, commentID string) (int, error) {
 	// Query the database
 	var commentOwner string
-	err = auth.DB.QueryRow("SELECT user_id FROM comments WHERE id = ?", commentID).Scan(&commentOwner)
+	err := auth.DB.QueryRow("SELECT user_id FROM comments WHERE id = ?", commentID).Scan(&commentOwner)
 	if err == sql.ErrNoRows {
-		http.Error(w, "comment not found", http.StatusNotFound)
-		return
+		return http.StatusNotFound, fmt.Errorf("comment not found")
 	} else if err != nil {
-		http.Error(w, "Error retrieving comment", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError, fmt.Errorf("Error retrieving comment")
 	}
 	// Check if the current user is the owner of the comment
 	if commentOwner != userID {
-		http.Error(w, "You can only delete your own comments", http.StatusForbidden)
-		return
+		return http.StatusForbidden, fmt.Errorf("You can only delete your own comments")
 	}
 	// Delete the comment from the database
-	_, err = auth.DB.Exec("DELETE FROM comments WHERE id = ?", commentID)
-	if err != nil {
-		http.Error(w, "error deleting comment", http.StatusInternalServerError)
-		return
+	if _, err := auth.DB.Exec("DELETE FROM comments WHERE id = ?", commentID); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("error deleting comment")
 	}
-	fmt.Fprintf(w, "comment deleted successfully!")
+	deleteCommentData(commentID)
+	return http.StatusOK, nil
 }
 
 // Function to handles liking a comment
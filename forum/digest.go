@@ -0,0 +1,110 @@
+package forum
+
+import (
+	"Forum/auth"
+	"Forum/mailer"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Function to return the digest frequency of a user, daily when never chosen
+func digestFrequency(userID string) string {
+	digest := "daily"
+	auth.DB.QueryRow("SELECT digest FROM notification_settings WHERE user_id = ?", userID).Scan(&digest)
+	return digest
+}
+
+// Function to describe a notification on one line of the digest
+func digestLine(n Notification) string {
+	line := "- " + n.Text
+	if (n.Action == "comment" || n.Action == "reply") && n.Count == 1 && n.Content != "" {
+		line += " : " + n.Content
+	}
+	return line + "\n  " + publicURL() + n.Link
+}
+
+// Function to return the address of the forum used in the emails, set with PUBLIC_URL
+func publicURL() string {
+	if url := os.Getenv("PUBLIC_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://localhost:8080"
+}
+
+// Function to send their digest to the users whose period is over and who have
+// notifications waiting for the email channel
+func sendDigests() {
+	type recipient struct {
+		id, email, digest string
+		last              sql.NullTime
+	}
+	rows, err := auth.DB.Query(`SELECT u.id, u.email, COALESCE(s.digest, 'daily'), s.last_digest_at
+		FROM users u LEFT JOIN notification_settings s ON s.user_id = u.id
+		WHERE COALESCE(s.digest, 'daily') != 'none'
+		AND EXISTS (SELECT 1 FROM notifications n WHERE n.user_id = u.id AND n.email = 1 AND n.emailed = 0)`)
+	if err != nil {
+		log.Println("Error listing digest recipients:", err)
+		return
+	}
+	var recipients []recipient
+	for rows.Next() {
+		var rc recipient
+		if err := rows.Scan(&rc.id, &rc.email, &rc.digest, &rc.last); err == nil {
+			recipients = append(recipients, rc)
+		}
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, rc := range recipients {
+		period := 24 * time.Hour
+		if rc.digest == "weekly" {
+			period = 7 * 24 * time.Hour
+		}
+		if rc.last.Valid && now.Sub(rc.last.Time) < period {
+			continue
+		}
+		if err := sendDigest(rc.id, rc.email, now); err != nil {
+			log.Println("Error sending digest:", err)
+		}
+	}
+}
+
+// Function to build and send the digest of a user, then mark its notifications as emailed
+func sendDigest(userID, email string, now time.Time) error {
+	notifications, err := loadNotifications(defaultLocale, userID, "n.email = 1 AND n.emailed = 0")
+	if err != nil || len(notifications) == 0 {
+		return err
+	}
+	var lines []string
+	for i := len(notifications) - 1; i >= 0; i-- {
+		lines = append(lines, digestLine(notifications[i]))
+	}
+
+	subject := fmt.Sprintf("Votre résumé du forum : %d notifications", len(notifications))
+	body := "Bonjour,\n\nVoici ce qui s'est passé sur le forum depuis votre dernier résumé :\n\n" +
+		strings.Join(lines, "\n") +
+		"\n\nVous pouvez choisir les notifications reçues par email depuis votre profil.\n"
+	if err := mailer.Default.Send(email, subject, body); err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		auth.DB.Exec("UPDATE notifications SET emailed = 1 WHERE id = ?", n.ID)
+	}
+	_, err = auth.DB.Exec("INSERT INTO notification_settings (user_id, last_digest_at) VALUES (?, ?) ON CONFLICT(user_id) DO UPDATE SET last_digest_at = excluded.last_digest_at", userID, now)
+	return err
+}
+
+// Function to start the worker sending the daily and weekly digests
+func StartDigestWorker(interval time.Duration) {
+	go func() {
+		for {
+			sendDigests()
+			time.Sleep(interval)
+		}
+	}()
+}
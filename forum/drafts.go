@@ -0,0 +1,265 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits of the drafts kept for a user
+const (
+	maxDraftsPerUser    = 50
+	maxDraftTitleLength = 300
+	maxDraftLength      = 100000
+)
+
+// Draft of a post saved while it is written
+type Draft struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Categories string    `json:"categories"`
+	Tags       string    `json:"tags"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Post of the user waiting for its publication
+type ScheduledPost struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Held        bool      `json:"held"`
+}
+
+// Function to read the publication date of the post form, nil when the post is published
+// now. The date comes from a datetime-local input, or in RFC 3339.
+func parsePublishAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	publishAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		publishAt, err = time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid publication date")
+	}
+	if !publishAt.After(time.Now()) {
+		return nil, fmt.Errorf("The publication date must be in the future")
+	}
+	// Stored in UTC, the text comparisons of the scheduler would break on a change of offset
+	publishAt = publishAt.UTC()
+	return &publishAt, nil
+}
+
+// Function to tell the mentioned users and the subscribers that a post went live
+func announcePost(authorID, postID, content string, categoryIDs, tagIDs []string) {
+	mentioned := recordMentions(authorID, postID, "", content)
+	notifyNewPost(authorID, postID, categoryIDs, tagIDs, mentioned)
+}
+
+// Function to save a draft, a new one without id, it returns the draft with its date
+func SaveDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	draft := Draft{
+		ID:         r.FormValue("id"),
+		Title:      r.FormValue("title"),
+		Content:    r.FormValue("content"),
+		Categories: r.FormValue("categories"),
+		Tags:       r.FormValue("tags"),
+		UpdatedAt:  time.Now(),
+	}
+	if len(draft.Title) > maxDraftTitleLength || len(draft.Content) > maxDraftLength {
+		http.Error(w, "Draft too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if draft.ID != "" {
+		// Only the author updates a draft
+		result, err := auth.DB.Exec("UPDATE drafts SET title = ?, content = ?, categories = ?, tags = ?, updated_at = ? WHERE id = ? AND user_id = ?",
+			draft.Title, draft.Content, draft.Categories, draft.Tags, draft.UpdatedAt, draft.ID, userID)
+		if err != nil {
+			http.Error(w, "Error saving draft", http.StatusInternalServerError)
+			return
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		// An empty form is not worth a draft
+		if strings.TrimSpace(draft.Title) == "" && strings.TrimSpace(draft.Content) == "" {
+			http.Error(w, "The draft is empty", http.StatusBadRequest)
+			return
+		}
+		var count int
+		auth.DB.QueryRow("SELECT COUNT(*) FROM drafts WHERE user_id = ?", userID).Scan(&count)
+		if count >= maxDraftsPerUser {
+			http.Error(w, fmt.Sprintf("You cannot keep more than %d drafts", maxDraftsPerUser), http.StatusConflict)
+			return
+		}
+		draft.ID = uuid.New().String()
+		draft.CreatedAt = draft.UpdatedAt
+		_, err = auth.DB.Exec("INSERT INTO drafts (id, user_id, title, content, categories, tags, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			draft.ID, userID, draft.Title, draft.Content, draft.Categories, draft.Tags, draft.CreatedAt, draft.UpdatedAt)
+		if err != nil {
+			http.Error(w, "Error saving draft", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": draft.ID, "updated_at": draft.UpdatedAt})
+}
+
+// Function to list the drafts of the user, the most recently saved first
+func GetDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := auth.DB.Query("SELECT id, title, content, categories, tags, created_at, updated_at FROM drafts WHERE user_id = ? ORDER BY updated_at DESC", userID)
+	if err != nil {
+		http.Error(w, "Error retrieving drafts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	drafts := []Draft{}
+	for rows.Next() {
+		var draft Draft
+		if err := rows.Scan(&draft.ID, &draft.Title, &draft.Content, &draft.Categories, &draft.Tags, &draft.CreatedAt, &draft.UpdatedAt); err != nil {
+			http.Error(w, "Error reading drafts", http.StatusInternalServerError)
+			return
+		}
+		drafts = append(drafts, draft)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drafts)
+}
+
+// Function to delete a draft of the user
+func DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	result, err := auth.DB.Exec("DELETE FROM drafts WHERE id = ? AND user_id = ?", r.FormValue("id"), userID)
+	if err != nil {
+		http.Error(w, "Error deleting draft", http.StatusInternalServerError)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Draft deleted successfully"})
+}
+
+// Function to list the posts of the user waiting for their publication or for the review of
+// a moderator, the next one first. They are cancelled by deleting them.
+func GetScheduledPosts(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT id, title, content, scheduled_at, held_at IS NOT NULL FROM posts
+		WHERE user_id = ? AND scheduled_at IS NOT NULL AND deleted_at IS NULL ORDER BY scheduled_at`, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving scheduled posts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	posts := []ScheduledPost{}
+	for rows.Next() {
+		var post ScheduledPost
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.ScheduledAt, &post.Held); err != nil {
+			http.Error(w, "Error reading scheduled posts", http.StatusInternalServerError)
+			return
+		}
+		posts = append(posts, post)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+// Function to publish the scheduled posts whose date has come, then notify their readers.
+// The posts held for review wait for a moderator.
+func publishScheduledPosts() {
+	type duePost struct{ id, userID, content string }
+	rows, err := auth.DB.Query("SELECT id, user_id, content FROM posts WHERE scheduled_at IS NOT NULL AND scheduled_at <= ? AND held_at IS NULL AND deleted_at IS NULL",
+		time.Now().UTC())
+	if err != nil {
+		log.Println("Error listing scheduled posts:", err)
+		return
+	}
+	var due []duePost
+	for rows.Next() {
+		var post duePost
+		if rows.Scan(&post.id, &post.userID, &post.content) == nil {
+			due = append(due, post)
+		}
+	}
+	rows.Close()
+	for _, post := range due {
+		// The post is announced once, by whoever publishes it first
+		result, err := auth.DB.Exec("UPDATE posts SET scheduled_at = NULL WHERE id = ? AND scheduled_at IS NOT NULL AND held_at IS NULL AND deleted_at IS NULL", post.id)
+		if err != nil {
+			log.Println("Error publishing scheduled post:", post.id, err)
+			continue
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			continue
+		}
+		announcePost(post.userID, post.id, post.content, postLinks("post_categories", "category_id", post.id), postLinks("post_tags", "tag_id", post.id))
+	}
+}
+
+// Function to read the IDs linked to a post in a link table
+func postLinks(table, column, postID string) []string {
+	var ids []string
+	rows, err := auth.DB.Query("SELECT "+column+" FROM "+table+" WHERE post_id = ?", postID)
+	if err != nil {
+		return ids
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id sql.NullString
+		if rows.Scan(&id) == nil && id.Valid {
+			ids = append(ids, id.String)
+		}
+	}
+	return ids
+}
+
+// Function to start the scheduler publishing the posts at their date
+func StartPostScheduler(interval time.Duration) {
+	go func() {
+		for {
+			publishScheduledPosts()
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,372 @@
+package forum
+
+import (
+	"Forum/auth"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"html"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Number of entries of a feed
+const feedSize = 50
+
+// Feed of posts or comments, rendered as RSS 2.0 or Atom. The feeds are read without a
+// session, they list what a visitor sees of the forum.
+type feed struct {
+	Title       string
+	Description string
+	Link        string
+	Self        string
+	Updated     time.Time
+	Items       []feedItem
+}
+
+// Entry of a feed
+type feedItem struct {
+	ID         string
+	Title      string
+	Link       string
+	Author     string
+	Content    string
+	Published  time.Time
+	Categories []string
+}
+
+// RSS 2.0 document, with the Atom link to itself that feed readers expect
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Creator     string   `xml:"dc:creator"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Atom document
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Content    atomContent    `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// Function to render a feed in the format asked with format=atom, RSS otherwise
+func renderFeed(f feed, format string) ([]byte, string, error) {
+	var document any
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == "atom" {
+		contentType = "application/atom+xml; charset=utf-8"
+		atom := atomDocument{
+			Title:   f.Title,
+			ID:      f.Self,
+			Updated: f.Updated.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: f.Link, Rel: "alternate", Type: "text/html"}, {Href: f.Self, Rel: "self", Type: "application/atom+xml"}},
+		}
+		for _, item := range f.Items {
+			entry := atomEntry{
+				Title:     item.Title,
+				ID:        "urn:uuid:" + item.ID,
+				Updated:   item.Published.UTC().Format(time.RFC3339),
+				Published: item.Published.UTC().Format(time.RFC3339),
+				Link:      atomLink{Href: item.Link, Rel: "alternate", Type: "text/html"},
+				Author:    atomAuthor{Name: item.Author},
+				Content:   atomContent{Type: "text", Value: item.Content},
+			}
+			for _, category := range item.Categories {
+				entry.Categories = append(entry.Categories, atomCategory{Term: category})
+			}
+			atom.Entries = append(atom.Entries, entry)
+		}
+		document = atom
+	} else {
+		rss := rssDocument{
+			Version: "2.0",
+			AtomNS:  "http://www.w3.org/2005/Atom",
+			DCNS:    "http://purl.org/dc/elements/1.1/",
+			Channel: rssChannel{
+				Title:       f.Title,
+				Link:        f.Link,
+				Description: f.Description,
+				Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+			},
+		}
+		if !f.Updated.IsZero() {
+			rss.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+		}
+		for _, item := range f.Items {
+			rss.Channel.Items = append(rss.Channel.Items, rssItem{
+				Title:   item.Title,
+				Link:    item.Link,
+				GUID:    rssGUID{Value: "urn:uuid:" + item.ID},
+				Creator: item.Author,
+				// The readers show the description as HTML, the content is text
+				Description: html.EscapeString(item.Content),
+				PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+				Categories:  item.Categories,
+			})
+		}
+		document = rss
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), contentType, nil
+}
+
+// Function to send a feed with its caching headers: the ETag is the hash of the document and
+// Last-Modified the date of its latest entry, the readers asking again get a 304 when nothing changed
+func serveFeed(w http.ResponseWriter, r *http.Request, f feed) {
+	format := r.URL.Query().Get("format")
+	if format == "atom" {
+		f.Self += "?format=atom"
+	}
+	for _, item := range f.Items {
+		if item.Published.After(f.Updated) {
+			f.Updated = item.Published
+		}
+	}
+	body, contentType, err := renderFeed(f, format)
+	if err != nil {
+		http.Error(w, "Error rendering feed", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", f.Updated.Truncate(time.Second), bytes.NewReader(body))
+}
+
+// Function to load the latest visible posts matching a condition as feed entries, with
+// their tags as categories
+func postFeedItems(condition string, args ...any) ([]feedItem, error) {
+	query := `SELECT p.id, p.title, p.content, p.created_at, COALESCE(u.username, 'deleted user')
+		FROM posts p LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NULL AND p.scheduled_at IS NULL`
+	if condition != "" {
+		query += " AND " + condition
+	}
+	rows, err := auth.DB.Query(query+" ORDER BY p.created_at DESC LIMIT ?", append(args, feedSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []feedItem
+	var postIDs []string
+	for rows.Next() {
+		var item feedItem
+		if err := rows.Scan(&item.ID, &item.Title, &item.Content, &item.Published, &item.Author); err != nil {
+			return nil, err
+		}
+		item.Link = publicURL() + "/forum?post=" + item.ID
+		items = append(items, item)
+		postIDs = append(postIDs, item.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	tags := loadPostTags(postIDs)
+	for i := range items {
+		for _, tag := range tags[items[i].ID] {
+			items[i].Categories = append(items[i].Categories, tag.Name)
+		}
+	}
+	return items, nil
+}
+
+// Function to serve the feed of the latest posts
+func GetPostsFeed(w http.ResponseWriter, r *http.Request) {
+	items, err := postFeedItems("")
+	if err != nil {
+		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
+		return
+	}
+	serveFeed(w, r, feed{
+		Title:       "Forum : derniers posts",
+		Description: "Les derniers posts du forum",
+		Link:        publicURL() + "/forum",
+		Self:        publicURL() + "/feeds/posts",
+		Items:       items,
+	})
+}
+
+// Function to serve the feed of the latest posts of a category and its subcategories
+func GetCategoryFeed(w http.ResponseWriter, r *http.Request) {
+	categoryID := r.PathValue("id")
+	var name string
+	err := auth.DB.QueryRow("SELECT name FROM categories WHERE id = ?", categoryID).Scan(&name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving category", http.StatusInternalServerError)
+		return
+	}
+	items, err := postFeedItems("p.id IN (SELECT post_id FROM post_categories WHERE category_id IN "+categoryTree+")", categoryID)
+	if err != nil {
+		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
+		return
+	}
+	serveFeed(w, r, feed{
+		Title:       "Forum : " + name,
+		Description: "Les derniers posts de la catégorie " + name,
+		Link:        publicURL() + "/forum",
+		Self:        publicURL() + "/feeds/category/" + url.PathEscape(categoryID),
+		Items:       items,
+	})
+}
+
+// Function to serve the feed of the latest posts with a tag, given by name or synonym
+func GetTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag, ok := resolveTag(normalizeTag(r.PathValue("name")))
+	if !ok {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	items, err := postFeedItems("p.id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)", tag.ID)
+	if err != nil {
+		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
+		return
+	}
+	serveFeed(w, r, feed{
+		Title:       "Forum : #" + tag.Name,
+		Description: "Les derniers posts avec le tag " + tag.Name,
+		Link:        publicURL() + "/forum",
+		Self:        publicURL() + "/feeds/tag/" + url.PathEscape(tag.Name),
+		Items:       items,
+	})
+}
+
+// Function to serve the feed of the latest posts of a user
+func GetUserFeed(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	var userID string
+	err := auth.DB.QueryRow("SELECT id, username FROM users WHERE username = ?", username).Scan(&userID, &username)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+	items, err := postFeedItems("p.user_id = ?", userID)
+	if err != nil {
+		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
+		return
+	}
+	serveFeed(w, r, feed{
+		Title:       "Forum : posts de " + username,
+		Description: "Les derniers posts de " + username,
+		Link:        publicURL() + "/user/" + url.PathEscape(username),
+		Self:        publicURL() + "/feeds/user/" + url.PathEscape(username),
+		Items:       items,
+	})
+}
+
+// Function to serve the feed of the latest comments of a post
+func GetCommentsFeed(w http.ResponseWriter, r *http.Request) {
+	postID := r.PathValue("id")
+	var title string
+	var createdAt time.Time
+	err := auth.DB.QueryRow("SELECT title, created_at FROM posts WHERE id = ? AND deleted_at IS NULL AND scheduled_at IS NULL", postID).Scan(&title, &createdAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT c.id, c.content, c.created_at, COALESCE(u.username, 'deleted user')
+		FROM comments c LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = ? ORDER BY c.created_at DESC LIMIT ?`, postID, feedSize)
+	if err != nil {
+		http.Error(w, "Error retrieving comments", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	var items []feedItem
+	for rows.Next() {
+		var item feedItem
+		if err := rows.Scan(&item.ID, &item.Content, &item.Published, &item.Author); err != nil {
+			http.Error(w, "Error reading comments", http.StatusInternalServerError)
+			return
+		}
+		item.Title = item.Author + " sur « " + title + " »"
+		item.Link = publicURL() + "/forum?post=" + postID + "#comment-" + item.ID
+		items = append(items, item)
+	}
+	serveFeed(w, r, feed{
+		Title:       "Forum : commentaires sur « " + title + " »",
+		Description: "Les derniers commentaires du post " + title,
+		Link:        publicURL() + "/forum?post=" + postID,
+		Self:        publicURL() + "/feeds/post/" + url.PathEscape(postID) + "/comments",
+		Updated:     createdAt,
+		Items:       items,
+	})
+}
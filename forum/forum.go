@@ -1,8 +1,8 @@
 package forum
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"text/template"
@@ -77,56 +77,86 @@ func LikeContent(w http.ResponseWriter, r *http.Request, contentType string) {
 		http.Error(w, "Invalid parameters", http.StatusBadRequest)
 		return
 	}
-	// Check if the user has already liked or disliked
+	// The same reaction again removes it
+	if reactionOf(userID, contentID) == typeLike {
+		typeLike = ""
+	}
+	if status, err := react(userID, contentType, contentID, typeLike); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// Send a JSON response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Like status updated successfully"})
+}
+
+// Function to return the reaction of a user to a post or comment, empty without one
+func reactionOf(userID, contentID string) string {
 	var existingType string
-	err = auth.DB.QueryRow("SELECT type FROM likes WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", userID, contentID, contentID).Scan(&existingType)
+	auth.DB.QueryRow("SELECT type FROM likes WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", userID, contentID, contentID).Scan(&existingType)
+	return existingType
+}
 
-	if err == sql.ErrNoRows {
+// Function to set the reaction of a user to a post or comment, an empty type removes it.
+// It returns the HTTP status of the refusal.
+func react(userID, contentType, contentID, typeLike string) (int, error) {
+	// Reactions are refused on the posts in the trash or archived, and on their comments
+	likedPostID := contentID
+	if contentType != "post" {
+		auth.DB.QueryRow("SELECT post_id FROM comments WHERE id = ?", contentID).Scan(&likedPostID)
+	}
+	if status, err := postStatus(likedPostID); err == nil && status == "deleted" {
+		return http.StatusNotFound, fmt.Errorf("Post not found")
+	} else if status == "archived" {
+		return http.StatusForbidden, fmt.Errorf("This post is archived")
+	}
+	// Check if the user has already liked or disliked
+	existingType := reactionOf(userID, contentID)
+	var err error
+	switch {
+	case existingType == typeLike:
+		// Nothing changes
+		return http.StatusOK, nil
+	case typeLike == "":
+		_, err = auth.DB.Exec("DELETE FROM likes WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", userID, contentID, contentID)
+	case existingType == "":
 		// If no like/dislike exists, create a new like/dislike entry in the database
-		likeID := uuid.New().String()
-		var query string
-
-		// Determine the query based on the content type
+		query := "INSERT INTO likes (id, user_id, comment_id, type, created_at) VALUES (?, ?, ?, ?, ?)"
 		if contentType == "post" {
 			query = "INSERT INTO likes (id, user_id, post_id, type, created_at) VALUES (?, ?, ?, ?, ?)"
-		} else {
-			query = "INSERT INTO likes (id, user_id, comment_id, type, created_at) VALUES (?, ?, ?, ?, ?)"
 		}
-
-		// Insert the new like/dislike into the database.
-		_, err = auth.DB.Exec(query, likeID, userID, contentID, typeLike, time.Now())
-	} else if err == nil {
-		// If the user has already liked or disliked, update the existing record
-		if existingType == typeLike {
-			_, err = auth.DB.Exec("DELETE FROM likes WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", userID, contentID, contentID)
-		} else {
-			// If the user wants to change their like/dislike, update the record
-			_, err = auth.DB.Exec("UPDATE likes SET type = ? WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", typeLike, userID, contentID, contentID)
-		}
-	} else {
-		http.Error(w, "Error processing like", http.StatusInternalServerError)
-		return
+		_, err = auth.DB.Exec(query, uuid.New().String(), userID, contentID, typeLike, time.Now())
+	default:
+		// If the user wants to change their like/dislike, update the record
+		_, err = auth.DB.Exec("UPDATE likes SET type = ? WHERE user_id = ? AND (post_id = ? OR comment_id = ?)", typeLike, userID, contentID, contentID)
 	}
-	var ownerID string
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Error processing like")
+	}
+	var ownerID, postID string
 	if contentType == "post" {
+		postID = contentID
 		err = auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", contentID).Scan(&ownerID)
 	} else {
-		err = auth.DB.QueryRow("SELECT user_id FROM comments WHERE id = ?", contentID).Scan(&ownerID)
-	}
-	if err == nil && ownerID != userID {
-		CreateNotification(ownerID, contentID, "like", ""+typeLike)
+		err = auth.DB.QueryRow("SELECT user_id, post_id FROM comments WHERE id = ?", contentID).Scan(&ownerID, &postID)
 	}
 	if err != nil {
-		http.Error(w, "Error updating like status", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError, fmt.Errorf("Error updating like status")
 	}
-	// Create a notification for the owner of the post or comments
-	if ownerID != userID {
-        CreateNotification(ownerID, contentID, "like", typeLike)
-    }
-	// Send a JSON response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Like status updated successfully"})
+	// Create a notification for the owner of the post or comments, not when the reaction is removed
+	if ownerID != userID && typeLike != "" {
+		CreateNotification(NotificationEvent{
+			UserID:     ownerID,
+			ActorID:    userID,
+			Action:     "like",
+			PostID:     postID,
+			TargetType: contentType,
+			TargetID:   contentID,
+			Template:   typeLike,
+			Content:    typeLike,
+		})
+	}
+	return http.StatusOK, nil
 }
 
 // Function to retrieves the count of likes and dislikes
@@ -159,34 +189,3 @@ func GetLikesAndDislike(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"likes": likeCount, "dislikes": dislikeCount})
 }
-
-// Function to retrieves all categories from the database
-func GetCategories(w http.ResponseWriter, r *http.Request) {
-	// Query the database to get all categories.
-	rows, err := auth.DB.Query("SELECT id, name FROM categories")
-	if err != nil {
-		http.Error(w, "Error retrieving categories", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	// Define a category struct
-	type Category struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-	}
-	// Create a slice to hold the categories
-	var categories []Category
-	for rows.Next() {
-		var category Category
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
-			http.Error(w, "Error reading categories", http.StatusInternalServerError)
-			return
-		}
-		// Add each category to the slice.
-		categories = append(categories, category)
-	}
-	//Return a JSON respons
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(categories)
-}
\ No newline at end of file
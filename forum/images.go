@@ -0,0 +1,510 @@
+package forum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Forum/auth"
+	"Forum/storage"
+)
+
+// Maximum allowed size (20mb)
+const maxImageSize = 20 * 1024 * 1024
+
+// Maximum number of pixels of a decoded image
+const maxImagePixels = 50 * 1000 * 1000
+
+// Maximum size of a whole upload request, the attachments plus the other form fields
+const maxUploadRequestSize = 64 * 1024 * 1024
+
+// Image formats accepted after sniffing the content, with the extension used on disk
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Extensions of the formats the pipeline can write
+var outputExtensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"webp": ".webp",
+}
+
+// Image processing settings, read from the environment
+type imageConfig struct {
+	MaxWidth       int
+	MaxHeight      int
+	ThumbnailSizes []int
+	OutputFormat   string
+	JPEGQuality    int
+}
+
+// Function to read an integer setting with a default value
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// Function to load the image settings (IMAGE_MAX_WIDTH, IMAGE_MAX_HEIGHT, IMAGE_THUMBNAIL_SIZES, IMAGE_OUTPUT_FORMAT, IMAGE_JPEG_QUALITY)
+func loadImageConfig() imageConfig {
+	cfg := imageConfig{
+		MaxWidth:     envInt("IMAGE_MAX_WIDTH", 2048),
+		MaxHeight:    envInt("IMAGE_MAX_HEIGHT", 2048),
+		OutputFormat: strings.ToLower(os.Getenv("IMAGE_OUTPUT_FORMAT")),
+		JPEGQuality:  envInt("IMAGE_JPEG_QUALITY", 85),
+	}
+	if _, ok := outputExtensions[cfg.OutputFormat]; !ok {
+		cfg.OutputFormat = ""
+	}
+	sizes := os.Getenv("IMAGE_THUMBNAIL_SIZES")
+	if sizes == "" {
+		sizes = "150,400"
+	}
+	for _, s := range strings.Split(sizes, ",") {
+		if size, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && size > 0 {
+			cfg.ThumbnailSizes = append(cfg.ThumbnailSizes, size)
+		}
+	}
+	return cfg
+}
+
+// Result of the processing of an uploaded image
+type savedImage struct {
+	Path       string
+	Size       int64
+	Width      int
+	Height     int
+	Thumbnails map[int]string
+}
+
+// Function to limit the size of an upload request and parse its form before anything is read
+func parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadRequestSize)
+	err := r.ParseMultipartForm(maxUploadRequestSize)
+	if err == http.ErrNotMultipart {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+		}
+		return false
+	}
+	return true
+}
+
+// Function to save an uploaded image: the content is sniffed, decoded and re-encoded
+// so that EXIF/GPS metadata never reaches the disk
+func saveImage(fileHeader *multipart.FileHeader, private bool) (savedImage, error) {
+	var saved savedImage
+	if fileHeader.Size > maxImageSize {
+		return saved, fmt.Errorf("file too large")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return saved, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		return saved, err
+	}
+	if len(data) > maxImageSize {
+		return saved, fmt.Errorf("file too large")
+	}
+	// Trust the magic bytes, not the file name
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return saved, fmt.Errorf("unsupported file type")
+	}
+	// Refuse decompression bombs before allocating the pixels
+	header, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return saved, fmt.Errorf("invalid image")
+	}
+	if header.Width*header.Height > maxImagePixels {
+		return saved, fmt.Errorf("image dimensions too large")
+	}
+	cfg := loadImageConfig()
+
+	// GIFs that already fit are re-encoded frame by frame to keep the animation and palette
+	if contentType == "image/gif" && (cfg.OutputFormat == "" || cfg.OutputFormat == "gif") {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return saved, fmt.Errorf("invalid image")
+		}
+		if anim.Config.Width <= cfg.MaxWidth && anim.Config.Height <= cfg.MaxHeight {
+			saved.Width, saved.Height = anim.Config.Width, anim.Config.Height
+			var buf bytes.Buffer
+			if err := gif.EncodeAll(&buf, anim); err != nil {
+				return saved, err
+			}
+			saved.Size = int64(buf.Len())
+			if saved.Path, err = storeBlob(buf.Bytes(), ".gif", private); err != nil {
+				return saved, err
+			}
+			saved.Thumbnails = saveThumbnails(saved.Path, toRGBA(anim.Image[0]), "png", cfg, private)
+			return saved, nil
+		}
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return saved, fmt.Errorf("invalid image")
+	}
+	img := toRGBA(src)
+	// The orientation lives in the EXIF block that is about to be dropped
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+	img = fitWithin(img, cfg.MaxWidth, cfg.MaxHeight)
+
+	outFormat := cfg.OutputFormat
+	if outFormat == "" {
+		outFormat = format
+	}
+	saved.Width, saved.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	data, err = encodeImage(img, outFormat, cfg)
+	if err != nil {
+		return saved, err
+	}
+	saved.Size = int64(len(data))
+	if saved.Path, err = storeBlob(data, outputExtensions[outFormat], private); err != nil {
+		return saved, err
+	}
+	// GIF thumbnails would lose their colors with the default palette
+	thumbFormat := outFormat
+	if thumbFormat == "gif" {
+		thumbFormat = "png"
+	}
+	saved.Thumbnails = saveThumbnails(saved.Path, img, thumbFormat, cfg, private)
+	return saved, nil
+}
+
+// Function to generate and record the thumbnails of an image, one per configured size
+func saveThumbnails(imagePath string, img *image.RGBA, format string, cfg imageConfig, private bool) map[int]string {
+	thumbnails := map[int]string{}
+	// The same image was already uploaded, its thumbnails are shared
+	existing := loadThumbnails(imagePath)
+	for _, size := range cfg.ThumbnailSizes {
+		if path, ok := existing[strconv.Itoa(size)]; ok {
+			thumbnails[size] = path
+			continue
+		}
+		thumb := fitWithin(img, size, size)
+		data, err := encodeImage(thumb, format, cfg)
+		if err != nil {
+			continue
+		}
+		path, err := storeBlob(data, outputExtensions[format], private)
+		if err != nil {
+			continue
+		}
+		_, err = auth.DB.Exec("INSERT OR REPLACE INTO image_thumbnails (image_path, size, thumb_path, width, height) VALUES (?, ?, ?, ?, ?)",
+			imagePath, size, path, thumb.Bounds().Dx(), thumb.Bounds().Dy())
+		if err != nil {
+			continue
+		}
+		thumbnails[size] = path
+	}
+	return thumbnails
+}
+
+// Function to store processed content in the blob store and return its upload path
+func storeBlob(data []byte, extension string, private bool) (string, error) {
+	key, _, err := storage.Save(bytes.NewReader(data), extension, uploadContentTypes[extension], private)
+	if err != nil {
+		return "", fmt.Errorf("failed to store file: %v", err)
+	}
+	return storage.PathFromKey(key), nil
+}
+
+// Function to retrieves the thumbnails of an image keyed by size
+func loadThumbnails(imagePath string) map[string]string {
+	thumbnails := map[string]string{}
+	if imagePath == "" {
+		return thumbnails
+	}
+	rows, err := auth.DB.Query("SELECT size, thumb_path FROM image_thumbnails WHERE image_path = ?", imagePath)
+	if err != nil {
+		return thumbnails
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var size int
+		var path string
+		if rows.Scan(&size, &path) == nil {
+			thumbnails[strconv.Itoa(size)] = path
+		}
+	}
+	return thumbnails
+}
+
+// Function to encode an image in the requested format
+func encodeImage(img *image.RGBA, format string, cfg imageConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, flattenAlpha(img), &jpeg.Options{Quality: cfg.JPEGQuality})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "webp":
+		err = encodeWebP(&buf, img)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Function to convert any decoded image to RGBA
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// Function to draw an image on a white background, JPEG has no transparency
+func flattenAlpha(img *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// Function to shrink an image to fit in the given box, keeping its ratio
+func fitWithin(img *image.RGBA, maxWidth, maxHeight int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+	newW, newH := maxWidth, h*maxWidth/w
+	if newH > maxHeight {
+		newW, newH = w*maxHeight/h, maxHeight
+	}
+	return resizeImage(img, max(newW, 1), max(newH, 1))
+}
+
+// Function to downscale an image by averaging the source pixels covered by each destination pixel
+func resizeImage(src *image.RGBA, newW, newH int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
+	for y := 0; y < newH; y++ {
+		y0 := y * srcH / newH
+		y1 := max((y+1)*srcH/newH, y0+1)
+		for x := 0; x < newW; x++ {
+			x0 := x * srcW / newW
+			x1 := max((x+1)*srcW/newW, x0+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				offset := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[offset])
+					g += int(src.Pix[offset+1])
+					b += int(src.Pix[offset+2])
+					a += int(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Function to read the EXIF orientation of a JPEG, 1 when there is none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		// The metadata segments are all before the start of scan
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// Function to find the orientation tag (0x0112) in the first IFD of a TIFF block
+func exifOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// Function to rotate or flip an image according to an EXIF orientation
+func applyOrientation(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+	return dst
+}
+
+// Content types of the files served from the uploads directory
+var uploadContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// Function to serve an uploaded file from the blob store without directory listing or content sniffing
+func ServeUpload(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	if !storage.ValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	access := uploadAccess(storage.PathFromKey(key))
+	access.Private = access.Private || storage.IsPrivateKey(key)
+	// Private files are only reachable through a signed URL
+	query := r.URL.Query()
+	signed := storage.VerifySignature(key, query.Get("expires"), query.Get("signature"))
+	if access.Private && !signed {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	contentType, ok := uploadContentTypes[strings.ToLower(filepath.Ext(key))]
+	if !ok {
+		// Other attachments are always downloaded under their original name
+		if !access.Found {
+			http.NotFound(w, r)
+			return
+		}
+		contentType = access.MimeType
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": access.Name}))
+	}
+	blob, info, err := storage.Blobs.Get(key)
+	if err == storage.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Println("Error reading blob:", key, err)
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	// Blob names are the hash of their content, which never changes
+	if access.Private {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	if seeker, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, info.ModTime, seeker)
+		return
+	}
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodHead {
+		io.Copy(w, blob)
+	}
+}
@@ -0,0 +1,321 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default number of days a deleted post can be restored before it is purged
+const defaultRestoreDays = 30
+
+// Flags of a post p: locked, then archived by itself or by one of its categories
+const postFlags = `p.locked_at IS NOT NULL, p.archived_at IS NOT NULL OR EXISTS (SELECT 1 FROM post_categories apc
+	JOIN categories ac ON CAST(ac.id AS TEXT) = apc.category_id WHERE apc.post_id = p.id AND ac.status = 'archived')`
+
+// Post in the trash, shown to the moderators
+type TrashedPost struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content"`
+	Username     string    `json:"username"`
+	CreatedAt    time.Time `json:"created_at"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    string    `json:"deleted_by"`
+	RestoreUntil time.Time `json:"restore_until"`
+}
+
+// Function to return how long a deleted post stays in the trash, configurable with POST_RESTORE_DAYS
+func restoreWindow() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("POST_RESTORE_DAYS"))
+	if err != nil || days < 0 {
+		days = defaultRestoreDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Function to return after how long without activity a post is archived, configurable
+// with POST_ARCHIVE_DAYS, posts are never archived automatically when it is zero
+func archiveAfter() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("POST_ARCHIVE_DAYS"))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Function to find the state of a post: "open", "locked", "archived" or "deleted",
+// a post that does not exist or is not published yet is considered deleted
+func postStatus(postID string) (string, error) {
+	var locked, archived, deleted, scheduled bool
+	err := auth.DB.QueryRow("SELECT locked_at IS NOT NULL, archived_at IS NOT NULL, deleted_at IS NOT NULL, scheduled_at IS NOT NULL FROM posts WHERE id = ?",
+		postID).Scan(&locked, &archived, &deleted, &scheduled)
+	switch {
+	case err == sql.ErrNoRows || deleted || scheduled:
+		return "deleted", nil
+	case err != nil:
+		return "", err
+	case archived || isPostArchived(postID):
+		return "archived", nil
+	case locked:
+		return "locked", nil
+	}
+	return "open", nil
+}
+
+// Function to move a post to the trash, it can be restored during the restore window
+func trashPost(postID, userID string) (bool, error) {
+	result, err := auth.DB.Exec("UPDATE posts SET deleted_at = ?, deleted_by = NULLIF(?, '') WHERE id = ? AND deleted_at IS NULL",
+		time.Now(), userID, postID)
+	if err != nil {
+		return false, err
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// Function to delete a post for good with what belongs to it
+func purgePost(postID string) error {
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Nothing is removed unless everything is, so no row is left pointing to a missing post
+	if err := deletePostData(tx, postID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM posts WHERE id = ?", postID); err != nil {
+		return err
+	}
+	// The files are collected later by the blob garbage collector
+	return tx.Commit()
+}
+
+// Function to find the post of a moderation form, which must not be in the trash
+func formLivePost(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return "", false
+	}
+	postID := r.FormValue("post_id")
+	var exists int
+	err := auth.DB.QueryRow("SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL", postID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return "", false
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return "", false
+	}
+	return postID, true
+}
+
+// Function to pin a post to the top of the forum, or of a category when category_id is
+// given, or to unpin it with pinned=false
+func PinPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := formLivePost(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := auth.GetUserFromSession(r)
+	scope := r.FormValue("category_id")
+	if r.FormValue("pinned") == "false" {
+		if _, err := auth.DB.Exec("DELETE FROM post_pins WHERE post_id = ? AND scope = ?", postID, scope); err != nil {
+			http.Error(w, "Error unpinning post", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Post unpinned successfully"})
+		return
+	}
+	// A post is pinned in a category it belongs to, directly or through a subcategory
+	if scope != "" {
+		var exists int
+		err := auth.DB.QueryRow("SELECT 1 FROM post_categories WHERE post_id = ? AND category_id IN "+categoryTree+" LIMIT 1",
+			postID, scope).Scan(&exists)
+		if err != nil {
+			http.Error(w, "The post is not in this category", http.StatusBadRequest)
+			return
+		}
+	}
+	_, err := auth.DB.Exec(`INSERT INTO post_pins (post_id, scope, pinned_by, pinned_at) VALUES (?, ?, NULLIF(?, ''), ?)
+		ON CONFLICT(post_id, scope) DO NOTHING`, postID, scope, userID, time.Now())
+	if err != nil {
+		http.Error(w, "Error pinning post", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post pinned successfully"})
+}
+
+// Function to lock a thread against new comments, or to unlock it with locked=false
+func LockPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := formLivePost(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := auth.GetUserFromSession(r)
+	query, message := "UPDATE posts SET locked_at = ?2, locked_by = NULLIF(?3, '') WHERE id = ?1", "Post locked successfully"
+	if r.FormValue("locked") == "false" {
+		query, message = "UPDATE posts SET locked_at = NULL, locked_by = NULL WHERE id = ?1", "Post unlocked successfully"
+	}
+	if _, err := auth.DB.Exec(query, numberedArgs(query, postID, time.Now(), userID)...); err != nil {
+		http.Error(w, "Error locking post", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// Function to archive a thread, it becomes read-only, or to reopen it with archived=false
+func ArchivePost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := formLivePost(w, r)
+	if !ok {
+		return
+	}
+	query, message := "UPDATE posts SET archived_at = ?2 WHERE id = ?1", "Post archived successfully"
+	if r.FormValue("archived") == "false" {
+		query, message = "UPDATE posts SET archived_at = NULL WHERE id = ?1", "Post unarchived successfully"
+	}
+	if _, err := auth.DB.Exec(query, numberedArgs(query, postID, time.Now())...); err != nil {
+		http.Error(w, "Error archiving post", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// Function to list the deleted posts that can still be restored, most recent first
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	rows, err := auth.DB.Query(`SELECT p.id, p.title, p.content, COALESCE(u.username, 'deleted user'), p.created_at, p.deleted_at,
+		COALESCE(d.username, '') FROM posts p
+		LEFT JOIN users u ON u.id = p.user_id
+		LEFT JOIN users d ON d.id = p.deleted_by
+		WHERE p.deleted_at IS NOT NULL ORDER BY p.deleted_at DESC`)
+	if err != nil {
+		http.Error(w, "Error retrieving trash", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	window := restoreWindow()
+	posts := []TrashedPost{}
+	for rows.Next() {
+		var post TrashedPost
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Username, &post.CreatedAt, &post.DeletedAt, &post.DeletedBy); err != nil {
+			http.Error(w, "Error reading trash", http.StatusInternalServerError)
+			return
+		}
+		post.RestoreUntil = post.DeletedAt.Add(window)
+		posts = append(posts, post)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+// Function to restore a deleted post while its restore window is open
+func RestorePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	postID := r.FormValue("post_id")
+	var deletedAt time.Time
+	err := auth.DB.QueryRow("SELECT deleted_at FROM posts WHERE id = ? AND deleted_at IS NOT NULL", postID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found in the trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	if time.Since(deletedAt) > restoreWindow() {
+		http.Error(w, "The restore window of this post is over", http.StatusGone)
+		return
+	}
+	if _, err := auth.DB.Exec("UPDATE posts SET deleted_at = NULL, deleted_by = NULL WHERE id = ?", postID); err != nil {
+		http.Error(w, "Error restoring post", http.StatusInternalServerError)
+		return
+	}
+	// The author gets back the reputation lost by the removal
+	auth.DB.Exec("DELETE FROM reputation_events WHERE post_id = ? AND kind IN ('post_removed', 'post_rejected')", postID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post restored successfully"})
+}
+
+// Function to delete a post of the trash for good, without waiting for the end of its restore window
+func PurgePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	postID := r.FormValue("post_id")
+	var exists int
+	err := auth.DB.QueryRow("SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NOT NULL", postID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found in the trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	if err := purgePost(postID); err != nil {
+		http.Error(w, "Error purging post", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post purged successfully"})
+}
+
+// Function to purge the posts whose restore window is over
+func purgeExpiredPosts() {
+	rows, err := auth.DB.Query("SELECT id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-restoreWindow()))
+	if err != nil {
+		log.Println("Error listing expired posts:", err)
+		return
+	}
+	var postIDs []string
+	for rows.Next() {
+		var postID string
+		if rows.Scan(&postID) == nil {
+			postIDs = append(postIDs, postID)
+		}
+	}
+	rows.Close()
+	for _, postID := range postIDs {
+		if err := purgePost(postID); err != nil {
+			log.Println("Error purging post:", err)
+		}
+	}
+}
+
+// Function to archive the threads without new post or comment for the configured time
+func archiveInactivePosts() {
+	after := archiveAfter()
+	if after == 0 {
+		return
+	}
+	now := time.Now()
+	_, err := auth.DB.Exec(`UPDATE posts SET archived_at = ?1
+		WHERE archived_at IS NULL AND deleted_at IS NULL AND scheduled_at IS NULL AND created_at < ?2
+		AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.post_id = posts.id AND c.created_at >= ?2)`, now, now.Add(-after))
+	if err != nil {
+		log.Println("Error archiving inactive posts:", err)
+	}
+}
+
+// Function to start the background worker purging the trash and archiving inactive threads
+func StartPostLifecycleWorker(interval time.Duration) {
+	go func() {
+		for {
+			purgeExpiredPosts()
+			archiveInactivePosts()
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,133 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Maximum number of users notified by one post or comment
+const maxMentions = 10
+
+// @username preceded by the start of the text or a character that cannot be in an email
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@-])@([\p{L}\p{N}_.-]{1,32})`)
+
+// Function to extract the usernames mentioned in a text, without duplicates
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// A dot ending the sentence is not part of the name
+		username := strings.TrimRight(match[1], ".")
+		key := strings.ToLower(username)
+		if username == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
+
+// Function to return whether a user accepts to be mentioned
+func allowMentions(userID string) bool {
+	allowed := true
+	auth.DB.QueryRow("SELECT allow_mentions FROM notification_settings WHERE user_id = ?", userID).Scan(&allowed)
+	return allowed
+}
+
+// Function to check whether a user can be mentioned by another one
+func canMention(authorID, userID string) bool {
+	return authorID != userID && allowMentions(userID) && canReach(authorID, userID)
+}
+
+// Function to record the mentions of a post or a comment and notify the mentioned users,
+// it returns the IDs of the users notified
+func recordMentions(authorID, postID, commentID, content string) []string {
+	var mentioned []string
+	for _, username := range parseMentions(content) {
+		var userID string
+		// The exact username first, then without case
+		err := auth.DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&userID)
+		if err != nil {
+			err = auth.DB.QueryRow("SELECT id FROM users WHERE username = ? COLLATE NOCASE LIMIT 1", username).Scan(&userID)
+		}
+		if err != nil || !canMention(authorID, userID) {
+			continue
+		}
+		_, err = auth.DB.Exec("INSERT INTO mentions (id, user_id, author_id, post_id, comment_id, created_at) VALUES (?, ?, ?, ?, NULLIF(?, ''), ?)",
+			uuid.New().String(), userID, authorID, postID, commentID, time.Now())
+		if err != nil {
+			continue
+		}
+		event := NotificationEvent{
+			UserID:     userID,
+			ActorID:    authorID,
+			Action:     "mention",
+			PostID:     postID,
+			TargetType: "post",
+			TargetID:   postID,
+			Template:   "mention",
+			Content:    content,
+		}
+		if commentID != "" {
+			event.TargetType = "comment"
+			event.TargetID = commentID
+		}
+		CreateNotification(event)
+		mentioned = append(mentioned, userID)
+	}
+	return mentioned
+}
+
+// Function to suggest the users whose name starts with the text typed after @,
+// the names that are emails, the users refusing mentions and the blocked or muted
+// users are never listed
+func AutocompleteUsers(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	type Suggestion struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		AvatarPath string `json:"avatar_path"`
+	}
+	suggestions := []Suggestion{}
+	query := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("q")), "@")
+	if query == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(suggestions)
+		return
+	}
+	// Escape the wildcards of LIKE in the typed text
+	pattern := escapeLike(query) + "%"
+	rows, err := auth.DB.Query(`SELECT u.id, u.username, COALESCE(u.avatar_path, '') FROM users u
+		LEFT JOIN notification_settings s ON s.user_id = u.id
+		WHERE u.username LIKE ?1 ESCAPE '\' AND u.username NOT LIKE '%@%' AND u.id != ?2 AND COALESCE(s.allow_mentions, 1) = 1
+		AND u.id NOT IN (SELECT blocked_id FROM blocks WHERE user_id = ?2)
+		AND u.id NOT IN (SELECT user_id FROM blocks WHERE blocked_id = ?2 AND kind = 'block')
+		ORDER BY length(u.username), u.username LIMIT 8`, pattern, userID)
+	if err != nil {
+		http.Error(w, "Error searching users", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Suggestion
+		if err := rows.Scan(&s.ID, &s.Username, &s.AvatarPath); err == nil {
+			suggestions = append(suggestions, s)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -0,0 +1,672 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Maximum length of a private message, in characters
+const maxMessageLength = 5000
+
+// Maximum number of members of a group conversation
+const maxConversationMembers = 20
+
+// Number of messages returned by page
+const messagesPageSize = 50
+
+// Member of a conversation
+type ConversationMember struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	lastRead sql.NullTime
+}
+
+// Conversation as listed for one of its members
+type Conversation struct {
+	ID          string               `json:"id"`
+	Title       string               `json:"title"`
+	IsGroup     bool                 `json:"is_group"`
+	Members     []ConversationMember `json:"members"`
+	LastMessage string               `json:"last_message"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Unread      int                  `json:"unread"`
+}
+
+// Private message with the members who read it
+type Message struct {
+	ID             string       `json:"id"`
+	ConversationID string       `json:"conversation_id"`
+	SenderID       string       `json:"sender_id"`
+	Username       string       `json:"username"`
+	Content        string       `json:"content"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Attachments    []Attachment `json:"attachments"`
+	ReadBy         []string     `json:"read_by"`
+}
+
+// Messages of a conversation not read by ?1, the messages of the users ?1 blocked do not count
+const unreadMessagesCondition = `m.sender_id != ?1 AND m.created_at > COALESCE(cm.last_read_at, '')
+	AND m.sender_id NOT IN (SELECT blocked_id FROM blocks WHERE user_id = ?1)`
+
+// Function to serve the private messages page
+func ServeMessages(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "web/html/messages.html")
+}
+
+// Function to check whether a user is a member of a conversation
+func isConversationMember(conversationID, userID string) bool {
+	var exists int
+	err := auth.DB.QueryRow("SELECT 1 FROM conversation_members WHERE conversation_id = ? AND user_id = ?", conversationID, userID).Scan(&exists)
+	return err == nil
+}
+
+// Function to check whether a user is a member of the conversation of a message
+func isMessageMember(messageID, userID string) bool {
+	var exists int
+	err := auth.DB.QueryRow(`SELECT 1 FROM messages m JOIN conversation_members cm ON cm.conversation_id = m.conversation_id
+		WHERE m.id = ? AND cm.user_id = ?`, messageID, userID).Scan(&exists)
+	return err == nil
+}
+
+// Function to load the members of a conversation
+func conversationMembers(conversationID string) ([]ConversationMember, error) {
+	rows, err := auth.DB.Query(`SELECT cm.user_id, COALESCE(u.username, ''), cm.last_read_at
+		FROM conversation_members cm LEFT JOIN users u ON u.id = cm.user_id
+		WHERE cm.conversation_id = ? ORDER BY cm.joined_at`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []ConversationMember{}
+	for rows.Next() {
+		var member ConversationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.lastRead); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// Function to find the users of a comma separated list of usernames, the user and
+// the duplicates are left out
+func resolveUsernames(userID, list string) ([]string, error) {
+	var userIDs []string
+	seen := map[string]bool{userID: true}
+	for _, username := range strings.Split(list, ",") {
+		username = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+		if username == "" {
+			continue
+		}
+		var memberID string
+		if err := auth.DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&memberID); err != nil {
+			return nil, fmt.Errorf("user %s not found", username)
+		}
+		if !seen[memberID] {
+			seen[memberID] = true
+			userIDs = append(userIDs, memberID)
+		}
+	}
+	return userIDs, nil
+}
+
+// Function to find the 1:1 conversation between two users
+func directConversation(userID, otherID string) (string, bool) {
+	var conversationID string
+	err := auth.DB.QueryRow(`SELECT c.id FROM conversations c
+		WHERE c.is_group = 0
+		AND EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = c.id AND user_id = ?)
+		AND EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = c.id AND user_id = ?)
+		AND (SELECT COUNT(*) FROM conversation_members WHERE conversation_id = c.id) = 2
+		LIMIT 1`, userID, otherID).Scan(&conversationID)
+	return conversationID, err == nil
+}
+
+// Function to add users to a conversation
+func addConversationMembers(conversationID string, userIDs []string) error {
+	for _, memberID := range userIDs {
+		_, err := auth.DB.Exec("INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, joined_at) VALUES (?, ?, ?)", conversationID, memberID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to list the conversations of the connected user, the most recent first
+func GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT c.id, c.title, c.is_group, c.updated_at,
+		COALESCE((SELECT content FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1), ''),
+		(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND `+unreadMessagesCondition+`)
+		FROM conversations c JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.user_id = ?1
+		ORDER BY c.updated_at DESC`, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving conversations", http.StatusInternalServerError)
+		return
+	}
+	conversations := []Conversation{}
+	for rows.Next() {
+		var c Conversation
+		if err := rows.Scan(&c.ID, &c.Title, &c.IsGroup, &c.UpdatedAt, &c.LastMessage, &c.Unread); err != nil {
+			rows.Close()
+			http.Error(w, "Error reading conversations", http.StatusInternalServerError)
+			return
+		}
+		c.LastMessage = excerpt(c.LastMessage, 100)
+		conversations = append(conversations, c)
+	}
+	rows.Close()
+	for i := range conversations {
+		if conversations[i].Members, err = conversationMembers(conversations[i].ID); err != nil {
+			http.Error(w, "Error retrieving conversation members", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+// Function to cut a text to a number of characters
+func excerpt(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return string([]rune(text)[:length]) + "…"
+}
+
+// Function to start a conversation with one or several users, the 1:1 conversation
+// with a user is reused when it exists
+func CreateConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	members, err := resolveUsernames(userID, r.FormValue("members"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(members) == 0 {
+		http.Error(w, "At least one member is required", http.StatusBadRequest)
+		return
+	}
+	if len(members)+1 > maxConversationMembers {
+		http.Error(w, fmt.Sprintf("Too many members (maximum %d)", maxConversationMembers), http.StatusBadRequest)
+		return
+	}
+	for _, memberID := range members {
+		if isBlocked(userID, memberID) {
+			http.Error(w, "You cannot write to one of these users", http.StatusForbidden)
+			return
+		}
+	}
+	title := strings.TrimSpace(r.FormValue("title"))
+	isGroup := len(members) > 1 || title != ""
+	w.Header().Set("Content-Type", "application/json")
+	if !isGroup {
+		if conversationID, ok := directConversation(userID, members[0]); ok {
+			json.NewEncoder(w).Encode(map[string]string{"message": "Conversation already exists", "id": conversationID})
+			return
+		}
+	}
+	conversationID := uuid.New().String()
+	now := time.Now()
+	_, err = auth.DB.Exec("INSERT INTO conversations (id, title, is_group, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		conversationID, title, isGroup, userID, now, now)
+	if err != nil {
+		http.Error(w, "Error creating conversation", http.StatusInternalServerError)
+		return
+	}
+	if err := addConversationMembers(conversationID, append([]string{userID}, members...)); err != nil {
+		http.Error(w, "Error adding conversation members", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Conversation created successfully", "id": conversationID})
+}
+
+// Function to add users to a group conversation, by one of its members
+func AddConversationMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conversationID := r.FormValue("conversation_id")
+	var isGroup bool
+	err = auth.DB.QueryRow("SELECT is_group FROM conversations WHERE id = ?", conversationID).Scan(&isGroup)
+	if err != nil || !isConversationMember(conversationID, userID) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if !isGroup {
+		http.Error(w, "Members can only be added to a group conversation", http.StatusBadRequest)
+		return
+	}
+	members, err := resolveUsernames(userID, r.FormValue("members"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var count int
+	auth.DB.QueryRow("SELECT COUNT(*) FROM conversation_members WHERE conversation_id = ?", conversationID).Scan(&count)
+	if count+len(members) > maxConversationMembers {
+		http.Error(w, fmt.Sprintf("Too many members (maximum %d)", maxConversationMembers), http.StatusBadRequest)
+		return
+	}
+	for _, memberID := range members {
+		if isBlocked(userID, memberID) {
+			http.Error(w, "You cannot write to one of these users", http.StatusForbidden)
+			return
+		}
+	}
+	if err := addConversationMembers(conversationID, members); err != nil {
+		http.Error(w, "Error adding conversation members", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Members added successfully"})
+}
+
+// Function to leave a conversation, it is deleted with its messages when nobody is left
+func LeaveConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conversationID := r.FormValue("conversation_id")
+	result, err := auth.DB.Exec("DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?", conversationID, userID)
+	if err != nil {
+		http.Error(w, "Error leaving conversation", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	auth.DB.Exec("DELETE FROM notifications WHERE user_id = ? AND target_type = 'conversation' AND target_id = ?", userID, conversationID)
+	var remaining int
+	auth.DB.QueryRow("SELECT COUNT(*) FROM conversation_members WHERE conversation_id = ?", conversationID).Scan(&remaining)
+	if remaining == 0 {
+		deleteConversationData(conversationID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Conversation left successfully"})
+}
+
+// Function to retrieve a page of messages of a conversation, older than the "before" date when given.
+// The messages of the users blocked by the reader are left out.
+func GetMessages(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conversationID := r.URL.Query().Get("conversation_id")
+	if !isConversationMember(conversationID, userID) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	before := time.Now()
+	if value := r.URL.Query().Get("before"); value != "" {
+		if before, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		// The dates of the messages are stored in local time and compared as text
+		before = before.Local()
+	}
+	rows, err := auth.DB.Query(`SELECT m.id, m.sender_id, COALESCE(u.username, ''), m.content, m.created_at
+		FROM messages m LEFT JOIN users u ON u.id = m.sender_id
+		WHERE m.conversation_id = ? AND m.created_at < ?
+		AND m.sender_id NOT IN (SELECT blocked_id FROM blocks WHERE user_id = ?)
+		ORDER BY m.created_at DESC LIMIT ?`, conversationID, before, userID, messagesPageSize)
+	if err != nil {
+		http.Error(w, "Error retrieving messages", http.StatusInternalServerError)
+		return
+	}
+	messages := []Message{}
+	var messageIDs []string
+	for rows.Next() {
+		message := Message{ConversationID: conversationID, ReadBy: []string{}}
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.Username, &message.Content, &message.CreatedAt); err != nil {
+			rows.Close()
+			http.Error(w, "Error reading messages", http.StatusInternalServerError)
+			return
+		}
+		messages = append(messages, message)
+		messageIDs = append(messageIDs, message.ID)
+	}
+	rows.Close()
+
+	// Read receipts come from the date each member last read the conversation
+	members, err := conversationMembers(conversationID)
+	if err != nil {
+		http.Error(w, "Error retrieving conversation members", http.StatusInternalServerError)
+		return
+	}
+	attachments := loadAttachments("message_id", messageIDs)
+	// Oldest first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	for i := range messages {
+		for _, a := range attachments[messages[i].ID] {
+			messages[i].Attachments = append(messages[i].Attachments, signAttachment(a))
+		}
+		for _, member := range members {
+			if member.UserID != messages[i].SenderID && member.lastRead.Valid && !member.lastRead.Time.Before(messages[i].CreatedAt) {
+				messages[i].ReadBy = append(messages[i].ReadBy, member.Username)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// Function to send a message with its attachments to a conversation and notify the other members
+func SendMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Reject oversized uploads before reading the form
+	if !parseUploadForm(w, r) {
+		return
+	}
+	conversationID := r.FormValue("conversation_id")
+	content := strings.TrimSpace(r.FormValue("content"))
+	var isGroup bool
+	err = auth.DB.QueryRow("SELECT is_group FROM conversations WHERE id = ?", conversationID).Scan(&isGroup)
+	if err != nil || !isConversationMember(conversationID, userID) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	files := uploadedFiles(r)
+	if content == "" && len(files) == 0 {
+		http.Error(w, "Message is empty", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(content) > maxMessageLength {
+		http.Error(w, fmt.Sprintf("Message too long (maximum %d characters)", maxMessageLength), http.StatusBadRequest)
+		return
+	}
+	members, err := conversationMembers(conversationID)
+	if err != nil {
+		http.Error(w, "Error retrieving conversation members", http.StatusInternalServerError)
+		return
+	}
+	// Nobody can write in a 1:1 conversation once one of the two blocked the other
+	if !isGroup {
+		for _, member := range members {
+			if member.UserID != userID && isBlocked(userID, member.UserID) {
+				http.Error(w, "You cannot write to this user", http.StatusForbidden)
+				return
+			}
+		}
+	}
+	// Save the attached files
+	attachments, err := saveAttachments(userID, "message_id", files)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := Message{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Content:        content,
+		CreatedAt:      time.Now(),
+		ReadBy:         []string{},
+	}
+	_, err = auth.DB.Exec("INSERT INTO messages (id, conversation_id, sender_id, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		message.ID, conversationID, userID, content, message.CreatedAt)
+	if err != nil {
+		http.Error(w, "Error sending message", http.StatusInternalServerError)
+		return
+	}
+	if err := insertAttachments(userID, "message_id", message.ID, attachments); err != nil {
+		http.Error(w, "Error saving attachments", http.StatusInternalServerError)
+		return
+	}
+	auth.DB.Exec("UPDATE conversations SET updated_at = ? WHERE id = ?", message.CreatedAt, conversationID)
+	auth.DB.Exec("UPDATE conversation_members SET last_read_at = ? WHERE conversation_id = ? AND user_id = ?", message.CreatedAt, conversationID, userID)
+
+	// The members who blocked or muted the sender are not told
+	for _, member := range members {
+		if member.UserID == userID {
+			message.Username = member.Username
+			continue
+		}
+		CreateNotification(NotificationEvent{
+			UserID:     member.UserID,
+			ActorID:    userID,
+			Action:     "message",
+			TargetType: "conversation",
+			TargetID:   conversationID,
+			Template:   "message",
+		})
+	}
+	for _, a := range attachments {
+		message.Attachments = append(message.Attachments, signAttachment(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// Function to mark a conversation as read by the connected user, which the other members see
+func MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conversationID := r.FormValue("conversation_id")
+	result, err := auth.DB.Exec("UPDATE conversation_members SET last_read_at = ? WHERE conversation_id = ? AND user_id = ?", time.Now(), conversationID, userID)
+	if err != nil {
+		http.Error(w, "Error marking conversation as read", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	// The notifications of the conversation are read with it
+	auth.DB.Exec("UPDATE notifications SET seen = 1 WHERE user_id = ? AND target_type = 'conversation' AND target_id = ?", userID, conversationID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Conversation marked as read"})
+}
+
+// Function to count the unread messages of the connected user
+func GetUnreadMessagesCount(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var count int
+	err = auth.DB.QueryRow(`SELECT COUNT(*) FROM messages m
+		JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.user_id = ?1
+		WHERE `+unreadMessagesCondition, userID).Scan(&count)
+	if err != nil {
+		http.Error(w, "Error counting messages", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unread": count})
+}
+
+// Function to allow the members of a conversation to report a message
+func ReportMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	messageID := r.FormValue("id")
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if messageID == "" || reason == "" {
+		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+		return
+	}
+	if !isMessageMember(messageID, userID) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	_, err = auth.DB.Exec("INSERT INTO message_reports (message_id, reporter_id, reason, status, created_at) VALUES (?, ?, ?, 'pending', ?)",
+		messageID, userID, reason, time.Now())
+	if err != nil {
+		log.Println("Database error:", err)
+		http.Error(w, "Error creating report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report submitted successfully"})
+}
+
+// Function to fetch the reported messages, only the reported message is shown
+func GetMessageReports(w http.ResponseWriter, r *http.Request) {
+	rows, err := auth.DB.Query(`SELECT mr.id, mr.message_id, mr.reason, mr.status, m.content, COALESCE(u.username, ''), m.created_at
+		FROM message_reports mr
+		JOIN messages m ON m.id = mr.message_id
+		LEFT JOIN users u ON u.id = m.sender_id
+		ORDER BY mr.created_at`)
+	if err != nil {
+		http.Error(w, "Error fetching reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	reports := []map[string]any{}
+	for rows.Next() {
+		var id int
+		var messageID, reason, status, content, sender string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &messageID, &reason, &status, &content, &sender, &createdAt); err != nil {
+			http.Error(w, "Error reading report data", http.StatusInternalServerError)
+			return
+		}
+		reports = append(reports, map[string]any{
+			"id":         id,
+			"message_id": messageID,
+			"reason":     reason,
+			"status":     status,
+			"content":    content,
+			"sender":     sender,
+			"created_at": createdAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// Function to tell the reporter of a message whether the report was resolved or rejected
+func notifyMessageReportOutcome(reportID, status string) {
+	var reporterID string
+	if auth.DB.QueryRow("SELECT reporter_id FROM message_reports WHERE id = ?", reportID).Scan(&reporterID) != nil {
+		return
+	}
+	CreateNotification(NotificationEvent{
+		UserID:     reporterID,
+		Action:     "moderation",
+		TargetType: "message_report",
+		TargetID:   reportID,
+		Template:   "message_report_" + status,
+	})
+}
+
+// Function to resolve the report of a message, the message is deleted
+func ResolveMessageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	reportID := r.FormValue("id")
+	var messageID string
+	if auth.DB.QueryRow("SELECT message_id FROM message_reports WHERE id = ?", reportID).Scan(&messageID) != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	// Every reporter of the message is told
+	rows, err := auth.DB.Query("SELECT id FROM message_reports WHERE message_id = ?", messageID)
+	if err == nil {
+		var reportIDs []string
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil {
+				reportIDs = append(reportIDs, id)
+			}
+		}
+		rows.Close()
+		for _, id := range reportIDs {
+			notifyMessageReportOutcome(id, "resolved")
+		}
+	}
+	if _, err := auth.DB.Exec("DELETE FROM messages WHERE id = ?", messageID); err != nil {
+		http.Error(w, "Error deleting message", http.StatusInternalServerError)
+		return
+	}
+	deleteMessageData(messageID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report resolved and message deleted successfully"})
+}
+
+// Function to reject the report of a message
+func RejectMessageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	reportID := r.FormValue("id")
+	notifyMessageReportOutcome(reportID, "rejected")
+	result, err := auth.DB.Exec("DELETE FROM message_reports WHERE id = ?", reportID)
+	if err != nil {
+		http.Error(w, "Error deleting rejected report", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report rejected and deleted successfully"})
+}
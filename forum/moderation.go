@@ -32,7 +32,7 @@ func DeletePostByAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 	// Check if the post exists in the database
 	var postOwner string
-	err := auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", postID).Scan(&postOwner)
+	err := auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ? AND deleted_at IS NULL", postID).Scan(&postOwner)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
@@ -40,12 +40,16 @@ func DeletePostByAdmin(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
 		return
 	}
-	// Delete the post from the database
-	_, err = auth.DB.Exec("DELETE FROM posts WHERE id = ?", postID)
-	if err != nil {
+	// Move the post to the trash, it can be restored until the end of the restore window
+	moderatorID, _ := auth.GetUserFromSession(r)
+	if _, err := trashPost(postID, moderatorID); err != nil {
 		http.Error(w, "Error deleting post", http.StatusInternalServerError)
 		return
 	}
+	// The removal costs reputation to the author, given back if the post is restored
+	if postOwner != moderatorID {
+		recordReputationEvent(postOwner, postID, "post_removed", postRemovedReputation)
+	}
 	// Respond with a success message
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted successfully"})
@@ -79,6 +83,7 @@ func DeleteCommentAdmin(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error deleting comment", http.StatusInternalServerError)
         return
     }
+    deleteCommentData(commentID)
 }
 
 // Function to allows users to report posts
@@ -103,10 +108,12 @@ func ReportPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Insert the report into the database
-	query := "INSERT INTO reports (post_id, reason, status) VALUES (?, ?, 'pending')"
+	// The reporter is kept to tell them the outcome
+	reporterID, _ := auth.GetUserFromSession(r)
+	query := "INSERT INTO reports (post_id, reason, status, reporter_id) VALUES (?, ?, 'pending', NULLIF(?, ''))"
 	log.Println("Executing SQL Query:", query)
 
-	_, err := auth.DB.Exec(query, postID, reason)
+	_, err := auth.DB.Exec(query, postID, reason, reporterID)
 	if err != nil {
 		log.Println("Database error:", err)
 		http.Error(w, "Error creating report", http.StatusInternalServerError)
@@ -117,6 +124,23 @@ func ReportPost(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Report submitted successfully"})
 }
 
+// Function to tell the reporter of a report whether it was resolved or rejected
+func notifyReportOutcome(reportID, status string) {
+    var reporterID, postID string
+    err := auth.DB.QueryRow("SELECT COALESCE(reporter_id, ''), post_id FROM reports WHERE id = ?", reportID).Scan(&reporterID, &postID)
+    if err != nil || reporterID == "" {
+        return
+    }
+    CreateNotification(NotificationEvent{
+        UserID:     reporterID,
+        Action:     "moderation",
+        PostID:     postID,
+        TargetType: "report",
+        TargetID:   reportID,
+        Template:   "report_" + status,
+    })
+}
+
 // Function to allows the admin to resolve a report
 func ResolveReport(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
@@ -134,6 +158,12 @@ func ResolveReport(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error resolving report", http.StatusInternalServerError)
         return
     }
+    notifyReportOutcome(reportID, "resolved")
+    // An upheld report costs reputation to the author of the post
+    var postID, authorID string
+    if auth.DB.QueryRow("SELECT p.id, p.user_id FROM reports r JOIN posts p ON p.id = r.post_id WHERE r.id = ?", reportID).Scan(&postID, &authorID) == nil {
+        recordReputationEvent(authorID, postID, "report_upheld", reportUpheldReputation)
+    }
     // Delete the report after resolving it
     _, err = auth.DB.Exec("DELETE FROM reports WHERE id = ?", reportID)
     if err != nil {
@@ -162,6 +192,7 @@ func RejectReport(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error rejecting report", http.StatusInternalServerError)
         return
     }
+    notifyReportOutcome(reportID, "rejected")
     // Delete the report after rejecting it
     _, err = auth.DB.Exec("DELETE FROM reports WHERE id = ?", reportID)
     if err != nil {
@@ -207,74 +238,6 @@ func GetReports(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(reports)
 }
 
-// Function to allows the admin to create a new category
-func CreateCategory(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost {
-        http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-        return
-    }
-    // Retrieve the category name from the request
-    categoryName := r.FormValue("name")
-    if categoryName == "" {
-        http.Error(w, "Category name is required", http.StatusBadRequest)
-        return
-    }
-    // Check if the category already exists
-    var existingCategory string
-    err := auth.DB.QueryRow("SELECT name FROM categories WHERE name = ?", categoryName).Scan(&existingCategory)
-    if err == nil {
-        http.Error(w, "Category already exists", http.StatusBadRequest)
-        return
-    }
-    // If a scan error occurs (category not found), continue
-    if err != sql.ErrNoRows {
-        http.Error(w, "Error checking category existence", http.StatusInternalServerError)
-        return
-    }
-    // Insert the new category into the database
-    result, err := auth.DB.Exec("INSERT INTO categories (name) VALUES (?)", categoryName)
-    if err != nil {
-        http.Error(w, "Error creating category", http.StatusInternalServerError)
-        return
-    }
-    // Retrieve the ID of the inserted category
-    lastInsertID, err := result.LastInsertId()
-    if err != nil {
-        http.Error(w, "Error retrieving category ID", http.StatusInternalServerError)
-        return
-    }
-    // Respond with the new category ID
-    w.Header().Set("Content-Type", "application/json")
-
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "message": "Category created successfully",
-        "id":      lastInsertID,
-    })
-}
-
-// Function to allows the admin to delete a category
-func DeleteCategory(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost {
-        http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-        return
-    }
-    // Retrieve the category ID to delete
-    categoryID := r.FormValue("id")
-    if categoryID == "" {
-        http.Error(w, "Category ID is required", http.StatusBadRequest)
-        return
-    }
-    // Delete the category from the database
-    _, err := auth.DB.Exec("DELETE FROM categories WHERE id = ?", categoryID)
-    if err != nil {
-        http.Error(w, "Error deleting category", http.StatusInternalServerError)
-        return
-    }
-    // Respond with a success message
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted successfully"})
-}
-
 // RequestModerator handles a user's request for moderator role
 type ModeratorRequest struct {
 	UserID string `json:"user_id"`
@@ -385,6 +348,7 @@ func ApproveModerator(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    CreateNotification(NotificationEvent{UserID: userID, Action: "moderation", TargetType: "user", TargetID: userID, Template: "promotion_approved"})
     fmt.Fprintln(w, "User has been promoted to moderator")
 }
 
@@ -401,6 +365,10 @@ func RejectModerator(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error rejecting request", http.StatusInternalServerError)
         return
     }
+    var userID string
+    if auth.DB.QueryRow("SELECT user_id FROM promotion_requests WHERE id = ?", requestID).Scan(&userID) == nil {
+        CreateNotification(NotificationEvent{UserID: userID, Action: "moderation", TargetType: "user", TargetID: userID, Template: "promotion_rejected"})
+    }
     fmt.Fprintln(w, "Request rejected successfully")
 }
 
@@ -0,0 +1,133 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"net/http"
+)
+
+// Types of notification a user can configure, in display order
+var notificationTypes = []string{"comment", "reply", "reaction", "mention", "subscription", "message", "moderation", "achievement"}
+
+// Type of notification of each action, actions without a type are always shown in the app
+var notificationKinds = map[string]string{
+	"comment":         "comment",
+	"reply":           "reply",
+	"like":            "reaction",
+	"accepted_answer": "reaction",
+	"mention":         "mention",
+	"subscription":    "subscription",
+	"message":         "message",
+	"moderation":      "moderation",
+	"achievement":     "achievement",
+}
+
+// Preference of a user for a type of notification
+type NotificationPreference struct {
+	Type  string `json:"type"`
+	InApp bool   `json:"in_app"`
+	Email bool   `json:"email"`
+}
+
+// Function to return the channels on which a user receives an action, in the app and
+// not by email unless the user changed it
+func notificationChannels(userID, action string) (inApp, email bool) {
+	kind, ok := notificationKinds[action]
+	if !ok {
+		return true, false
+	}
+	return preferenceChannels(userID, kind)
+}
+
+// Function to read the channels chosen by a user for a type of notification
+func preferenceChannels(userID, kind string) (inApp, email bool) {
+	inApp = true
+	rows, err := auth.DB.Query("SELECT channel, enabled FROM notification_preferences WHERE user_id = ? AND type = ?", userID, kind)
+	if err != nil {
+		return inApp, email
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var channel string
+		var enabled bool
+		if rows.Scan(&channel, &enabled) != nil {
+			continue
+		}
+		if channel == "in_app" {
+			inApp = enabled
+		} else if channel == "email" {
+			email = enabled
+		}
+	}
+	return inApp, email
+}
+
+// Function to retrieves the notification preferences and the digest frequency of the user
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	preferences := []NotificationPreference{}
+	for _, kind := range notificationTypes {
+		inApp, email := preferenceChannels(userID, kind)
+		preferences = append(preferences, NotificationPreference{Type: kind, InApp: inApp, Email: email})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences":    preferences,
+		"digest":         digestFrequency(userID),
+		"allow_mentions": allowMentions(userID),
+	})
+}
+
+// Function to change a channel of a type of notification, the digest frequency or whether
+// the user can be mentioned
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if digest := r.FormValue("digest"); digest != "" {
+		if digest != "none" && digest != "daily" && digest != "weekly" {
+			http.Error(w, "Invalid digest frequency", http.StatusBadRequest)
+			return
+		}
+		_, err = auth.DB.Exec("INSERT INTO notification_settings (user_id, digest) VALUES (?, ?) ON CONFLICT(user_id) DO UPDATE SET digest = excluded.digest", userID, digest)
+		if err != nil {
+			http.Error(w, "Error saving preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	if allow := r.FormValue("allow_mentions"); allow != "" {
+		_, err = auth.DB.Exec("INSERT INTO notification_settings (user_id, allow_mentions) VALUES (?, ?) ON CONFLICT(user_id) DO UPDATE SET allow_mentions = excluded.allow_mentions", userID, allow == "true")
+		if err != nil {
+			http.Error(w, "Error saving preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	if kind := r.FormValue("type"); kind != "" {
+		known := false
+		for _, t := range notificationTypes {
+			known = known || t == kind
+		}
+		channel := r.FormValue("channel")
+		if !known || (channel != "in_app" && channel != "email") {
+			http.Error(w, "Invalid notification type or channel", http.StatusBadRequest)
+			return
+		}
+		_, err = auth.DB.Exec("INSERT INTO notification_preferences (user_id, type, channel, enabled) VALUES (?, ?, ?, ?) ON CONFLICT(user_id, type, channel) DO UPDATE SET enabled = excluded.enabled", userID, kind, channel, r.FormValue("enabled") == "true")
+		if err != nil {
+			http.Error(w, "Error saving preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Preferences updated successfully"})
+}
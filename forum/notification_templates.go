@@ -0,0 +1,147 @@
+package forum
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Language used when the client does not ask for a supported one
+const defaultLocale = "fr"
+
+// Texts of the notifications by locale and template key, the second text is used
+// when several people caused the event
+var notificationTemplates = map[string]map[string][2]string{
+	"fr": {
+		"comment":                 {"{actor} a commenté {target}", "{count} personnes ont commenté {target}"},
+		"reply":                   {"{actor} a répondu dans {title}", "{count} personnes ont répondu dans {title}"},
+		"like":                    {"{actor} a aimé {target}", "{count} personnes ont aimé {target}"},
+		"dislike":                 {"{actor} n'a pas aimé {target}", "{count} personnes n'ont pas aimé {target}"},
+		"mention":                 {"{actor} vous a mentionné dans {title}", "{count} personnes vous ont mentionné dans {title}"},
+		"new_post":                {"{actor} a publié {title}", ""},
+		"accepted_answer":         {"{actor} a accepté votre réponse dans {title}", ""},
+		"message":                 {"{actor} vous a envoyé un message", "{count} personnes vous ont envoyé des messages"},
+		"report_resolved":         {"Votre signalement de {title} a été traité, merci.", ""},
+		"report_rejected":         {"Votre signalement de {title} a été examiné et rejeté.", ""},
+		"message_report_resolved": {"Votre signalement d'un message a été traité, merci.", ""},
+		"message_report_rejected": {"Votre signalement d'un message a été examiné et rejeté.", ""},
+		"promotion_approved":      {"Votre demande pour devenir modérateur a été acceptée.", ""},
+		"promotion_rejected":      {"Votre demande pour devenir modérateur a été refusée.", ""},
+		"login_burst":             {"{failures} tentatives de connexion échouées depuis {ip}. Changez votre mot de passe si ce n'était pas vous.", ""},
+		"trust_level":             {"Vous avez atteint le niveau de confiance {level} ({name}).", ""},
+		"badge_awarded":           {"Vous avez obtenu le badge {icon} {badge}.", ""},
+		"post_approved":           {"Votre post {title} a été approuvé.", ""},
+		"post_rejected":           {"Votre post {title} a été refusé par la modération. {reason}", ""},
+	},
+	"en": {
+		"comment":                 {"{actor} commented on {target}", "{count} people commented on {target}"},
+		"reply":                   {"{actor} replied in {title}", "{count} people replied in {title}"},
+		"like":                    {"{actor} liked {target}", "{count} people liked {target}"},
+		"dislike":                 {"{actor} disliked {target}", "{count} people disliked {target}"},
+		"mention":                 {"{actor} mentioned you in {title}", "{count} people mentioned you in {title}"},
+		"new_post":                {"{actor} published {title}", ""},
+		"accepted_answer":         {"{actor} accepted your answer in {title}", ""},
+		"message":                 {"{actor} sent you a message", "{count} people sent you messages"},
+		"report_resolved":         {"Your report of {title} was handled, thank you.", ""},
+		"report_rejected":         {"Your report of {title} was reviewed and rejected.", ""},
+		"message_report_resolved": {"Your report of a message was handled, thank you.", ""},
+		"message_report_rejected": {"Your report of a message was reviewed and rejected.", ""},
+		"promotion_approved":      {"Your request to become a moderator was accepted.", ""},
+		"promotion_rejected":      {"Your request to become a moderator was declined.", ""},
+		"login_burst":             {"{failures} failed login attempts from {ip}. Change your password if it was not you.", ""},
+		"trust_level":             {"You reached trust level {level} ({name}).", ""},
+		"badge_awarded":           {"You earned the badge {icon} {badge}.", ""},
+		"post_approved":           {"Your post {title} was approved.", ""},
+		"post_rejected":           {"Your post {title} was rejected by the moderators. {reason}", ""},
+	},
+}
+
+// Words completing the templates by locale
+var notificationWords = map[string]map[string]string{
+	"fr": {"someone": "Quelqu'un", "post": "votre post", "comment": "votre commentaire", "on": "sur", "a_post": "un post", "open": "« ", "close": " »"},
+	"en": {"someone": "Someone", "post": "your post", "comment": "your comment", "on": "on", "a_post": "a post", "open": "\"", "close": "\""},
+}
+
+// Function to choose the locale of the texts from the Accept-Language header
+func notificationLocale(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		lang := strings.SplitN(tag, "-", 2)[0]
+		if _, ok := notificationTemplates[lang]; ok {
+			return lang
+		}
+	}
+	return defaultLocale
+}
+
+// Function to render the text of a notification in a locale, the stored content
+// is shown as is for the notifications without a known template
+func renderNotification(n Notification, locale string) string {
+	templates, ok := notificationTemplates[locale]
+	if !ok {
+		templates = notificationTemplates[defaultLocale]
+		locale = defaultLocale
+	}
+	texts, ok := templates[n.Template]
+	if !ok {
+		return n.Content
+	}
+	text := texts[0]
+	if n.Count > 1 && texts[1] != "" {
+		text = texts[1]
+	}
+	words := notificationWords[locale]
+
+	// The usernames that are emails are hidden
+	actor := n.Username
+	if actor == "" || strings.Contains(actor, "@") {
+		actor = words["someone"]
+	}
+	title := words["a_post"]
+	if n.postTitle != "" {
+		title = words["open"] + n.postTitle + words["close"]
+	}
+	target := words["post"]
+	if n.TargetType == "comment" {
+		target = words["comment"]
+		if n.postTitle != "" {
+			target += " " + words["on"] + " " + title
+		}
+	} else if n.postTitle != "" {
+		target += " " + title
+	}
+	replacements := []string{"{actor}", actor, "{count}", strconv.Itoa(n.Count), "{title}", title, "{target}", target}
+	for key, value := range n.Params {
+		replacements = append(replacements, "{"+key+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// Function to build the link opening the target of a notification
+func notificationLink(n Notification) string {
+	switch n.TargetType {
+	case "post", "comment", "report":
+		if n.PostID == "" {
+			return "/forum"
+		}
+		link := "/forum?post=" + n.PostID
+		if n.TargetType == "comment" {
+			link += "#comment-" + n.TargetID
+		} else if commentID := n.Params["comment_id"]; commentID != "" {
+			link += "#comment-" + commentID
+		}
+		return link
+	case "conversation":
+		return "/messages?conversation=" + n.TargetID
+	case "message_report":
+		return "/messages"
+	case "user":
+		switch n.Template {
+		case "login_burst":
+			return "/edit_user"
+		case "promotion_approved":
+			return "/moderator"
+		}
+	}
+	return "/forum"
+}
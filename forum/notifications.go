@@ -8,70 +8,198 @@ import (
 	"github.com/google/uuid"
 )
 
-// Function to creates a new notification for a user related to a post
-func CreateNotification(userID, postID, action, content string) {
+// Actions whose repeated events on a target are merged into one unseen notification
+var coalescedActions = map[string]bool{"like": true, "comment": true, "reply": true, "mention": true, "message": true}
+
+// Event notified to a user
+type NotificationEvent struct {
+	UserID     string // recipient
+	ActorID    string // user who caused the event, empty for the forum itself
+	Action     string // kind used by the preferences: comment, reply, like, mention, message, moderation, security
+	PostID     string // post the event belongs to, used by the links
+	TargetType string // post, comment, user, conversation, report or message_report
+	TargetID   string
+	Template   string            // key of the text in notificationTemplates
+	Content    string            // excerpt of the content, shown with the text
+	Params     map[string]string // values of the template
+}
+
+// Notification as sent to the client, the text is rendered in the language of the request
+type Notification struct {
+	ID         string            `json:"id"`
+	PostID     string            `json:"post_id"`
+	Action     string            `json:"action"`
+	Content    string            `json:"content"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Seen       bool              `json:"seen"`
+	Count      int               `json:"count"`
+	ActorID    string            `json:"actor_id"`
+	Username   string            `json:"username"`
+	TargetType string            `json:"target_type"`
+	TargetID   string            `json:"target_id"`
+	Template   string            `json:"template"`
+	Text       string            `json:"text"`
+	Link       string            `json:"link"`
+	Params     map[string]string `json:"-"`
+	postTitle  string
+}
+
+// Function to creates a new notification for a user, on the channels chosen by the user.
+// A repeated event is counted on the unseen notification of the same target instead.
+// Nothing is sent when the user muted the actor or when one of them blocked the other.
+func CreateNotification(event NotificationEvent) {
+	if event.ActorID != "" && !canReach(event.ActorID, event.UserID) {
+		return
+	}
+	inApp, email := notificationChannels(event.UserID, event.Action)
+	if !inApp && !email {
+		return
+	}
+	params := "{}"
+	if len(event.Params) > 0 {
+		if data, err := json.Marshal(event.Params); err == nil {
+			params = string(data)
+		}
+	}
+	if coalescedActions[event.Action] {
+		var notificationID string
+		err := auth.DB.QueryRow("SELECT id FROM notifications WHERE user_id = ? AND template = ? AND target_type = ? AND target_id = ? AND seen = 0 LIMIT 1",
+			event.UserID, event.Template, event.TargetType, event.TargetID).Scan(&notificationID)
+		if err == nil {
+			res, err := auth.DB.Exec("INSERT OR IGNORE INTO notification_actors (notification_id, user_id) VALUES (?, ?)", notificationID, event.ActorID)
+			if err != nil {
+				return
+			}
+			// The same person acting again does not count twice
+			if n, _ := res.RowsAffected(); n == 0 {
+				return
+			}
+			auth.DB.Exec("UPDATE notifications SET count = count + 1, actor_id = ?, content = ?, params = ?, created_at = ?, in_app = ?, email = ?, emailed = 0 WHERE id = ?",
+				event.ActorID, event.Content, params, time.Now(), inApp, email, notificationID)
+			return
+		}
+	}
 	notificationID := uuid.New().String()
-	_, err := auth.DB.Exec("INSERT INTO notifications (id, user_id, post_id, action, content, created_at) VALUES (?, ?, ?, ?, ?, ?)", notificationID, userID, postID, action, content, time.Now())
+	_, err := auth.DB.Exec(`INSERT INTO notifications (id, user_id, post_id, action, content, created_at, in_app, email, actor_id, target_type, target_id, template, params)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)`,
+		notificationID, event.UserID, event.PostID, event.Action, event.Content, time.Now(), inApp, email, event.ActorID, event.TargetType, event.TargetID, event.Template, params)
 	if err != nil {
 		return
 	}
+	if event.ActorID != "" {
+		auth.DB.Exec("INSERT OR IGNORE INTO notification_actors (notification_id, user_id) VALUES (?, ?)", notificationID, event.ActorID)
+	}
+}
+
+// Function to load the notifications of a user matching a condition, rendered in a locale
+func loadNotifications(locale, userID, condition string, args ...interface{}) ([]Notification, error) {
+	rows, err := auth.DB.Query(`SELECT n.id, COALESCE(n.post_id, ''), n.action, n.content, n.created_at, n.seen, n.count,
+		COALESCE(n.actor_id, ''), COALESCE(u.username, ''), COALESCE(n.target_type, ''), COALESCE(n.target_id, ''),
+		COALESCE(n.template, ''), COALESCE(n.params, '{}'), COALESCE(p.title, '')
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		LEFT JOIN posts p ON p.id = n.post_id
+		WHERE n.user_id = ? AND `+condition+` ORDER BY n.created_at DESC`, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var notif Notification
+		var params string
+		if err := rows.Scan(&notif.ID, &notif.PostID, &notif.Action, &notif.Content, &notif.CreatedAt, &notif.Seen, &notif.Count,
+			&notif.ActorID, &notif.Username, &notif.TargetType, &notif.TargetID, &notif.Template, &params, &notif.postTitle); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(params), &notif.Params)
+		notif.Text = renderNotification(notif, locale)
+		notif.Link = notificationLink(notif)
+		notifications = append(notifications, notif)
+	}
+	return notifications, rows.Err()
 }
 
 // Function to retrieves a list of notifications for a user
 func GetNotifications(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID
-    userID, err := auth.GetUserFromSession(r)
-    if err != nil {
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-	// Query the notifications from the database
-    rows, err := auth.DB.Query("SELECT n.id, n.post_id, n.action, n.content, n.created_at, n.seen, u.username FROM notifications n JOIN users u ON n.user_id = u.id WHERE n.user_id = ? ORDER BY n.created_at DESC", userID)
-
-    if err != nil {
-        http.Error(w, "Error retrieving notifications", http.StatusInternalServerError)
-        return
-    }
-    defer rows.Close()
-
-	// Define a struct for a notification
-    type Notification struct {
-        ID        string    `json:"id"`
-        PostID    string    `json:"post_id"`
-        Action    string    `json:"action"`
-        Content   string    `json:"content"`
-        CreatedAt time.Time `json:"created_at"`
-        Seen      bool      `json:"seen"`
-        Username  string    `json:"username"`
-    }
-    var notifications []Notification
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	notifications, err := loadNotifications(notificationLocale(r), userID, "n.in_app = 1")
+	if err != nil {
+		http.Error(w, "Error retrieving notifications", http.StatusInternalServerError)
+		return
+	}
+	// Set the response header to JSON
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
 
-	// Loop through the rows and create a list of notifications
-    for rows.Next() {
-        var notif Notification
-        if err := rows.Scan(&notif.ID, &notif.PostID, &notif.Action, &notif.Content, &notif.CreatedAt, &notif.Seen, &notif.Username); err != nil {
-            http.Error(w, "Error reading notifications", http.StatusInternalServerError)
-            return
-        }
-        // Récupérer l'acteur de la notification
-        var actorID string
-        var query string
+// Function to count the unread notifications of the user
+func GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var unread int
+	if err := auth.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND in_app = 1 AND seen = 0", userID).Scan(&unread); err != nil {
+		http.Error(w, "Error counting notifications", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unread": unread})
+}
 
-		// Retrieve the actor of the notification based on the action (like or comment)
-        if notif.Action == "comment" {
-            query = "SELECT user_id FROM comments WHERE post_id = ? ORDER BY created_at DESC LIMIT 1"
-        } else {
-            query = "SELECT user_id FROM likes WHERE post_id = ? AND type = 'like' ORDER BY created_at DESC LIMIT 1"
-        }
-        if err := auth.DB.QueryRow(query, notif.PostID).Scan(&actorID); err == nil {
-            auth.DB.QueryRow("SELECT username FROM users WHERE id = ?", actorID).Scan(&notif.Username)
-        }
-        notifications = append(notifications, notif)
+// Function to mark the selected notifications as read, their IDs are sent in "id" fields
+func MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r.ParseForm()
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		http.Error(w, "Notification ID is required", http.StatusBadRequest)
+		return
+	}
+	for _, id := range ids {
+		if _, err := auth.DB.Exec("UPDATE notifications SET seen = 1 WHERE id = ? AND user_id = ?", id, userID); err != nil {
+			http.Error(w, "Error updating notifications", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Notifications marked as read"})
+}
 
-    }
-    // Set the response header to JSON
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(notifications)
+// Function to delete all the read notifications of the user
+func DeleteReadNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	auth.DB.Exec("DELETE FROM notification_actors WHERE notification_id IN (SELECT id FROM notifications WHERE user_id = ? AND seen = 1)", userID)
+	if _, err := auth.DB.Exec("DELETE FROM notifications WHERE user_id = ? AND seen = 1", userID); err != nil {
+		http.Error(w, "Error deleting notifications", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Read notifications deleted"})
 }
 
 // Function to marks all notifications as "seen" for a user
@@ -90,7 +218,8 @@ func MarkNotificationsAsSeen(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// function to retrieves the most recent comments for a specific post
+// function to retrieves the comments of a specific post written by others since the user
+// last read it, all of them for a visitor
 func GetNewComments(w http.ResponseWriter, r *http.Request) {
 	// Get the post ID 
     postID := r.URL.Query().Get("post_id")
@@ -98,8 +227,19 @@ func GetNewComments(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Post ID is required", http.StatusBadRequest)
         return
     }
-	// Query the comments from the database
-    rows, err := auth.DB.Query("SELECT id, user_id, content, created_at FROM comments WHERE post_id = ? ORDER BY created_at DESC", postID)
+    // The comments of a post in the trash or not published yet are hidden with it
+    if status, err := postStatus(postID); err != nil {
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return
+    } else if status == "deleted" {
+        http.Error(w, "Post not found", http.StatusNotFound)
+        return
+    }
+	// Query the comments from the database, without those of the users blocked or muted by the reader
+    userID, _ := auth.GetUserFromSession(r)
+    rows, err := auth.DB.Query(`SELECT c.id, c.user_id, c.content, c.created_at FROM comments c JOIN posts p ON p.id = c.post_id
+        WHERE c.post_id = ? AND c.user_id NOT IN `+hiddenUsers+` AND c.user_id != ? AND c.created_at > `+readMarker+`
+        ORDER BY c.created_at DESC`, append([]interface{}{postID}, readArgs(userID, 4)...)...)
     if err != nil {
         http.Error(w, "Error retrieving comments", http.StatusInternalServerError)
         return
@@ -147,6 +287,7 @@ func DeleteNotification(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Error deleting notification", http.StatusInternalServerError)
         return
     }
+    auth.DB.Exec("DELETE FROM notification_actors WHERE notification_id = ? AND NOT EXISTS (SELECT 1 FROM notifications WHERE id = ?)", notifID, notifID)
 
     w.WriteHeader(http.StatusOK)
 }
\ No newline at end of file
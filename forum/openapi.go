@@ -0,0 +1,194 @@
+package forum
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameters of a path, like {id}
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Reference to the error response shared by the operations
+var apiErrorResponse = map[string]any{"$ref": "#/components/responses/Error"}
+
+// Function to build the schema of a Go type from its JSON tags. The structs are added to the
+// components and referenced, the description, enum and format tags of their fields are kept.
+func openAPISchema(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return withAttributes(openAPISchema(t.Elem(), schemas), map[string]any{"nullable": true})
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		// Reserved before the fields so that a recursive type ends
+		schemas[name] = nil
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			jsonName, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || jsonName == "-" {
+				continue
+			}
+			if jsonName == "" {
+				jsonName = field.Name
+			}
+			attributes := map[string]any{}
+			if description := field.Tag.Get("description"); description != "" {
+				attributes["description"] = description
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				attributes["enum"] = strings.Split(enum, ",")
+			}
+			if format := field.Tag.Get("format"); format != "" {
+				attributes["format"] = format
+			}
+			properties[jsonName] = withAttributes(openAPISchema(field.Type, schemas), attributes)
+			if options != "omitempty" {
+				required = append(required, jsonName)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref
+	}
+	return map[string]any{}
+}
+
+// Function to add attributes to a schema, a reference is wrapped since nothing may sit beside it
+func withAttributes(schema, attributes map[string]any) map[string]any {
+	if len(attributes) == 0 {
+		return schema
+	}
+	if _, ok := schema["$ref"]; ok {
+		schema = map[string]any{"allOf": []any{schema}}
+	}
+	for key, value := range attributes {
+		// The values of an enum are those of the elements of an array
+		if items, ok := schema["items"].(map[string]any); ok && key == "enum" {
+			items[key] = value
+			continue
+		}
+		schema[key] = value
+	}
+	return schema
+}
+
+// Function to build the operation of a route
+func openAPIOperation(route apiRoute, schemas map[string]any) map[string]any {
+	parameters := []any{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, param := range route.Query {
+		parameters = append(parameters, map[string]any{
+			"name": param.Name, "in": "query", "description": param.Description, "schema": map[string]any{"type": param.Type},
+		})
+	}
+	success := map[string]any{"description": http.StatusText(route.Status)}
+	if route.Response != nil {
+		data := openAPISchema(reflect.TypeOf(route.Response), schemas)
+		envelope := map[string]any{"type": "object", "required": []string{"data"}, "properties": map[string]any{"data": data}}
+		if route.List {
+			data = map[string]any{"type": "array", "items": data}
+			envelope = map[string]any{
+				"type":       "object",
+				"required":   []string{"data", "page"},
+				"properties": map[string]any{"data": data, "page": openAPISchema(reflect.TypeOf(apiPage{}), schemas)},
+			}
+		}
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": envelope}}
+	}
+	operation := map[string]any{
+		"tags":       []string{route.Tag},
+		"summary":    route.Summary,
+		"parameters": parameters,
+		"responses":  map[string]any{strconv.Itoa(route.Status): success, "default": apiErrorResponse},
+	}
+	if route.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": openAPISchema(reflect.TypeOf(route.Request), schemas),
+			}},
+		}
+	}
+	if route.Auth {
+		operation["security"] = []any{map[string]any{"cookieAuth": []string{}}}
+	}
+	return operation
+}
+
+// Function to build the OpenAPI 3 document of the API from its routes
+func openAPIDocument() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+	for _, route := range apiRoutes() {
+		item, ok := paths[route.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = openAPIOperation(route, schemas)
+	}
+	errorSchema := openAPISchema(reflect.TypeOf(apiErrorEnvelope{}), schemas)
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Forum API",
+			"version":     strings.TrimPrefix(apiPrefix, "/api/"),
+			"description": "The answers are wrapped in a data envelope, the lists have a page too, and the errors are wrapped in an error envelope with a machine-readable code.",
+		},
+		"servers": []any{map[string]any{"url": publicURL() + apiPrefix}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error",
+					"content":     map[string]any{"application/json": map[string]any{"schema": errorSchema}},
+				},
+			},
+			"securitySchemes": map[string]any{
+				"cookieAuth": map[string]any{"type": "apiKey", "in": "cookie", "name": "session_token"},
+			},
+		},
+	}
+}
+
+// Function to serve the OpenAPI document of the API
+func GetOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(openAPIDocument())
+}
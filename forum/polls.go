@@ -0,0 +1,333 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the polls
+const (
+	minPollOptions     = 2
+	maxPollOptions     = 10
+	maxPollLabelLength = 200
+)
+
+// When the results of a poll are shown: always, to those who voted, or once it is closed
+var pollVisibilities = map[string]bool{
+	"always":      true,
+	"after_vote":  true,
+	"after_close": true,
+}
+
+// Option of a poll, the votes are left out while the results are hidden and the
+// voters are only named when the poll is not anonymous
+type PollOption struct {
+	ID     int64    `json:"id"`
+	Label  string   `json:"label"`
+	Votes  *int     `json:"votes,omitempty"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+// Poll of a post, as seen by the reader
+type Poll struct {
+	Question          string       `json:"question"`
+	Multiple          bool         `json:"multiple"`
+	Anonymous         bool         `json:"anonymous"`
+	ResultsVisibility string       `json:"results_visibility"`
+	ClosesAt          *time.Time   `json:"closes_at,omitempty"`
+	Closed            bool         `json:"closed"`
+	Voters            int          `json:"voters"`
+	Voted             bool         `json:"voted"`
+	MyChoices         []int64      `json:"my_choices,omitempty"`
+	ResultsVisible    bool         `json:"results_visible"`
+	Options           []PollOption `json:"options"`
+}
+
+// Poll given with a new post
+type pollDraft struct {
+	question          string
+	options           []string
+	multiple          bool
+	anonymous         bool
+	resultsVisibility string
+	closesAt          *time.Time
+}
+
+// Function to read the poll of the post form, there is none without a question.
+// The options come one per line or as repeated poll_options fields.
+func parsePoll(r *http.Request) (*pollDraft, error) {
+	question := strings.TrimSpace(r.FormValue("poll_question"))
+	if question == "" {
+		return nil, nil
+	}
+	draft := &pollDraft{
+		question:          question,
+		multiple:          r.FormValue("poll_multiple") == "true",
+		anonymous:         r.FormValue("poll_anonymous") == "true",
+		resultsVisibility: r.FormValue("poll_results"),
+	}
+	seen := map[string]bool{}
+	for _, value := range r.Form["poll_options"] {
+		for _, label := range strings.Split(value, "\n") {
+			label = strings.TrimSpace(label)
+			if label == "" {
+				continue
+			}
+			if len(label) > maxPollLabelLength {
+				return nil, fmt.Errorf("A poll option is too long")
+			}
+			if seen[strings.ToLower(label)] {
+				return nil, fmt.Errorf("The options of a poll must be different")
+			}
+			seen[strings.ToLower(label)] = true
+			draft.options = append(draft.options, label)
+		}
+	}
+	if len(draft.options) < minPollOptions || len(draft.options) > maxPollOptions {
+		return nil, fmt.Errorf("A poll needs between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	if draft.resultsVisibility == "" {
+		draft.resultsVisibility = "always"
+	}
+	if !pollVisibilities[draft.resultsVisibility] {
+		return nil, fmt.Errorf("Invalid results visibility")
+	}
+	// The closing date comes from a datetime-local input, or in RFC 3339
+	if value := r.FormValue("poll_closes_at"); value != "" {
+		closesAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			closesAt, err = time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid poll closing date")
+		}
+		if !closesAt.After(time.Now()) {
+			return nil, fmt.Errorf("The poll closing date must be in the future")
+		}
+		draft.closesAt = &closesAt
+	}
+	if draft.resultsVisibility == "after_close" && draft.closesAt == nil {
+		return nil, fmt.Errorf("A poll showing its results once closed needs a closing date")
+	}
+	return draft, nil
+}
+
+// Function to save the poll of a new post with its options
+func insertPoll(postID string, draft *pollDraft) error {
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO polls (post_id, question, multiple, anonymous, results_visibility, closes_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, postID, draft.question, draft.multiple, draft.anonymous, draft.resultsVisibility, draft.closesAt, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, label := range draft.options {
+		if _, err := tx.Exec("INSERT INTO poll_options (post_id, label, position) VALUES (?, ?, ?)", postID, label, i); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Function to load the polls of several posts as seen by the user, who may be logged out
+func loadPolls(postIDs []string, userID string) map[string]*Poll {
+	result := map[string]*Poll{}
+	if len(postIDs) == 0 {
+		return result
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		args[i] = id
+	}
+	rows, err := auth.DB.Query(`SELECT post_id, question, multiple, anonymous, results_visibility, closes_at,
+		(SELECT COUNT(*) FROM poll_ballots b WHERE b.post_id = polls.post_id)
+		FROM polls WHERE post_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return result
+	}
+	for rows.Next() {
+		var postID string
+		var closesAt sql.NullTime
+		poll := &Poll{Options: []PollOption{}}
+		if rows.Scan(&postID, &poll.Question, &poll.Multiple, &poll.Anonymous, &poll.ResultsVisibility, &closesAt, &poll.Voters) != nil {
+			continue
+		}
+		if closesAt.Valid {
+			poll.ClosesAt = &closesAt.Time
+			poll.Closed = !time.Now().Before(closesAt.Time)
+		}
+		result[postID] = poll
+	}
+	rows.Close()
+	if len(result) == 0 {
+		return result
+	}
+	// The choices of the reader
+	if userID != "" {
+		rows, err = auth.DB.Query("SELECT post_id, option_id FROM poll_votes WHERE user_id = ? AND post_id IN ("+placeholders+")",
+			append([]any{userID}, args...)...)
+		if err == nil {
+			for rows.Next() {
+				var postID string
+				var optionID int64
+				if rows.Scan(&postID, &optionID) == nil && result[postID] != nil {
+					result[postID].Voted = true
+					result[postID].MyChoices = append(result[postID].MyChoices, optionID)
+				}
+			}
+			rows.Close()
+		}
+	}
+	for _, poll := range result {
+		poll.ResultsVisible = poll.ResultsVisibility == "always" || poll.Closed ||
+			(poll.ResultsVisibility == "after_vote" && poll.Voted)
+	}
+	// The voters of the options, named only in the polls that are not anonymous
+	voters := map[int64][]string{}
+	rows, err = auth.DB.Query(`SELECT v.option_id, COALESCE(u.username, 'deleted user') FROM poll_votes v
+		JOIN polls p ON p.post_id = v.post_id AND NOT p.anonymous
+		LEFT JOIN users u ON u.id = v.user_id
+		WHERE v.post_id IN (`+placeholders+`) ORDER BY u.username`, args...)
+	if err == nil {
+		for rows.Next() {
+			var optionID int64
+			var username string
+			if rows.Scan(&optionID, &username) == nil {
+				voters[optionID] = append(voters[optionID], username)
+			}
+		}
+		rows.Close()
+	}
+	rows, err = auth.DB.Query(`SELECT o.post_id, o.id, o.label, COUNT(v.user_id) FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.post_id IN (`+placeholders+`) GROUP BY o.id ORDER BY o.position`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID string
+		var option PollOption
+		var votes int
+		if rows.Scan(&postID, &option.ID, &option.Label, &votes) != nil || result[postID] == nil {
+			continue
+		}
+		if poll := result[postID]; poll.ResultsVisible {
+			option.Votes = &votes
+			option.Voters = voters[option.ID]
+		}
+		result[postID].Options = append(result[postID].Options, option)
+	}
+	return result
+}
+
+// Function to vote in the poll of a post, once, with one option or several when the
+// poll allows it
+func VotePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	postID := r.FormValue("post_id")
+	// The polls of the posts in the trash or archived do not take votes anymore
+	status, err := postStatus(postID)
+	if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	if status == "deleted" {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if status == "archived" {
+		http.Error(w, "This post is archived", http.StatusForbidden)
+		return
+	}
+	var multiple bool
+	var closesAt sql.NullTime
+	err = auth.DB.QueryRow("SELECT multiple, closes_at FROM polls WHERE post_id = ?", postID).Scan(&multiple, &closesAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving poll", http.StatusInternalServerError)
+		return
+	}
+	if closesAt.Valid && !time.Now().Before(closesAt.Time) {
+		http.Error(w, "This poll is closed", http.StatusForbidden)
+		return
+	}
+	// The chosen options, each one once
+	var optionIDs []int64
+	chosen := map[int64]bool{}
+	for _, value := range r.Form["option_id"] {
+		optionID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid option", http.StatusBadRequest)
+			return
+		}
+		if !chosen[optionID] {
+			chosen[optionID] = true
+			optionIDs = append(optionIDs, optionID)
+		}
+	}
+	if len(optionIDs) == 0 {
+		http.Error(w, "Choose at least one option", http.StatusBadRequest)
+		return
+	}
+	if !multiple && len(optionIDs) > 1 {
+		http.Error(w, "This poll accepts a single choice", http.StatusBadRequest)
+		return
+	}
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		http.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	// The ballot is unique per user and poll, a second vote is refused by the database
+	result, err := tx.Exec(`INSERT INTO poll_ballots (post_id, user_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(post_id, user_id) DO NOTHING`, postID, userID, time.Now())
+	if err != nil {
+		http.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		http.Error(w, "You already voted in this poll", http.StatusConflict)
+		return
+	}
+	for _, optionID := range optionIDs {
+		result, err := tx.Exec(`INSERT INTO poll_votes (post_id, user_id, option_id)
+			SELECT post_id, ?, id FROM poll_options WHERE id = ? AND post_id = ?`, userID, optionID, postID)
+		if err != nil {
+			http.Error(w, "Error recording vote", http.StatusInternalServerError)
+			return
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			http.Error(w, "Invalid option", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+	// The poll is returned with the results the user may now see
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loadPolls([]string{postID}, userID)[postID])
+}
@@ -4,11 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -16,61 +13,24 @@ import (
 	"Forum/auth"
 )
 
-// Allowed file extensions for images
-var allowedExtensions = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".gif":  true,
+// Post written by a user, read from the form of the site or from the body of the API
+type postInput struct {
+	Title      string
+	Content    string
+	Categories []string
+	Tags       string
+	Poll       *pollDraft
+	PublishAt  *time.Time
+	Files      []*multipart.FileHeader
+	DraftID    string
 }
 
-// Maximum allowed size (20mb)
-const maxImageSize = 20 * 1024 * 1024 
-
-// Function to save an uploaded image
-func saveImage(fileHeader *multipart.FileHeader) (string, error) {
-    // Open the uploaded file
-	file, err := fileHeader.Open()
-    if err != nil {
-        return "", err
-    }
-    defer file.Close()
-
-	// Check the file extension
-    ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-    if !allowedExtensions[ext] {
-        return "", fmt.Errorf("unsupported file type")
-    }
-	// Check if the file size exceeds the maximum allowed size
-    if fileHeader.Size > maxImageSize {
-        return "", fmt.Errorf("file too large")
-    }
-	// Check if the upload directory exists
-    uploadDir := "uploads"
-    if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-        err = os.Mkdir(uploadDir, os.ModePerm)
-        if err != nil {
-            return "", fmt.Errorf("failed to create upload directory: %v", err)
-        }
-    }
-	// Generate a unique ID for the image
-    imageID := uuid.New().String()
-    filePath := filepath.Join(uploadDir, imageID+ext)
-
-	// Create the file
-    outFile, err := os.Create(filePath)
-    if err != nil {
-        return "", err
-    }
-    defer outFile.Close()
-
-	// Copy the uploaded file in directory
-    _, err = io.Copy(outFile, file)
-    if err != nil {
-        return "", err
-    }
-    return filePath, nil
-}
+// What became of a new post
+const (
+	postPublished = "published"
+	postScheduled = "scheduled"
+	postHeld      = "held"
+)
 
 // Function to create a new post
 func CreatePost(w http.ResponseWriter, r *http.Request) {
@@ -79,61 +39,152 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Get the user ID
-	userID, err := auth.GetUserFromSession(r)
+	userID, role, err := auth.GetUserFromSessionRole(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	// Get the form data
-	title := r.FormValue("title")
-	content := r.FormValue("content")
-	categories := r.FormValue("categories") 
+	// Reject oversized uploads before reading the form
+	if !parseUploadForm(w, r) {
+		return
+	}
+	// A poll is optional
+	poll, err := parsePoll(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The post is published now, or later by the scheduler
+	publishAt, err := parsePublishAt(r.FormValue("publish_at"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Get the form data, the legacy "image" field comes first among the files
+	var categories []string
+	if value := r.FormValue("categories"); value != "" {
+		categories = strings.Split(value, ",")
+	}
+	_, outcome, status, err := createPost(userID, role, postInput{
+		Title:      r.FormValue("title"),
+		Content:    r.FormValue("content"),
+		Categories: categories,
+		Tags:       r.FormValue("tags"),
+		Poll:       poll,
+		PublishAt:  publishAt,
+		Files:      uploadedFiles(r),
+		DraftID:    r.FormValue("draft_id"),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	switch outcome {
+	case postHeld:
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "Post submitted for review!")
+	case postScheduled:
+		fmt.Fprintf(w, "Post scheduled successfully!")
+	default:
+		fmt.Fprintf(w, "Post created successfully!")
+	}
+}
 
+// Function to create a post, it returns the ID of the post, what became of it and the
+// HTTP status of the refusal
+func createPost(userID, role string, input postInput) (string, string, int, error) {
 	// Check if required fields are provided
-	if title == "" || content == "" || categories == "" {
-		http.Error(w, "Title, content, and at least one category are required", http.StatusBadRequest)
-		return
+	if input.Title == "" || input.Content == "" || len(input.Categories) == 0 {
+		return "", "", http.StatusBadRequest, fmt.Errorf("Title, content, and at least one category are required")
+	}
+	// Links need a trust level, the new users are a target of spammers
+	if containsLink(input.Title+" "+input.Content) && !auth.HasTrust(userID, role, "post_links") {
+		return "", "", http.StatusForbidden, fmt.Errorf("Your trust level does not allow links yet")
+	}
+	// The categories must exist and be open to the role of the user
+	categoryIDs, status, err := checkPostCategories(role, input.Categories)
+	if err != nil {
+		return "", "", status, err
+	}
+	// Tags are optional and normalized
+	tagNames, err := parseTags(input.Tags)
+	if err != nil {
+		return "", "", http.StatusBadRequest, err
 	}
 	// Create a ID for the post
 	postID := uuid.New().String()
-	var imagePath string
-
-	// Check if an image file is provided
-	file, fileHeader, err := r.FormFile("image")
-	if err == nil {
-		defer file.Close()
 
-		// Save the image and get its path
-		imagePath, err = saveImage(fileHeader)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+	// Save the attached files
+	attachments, err := saveAttachments(userID, "post_id", input.Files)
+	if err != nil {
+		return "", "", http.StatusBadRequest, err
+	}
+	// The stored files of a refused image are removed by the blob garbage collector
+	if _, ok := firstImage(attachments); ok && !auth.HasTrust(userID, role, "post_images") {
+		return "", "", http.StatusForbidden, fmt.Errorf("Your trust level does not allow images yet")
+	}
+	// The posts of the users below the trust level are held for the review of a moderator,
+	// they stay unpublished until it is approved
+	var heldAt, scheduledAt *time.Time
+	scheduledAt = input.PublishAt
+	if !auth.HasTrust(userID, role, "skip_premoderation") {
+		now := time.Now()
+		heldAt = &now
+		if scheduledAt == nil {
+			// Like the scheduled dates, in UTC
+			publishNow := now.UTC()
+			scheduledAt = &publishNow
 		}
 	}
 	// Insert the post into the database
-	_, err = auth.DB.Exec("INSERT INTO posts (id, user_id, title, content, created_at) VALUES (?, ?, ?, ?, ?)", postID, userID, title, content, time.Now())
+	// A scheduled post is dated from its publication, in local time like the other dates
+	createdAt := time.Now()
+	if input.PublishAt != nil {
+		createdAt = input.PublishAt.Local()
+	}
+	_, err = auth.DB.Exec("INSERT INTO posts (id, user_id, title, content, created_at, scheduled_at, held_at) VALUES (?, ?, ?, ?, ?, ?, ?)", postID, userID, input.Title, input.Content, createdAt, scheduledAt, heldAt)
 	if err != nil {
-		http.Error(w, "Error creating post", http.StatusInternalServerError)
-		return
+		return "", "", http.StatusInternalServerError, fmt.Errorf("Error creating post")
 	}
-	// If an image is provided, insert it into the post_images table
-	if imagePath != "" {
-		_, err = auth.DB.Exec("INSERT INTO post_images (post_id, image_path) VALUES (?, ?)", postID, imagePath)
-		if err != nil {
-			http.Error(w, "Error saving image", http.StatusInternalServerError)
-			return
-		}
+	// Link the attachments to the post
+	if err := insertAttachments(userID, "post_id", postID, attachments); err != nil {
+		return "", "", http.StatusInternalServerError, fmt.Errorf("Error saving attachments")
 	}
 	// Add the categories associated with the post
-	categoryIDs := strings.Split(categories, ",")
 	for _, categoryID := range categoryIDs {
-		_, err = auth.DB.Exec("INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)", postID, strings.TrimSpace(categoryID))
+		_, err = auth.DB.Exec("INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)", postID, categoryID)
 		if err != nil {
-			http.Error(w, "Error linking post to categories", http.StatusInternalServerError)
-			return
+			return "", "", http.StatusInternalServerError, fmt.Errorf("Error linking post to categories")
+		}
+	}
+	if input.Poll != nil {
+		if err := insertPoll(postID, input.Poll); err != nil {
+			return "", "", http.StatusInternalServerError, fmt.Errorf("Error saving poll")
 		}
 	}
-	fmt.Fprintf(w, "Post created successfully!")
+	tags, err := setPostTags(postID, tagNames)
+	if err != nil {
+		return "", "", http.StatusInternalServerError, fmt.Errorf("Error saving tags")
+	}
+	tagIDs := make([]string, len(tags))
+	for i, tag := range tags {
+		tagIDs[i] = tag.ID
+	}
+	// The draft the post was written from is not needed anymore
+	if input.DraftID != "" {
+		auth.DB.Exec("DELETE FROM drafts WHERE id = ? AND user_id = ?", input.DraftID, userID)
+	}
+	if heldAt != nil {
+		// The readers are told by the scheduler once a moderator approved the post
+		return postID, postHeld, http.StatusAccepted, nil
+	}
+	if input.PublishAt != nil {
+		// The readers are told by the scheduler once the post goes live
+		return postID, postScheduled, http.StatusOK, nil
+	}
+	// Notify the users mentioned with @username, then the followers of the author, categories and tags
+	announcePost(userID, postID, input.Content, categoryIDs, tagIDs)
+	return postID, postPublished, http.StatusOK, nil
 }
 
 // Function to get a post
@@ -151,9 +202,14 @@ func GetPost(w http.ResponseWriter, r *http.Request) {
 		Content   string
 		CreatedAt time.Time
 		ImagePath string
+		Attachments []Attachment
+		Tags      []Tag
+		Locked    bool
+		Archived  bool
+		Poll      *Poll
 	}
-	// Get the post data
-	err := auth.DB.QueryRow("SELECT p.id, p.user_id, p.title, p.content, p.created_at, pi.image_path FROM posts p LEFT JOIN post_images pi ON p.id = pi.post_id WHERE p.id = ?", postID).Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.ImagePath)
+	// Get the post data, the posts in the trash are not found
+	err := auth.DB.QueryRow("SELECT p.id, p.user_id, p.title, p.content, p.created_at, "+postFlags+" FROM posts p WHERE p.id = ? AND p.deleted_at IS NULL AND p.scheduled_at IS NULL", postID).Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.Locked, &post.Archived)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
@@ -161,6 +217,14 @@ func GetPost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
 		return
 	}
+	// Get the attached files
+	post.Attachments = loadAttachments("post_id", []string{post.ID})[post.ID]
+	post.Tags = loadPostTags([]string{post.ID})[post.ID]
+	readerID, _ := auth.GetUserFromSession(r)
+	post.Poll = loadPolls([]string{post.ID}, readerID)[post.ID]
+	if image, ok := firstImage(post.Attachments); ok {
+		post.ImagePath = image.Path
+	}
 	// Return the post data in JSON format
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(post)
@@ -172,30 +236,78 @@ func GetAllPosts(w http.ResponseWriter, r *http.Request) {
     // Get the filter parameters
 	filter := r.URL.Query().Get("filter")
     categoryID := r.URL.Query().Get("category_id")
-    userID, _ := auth.GetUserFromSession(r)
+    userID, role, _ := auth.GetUserFromSessionRole(r)
 
 	// Create the SQL query to retrieve posts
     var rows *sql.Rows
     var err error
+    // The posts pinned to the whole forum come first, with those pinned to the category listed
+    pinScope := ""
+    if filter == "category" {
+        pinScope = categoryID
+    }
     query := `
-        SELECT DISTINCT p.id, p.user_id, p.title, p.content, p.created_at, COALESCE(pi.image_path, '')
+        SELECT DISTINCT p.id, p.user_id, COALESCE(u.username, 'deleted user'), COALESCE(u.avatar_path, ''), p.title, p.content, p.created_at,
+        EXISTS (SELECT 1 FROM post_pins pp WHERE pp.post_id = p.id AND (pp.scope = '' OR pp.scope = ?)) AS pinned, ` + postFlags + `,
+        ` + qaPost + `, p.accepted_comment_id IS NOT NULL,
+        EXISTS (SELECT 1 FROM bookmarks bm WHERE bm.user_id = ? AND bm.target_type = 'post' AND bm.target_id = p.id),
+        ` + unreadPost + `, ` + unreadComments + `
         FROM posts p
-        LEFT JOIN post_images pi ON p.id = pi.post_id
+        LEFT JOIN users u ON p.user_id = u.id
         LEFT JOIN post_categories pc ON p.id = pc.post_id
     `
-    if filter == "category" && categoryID != "" {
-        query += " WHERE pc.category_id = ? ORDER BY p.created_at DESC"
-        rows, err = auth.DB.Query(query, categoryID)
-    } else if filter == "my_posts" && userID != "" {
-        query += " WHERE p.user_id = ? ORDER BY p.created_at DESC"
-        rows, err = auth.DB.Query(query, userID)
-    } else if filter == "liked" && userID != "" {
-        query += " WHERE p.id IN (SELECT post_id FROM likes WHERE user_id = ? AND type = 'like') ORDER BY p.created_at DESC"
-        rows, err = auth.DB.Query(query, userID)
-    } else {
-        query += " ORDER BY p.created_at DESC"
-        rows, err = auth.DB.Query(query)
+    // Conditions of the filter, joined with AND, the posts in the trash or not published yet are never listed
+    conditions := []string{"p.deleted_at IS NULL", "p.scheduled_at IS NULL"}
+    args := append([]interface{}{pinScope, userID}, readArgs(userID, 7)...)
+    switch {
+    case filter == "category" && categoryID != "":
+        // The posts of the subcategories are listed with those of the category
+        conditions = append(conditions, "pc.category_id IN "+categoryTree)
+        args = append(args, categoryID)
+    case filter == "my_posts" && userID != "":
+        conditions = append(conditions, "p.user_id = ?")
+        args = append(args, userID)
+    case filter == "liked" && userID != "":
+        conditions = append(conditions, "p.id IN (SELECT post_id FROM likes WHERE user_id = ? AND type = 'like')")
+        args = append(args, userID)
+    case filter == "unanswered":
+        // The questions without accepted answer, of a category when one is given
+        conditions = append(conditions, qaPost, "p.accepted_comment_id IS NULL")
+        if categoryID != "" {
+            conditions = append(conditions, "pc.category_id IN "+categoryTree)
+            args = append(args, categoryID)
+        }
+    case filter == "following" && userID != "":
+        conditions = append(conditions, followingCondition)
+        args = append(args, userID, userID, userID, userID)
+    case filter == "bookmarked" && userID != "":
+        // The bookmarked posts, of one collection when one is given
+        if collectionID := r.URL.Query().Get("collection_id"); collectionID != "" {
+            conditions = append(conditions, collectionCondition)
+            args = append(args, userID, collectionID)
+        } else {
+            conditions = append(conditions, bookmarkedCondition)
+            args = append(args, userID)
+        }
+    case filter == "unread" && userID != "":
+        // The posts new to the user or with comments they have not read
+        conditions = append(conditions, unreadCondition)
+        args = append(args, readArgs(userID, 7)...)
+    }
+    // Posts with any or all of the tags, given by name or synonym
+    if tags := r.URL.Query().Get("tags"); tags != "" {
+        condition, tagArgs := tagCondition(tags, r.URL.Query().Get("tag_mode"))
+        conditions = append(conditions, condition)
+        args = append(args, tagArgs...)
+    }
+    // The posts of the users blocked or muted by the reader are hidden
+    if userID != "" {
+        conditions = append(conditions, "p.user_id NOT IN "+hiddenUsers)
+        args = append(args, userID)
     }
+    query += " WHERE " + strings.Join(conditions, " AND ")
+    query += " ORDER BY pinned DESC, p.created_at DESC"
+    rows, err = auth.DB.Query(query, args...)
     if err != nil {
         http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
         return
@@ -206,20 +318,62 @@ func GetAllPosts(w http.ResponseWriter, r *http.Request) {
     type Post struct {
         ID        string    `json:"ID"`
         UserID    string    `json:"UserID"`
+        Username  string    `json:"Username"`
+        AvatarPath string   `json:"AvatarPath"`
         Title     string    `json:"Title"`
         Content   string    `json:"Content"`
         CreatedAt time.Time `json:"CreatedAt"`
         ImagePath string    `json:"ImagePath"`
+        ImageWidth  int     `json:"ImageWidth"`
+        ImageHeight int     `json:"ImageHeight"`
+        Thumbnails map[string]string `json:"Thumbnails"`
+        Attachments []Attachment `json:"Attachments"`
+        Tags []Tag `json:"Tags"`
+        Pinned bool `json:"Pinned"`
+        Locked bool `json:"Locked"`
+        Archived bool `json:"Archived"`
+        Poll *Poll `json:"Poll"`
+        // Questions of the Q&A categories are solved once an answer is accepted
+        QA bool `json:"QA"`
+        Solved bool `json:"Solved"`
+        CanAccept bool `json:"CanAccept"`
+        Bookmarked bool `json:"Bookmarked"`
+        // What the user has not read yet, the post itself or its last comments
+        Unread bool `json:"Unread"`
+        UnreadComments int `json:"UnreadComments"`
     }
 	// Retrieve the posts
     var posts []Post
     for rows.Next() {
         var post Post
-        if err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.ImagePath); err != nil {
+        if err := rows.Scan(&post.ID, &post.UserID, &post.Username, &post.AvatarPath, &post.Title, &post.Content, &post.CreatedAt, &post.Pinned, &post.Locked, &post.Archived, &post.QA, &post.Solved, &post.Bookmarked, &post.Unread, &post.UnreadComments); err != nil {
             http.Error(w, "Error reading post", http.StatusInternalServerError)
             return
         }
         posts = append(posts, post)
+    }
+    rows.Close()
+    // Load the attachments of every post at once, the first image stays the preview
+    postIDs := make([]string, len(posts))
+    for i := range posts {
+        postIDs[i] = posts[i].ID
+    }
+    attachments := loadAttachments("post_id", postIDs)
+    tags := loadPostTags(postIDs)
+    polls := loadPolls(postIDs, userID)
+    for i := range posts {
+        posts[i].Attachments = attachments[posts[i].ID]
+        posts[i].Tags = tags[posts[i].ID]
+        posts[i].Poll = polls[posts[i].ID]
+        posts[i].CanAccept = posts[i].QA && userID != "" && (posts[i].UserID == userID || auth.HasRole(role, "moderator"))
+        // The visitors have no read markers
+        if userID == "" {
+            posts[i].Unread, posts[i].UnreadComments = false, 0
+        }
+        if image, ok := firstImage(posts[i].Attachments); ok {
+            posts[i].ImagePath, posts[i].ImageWidth, posts[i].ImageHeight = image.Path, image.Width, image.Height
+            posts[i].Thumbnails = image.Thumbnails
+        }
     }
 	// Return the list of posts in JSON format
     w.Header().Set("Content-Type", "application/json")
@@ -243,29 +397,33 @@ func DeletePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Post ID is required", http.StatusBadRequest)
 		return
 	}
+	if status, err := deleteOwnPost(userID, postID); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted successfully"})
+}
+
+// Function to move a post of the user to the trash, the moderators can restore it during
+// the restore window. It returns the HTTP status of the refusal.
+func deleteOwnPost(userID, postID string) (int, error) {
 	// Get the post owner from the database
 	var postOwner string
-	err = auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", postID).Scan(&postOwner)
+	err := auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ? AND deleted_at IS NULL", postID).Scan(&postOwner)
 	if err == sql.ErrNoRows {
-		http.Error(w, "Post not found", http.StatusNotFound)
-		return
+		return http.StatusNotFound, fmt.Errorf("Post not found")
 	} else if err != nil {
-		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError, fmt.Errorf("Error retrieving post")
 	}
 	// Ensure the user is the owner of the post
 	if postOwner != userID {
-		http.Error(w, "You can only delete your own posts", http.StatusForbidden)
-		return
+		return http.StatusForbidden, fmt.Errorf("You can only delete your own posts")
 	}
-	// Delete the post from the database
-	_, err = auth.DB.Exec("DELETE FROM posts WHERE id = ?", postID)
-	if err != nil {
-		http.Error(w, "Error deleting post", http.StatusInternalServerError)
-		return
+	if _, err := trashPost(postID, userID); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Error deleting post")
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted successfully"})
+	return http.StatusOK, nil
 }
 
 // Function to like or dislike a post
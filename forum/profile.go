@@ -0,0 +1,125 @@
+package forum
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"Forum/auth"
+)
+
+// Maximum length of a user bio
+const maxBioLength = 500
+
+// Number of posts and comments shown in the recent activity of a profile
+const profileActivityLimit = 10
+
+// Public information about a user
+type Profile struct {
+	Username       string             `json:"username"`
+	AvatarPath     string             `json:"avatar_path"`
+	Bio            string             `json:"bio"`
+	Role           string             `json:"role"`
+	JoinedAt       time.Time          `json:"joined_at"`
+	PostCount      int                `json:"post_count"`
+	CommentCount   int                `json:"comment_count"`
+	Reputation     int                `json:"reputation"`
+	TrustLevel     int                `json:"trust_level"`
+	TrustLevelName string             `json:"trust_level_name"`
+	Badges         []UserBadge        `json:"badges"`
+	RecentPosts    []auth.Post        `json:"recent_posts"`
+	RecentComments []auth.CommentInfo `json:"recent_comments"`
+	IsSelf         bool               `json:"is_self"`
+}
+
+// Function to display the profile page of a user
+func ServeProfile(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "web/html/profile.html")
+}
+
+// Function to retrieves the public profile of a user
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	username := r.PathValue("username")
+
+	// Get the user data
+	var userID string
+	var profile Profile
+	err := auth.DB.QueryRow("SELECT id, username, COALESCE(avatar_path, ''), COALESCE(bio, ''), COALESCE(role, 'user'), created_at FROM users WHERE username = ?", username).Scan(&userID, &profile.Username, &profile.AvatarPath, &profile.Bio, &profile.Role, &profile.JoinedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+	// Count the posts and comments of the user
+	auth.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id = ? AND deleted_at IS NULL AND scheduled_at IS NULL", userID).Scan(&profile.PostCount)
+	auth.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE user_id = ?", userID).Scan(&profile.CommentCount)
+	profile.Reputation = userReputation(userID)
+	profile.TrustLevel = auth.TrustLevelOf(userID, profile.Role)
+	profile.TrustLevelName = auth.TrustLevels[profile.TrustLevel]
+	profile.Badges = userBadges(userID)
+	sessionUserID, _ := auth.GetUserFromSession(r)
+	profile.IsSelf = sessionUserID == userID
+
+	// Keep only the public part of the activity
+	activity, err := auth.LoadUserActivity(userID, profileActivityLimit)
+	if err != nil {
+		http.Error(w, "Error retrieving activity", http.StatusInternalServerError)
+		return
+	}
+	profile.RecentPosts = activity.Posts
+	profile.RecentComments = activity.Comments
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// Function to update the bio and avatar of the connected user
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !parseUploadForm(w, r) {
+		return
+	}
+	bio := r.FormValue("bio")
+	if len([]rune(bio)) > maxBioLength {
+		http.Error(w, "Bio is too long", http.StatusBadRequest)
+		return
+	}
+	// Save the new avatar with the same pipeline as post images
+	var avatarPath string
+	file, fileHeader, err := r.FormFile("avatar")
+	if err == nil {
+		defer file.Close()
+		avatar, err := saveImage(fileHeader, false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		avatarPath = avatar.Path
+	}
+	if avatarPath == "" {
+		_, err = auth.DB.Exec("UPDATE users SET bio = ? WHERE id = ?", bio, userID)
+	} else {
+		// The previous avatar is removed by the blob garbage collector
+		_, err = auth.DB.Exec("UPDATE users SET bio = ?, avatar_path = ? WHERE id = ?", bio, avatarPath, userID)
+	}
+	if err != nil {
+		http.Error(w, "Error updating profile", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated successfully", "avatar_path": avatarPath})
+}
@@ -0,0 +1,119 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Date up to which the user has read the post p: their marker of the post, or the date they
+// marked everything read when it is later. The user ID is given twice.
+const readMarker = `MAX(COALESCE((SELECT rp.last_read_at FROM post_reads rp WHERE rp.user_id = ? AND rp.post_id = p.id), ''),
+	COALESCE((SELECT ru.read_all_at FROM users ru WHERE ru.id = ?), ''))`
+
+// Post p published by another user since the user read it, the user ID is given three times
+const unreadPost = "(p.user_id != ? AND p.created_at > " + readMarker + ")"
+
+// Number of comments of the post p written by others since the user read it, without those
+// of the users they blocked or muted. The user ID is given four times.
+const unreadComments = `(SELECT COUNT(*) FROM comments uc WHERE uc.post_id = p.id AND uc.user_id != ?
+	AND uc.user_id NOT IN ` + hiddenUsers + ` AND uc.created_at > ` + readMarker + `)`
+
+// Posts with something the user has not read, the user ID is given seven times
+const unreadCondition = "(" + unreadPost + " OR " + unreadComments + " > 0)"
+
+// Function to repeat the user ID for each placeholder of a read tracking query
+func readArgs(userID string, count int) []interface{} {
+	args := make([]interface{}, count)
+	for i := range args {
+		args[i] = userID
+	}
+	return args
+}
+
+// Function to build the condition of the comments c of the post p newer than the cursor
+// given by since: last_read for those the user has not read, or a date
+func commentCursor(userID, since string) (string, []interface{}, bool) {
+	switch since {
+	case "":
+		return "", nil, true
+	case "last_read":
+		return "c.created_at > " + readMarker, readArgs(userID, 2), true
+	}
+	date, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return "", nil, false
+	}
+	return "c.created_at > ?", []interface{}{date.Local()}, true
+}
+
+// Function to mark a post read by the user up to now
+func markPostRead(userID, postID string) error {
+	_, err := auth.DB.Exec(`INSERT INTO post_reads (user_id, post_id, last_read_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, post_id) DO UPDATE SET last_read_at = excluded.last_read_at`, userID, postID, time.Now())
+	return err
+}
+
+// Function to mark a post and its comments read
+func MarkPostRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	postID := r.FormValue("post_id")
+	var exists int
+	err = auth.DB.QueryRow(subscriptionTargets["post"], postID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	if err := markPostRead(userID, postID); err != nil {
+		http.Error(w, "Error marking post as read", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post marked as read"})
+}
+
+// Function to mark every post read, the markers of the posts are replaced by the date of the user
+func MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		http.Error(w, "Error marking posts as read", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE users SET read_all_at = ? WHERE id = ?", time.Now(), userID); err != nil {
+		http.Error(w, "Error marking posts as read", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM post_reads WHERE user_id = ?", userID); err != nil {
+		http.Error(w, "Error marking posts as read", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error marking posts as read", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "All posts marked as read"})
+}
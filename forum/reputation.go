@@ -0,0 +1,210 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Reputation of a like or dislike received, and of the moderation outcomes
+const (
+	likeReputation         = 1
+	dislikeReputation      = -1
+	reportUpheldReputation = -5
+	postRemovedReputation  = -10
+	postRejectedReputation = -2
+)
+
+// Size of the leaderboard
+const (
+	defaultLeaderboardSize = 20
+	maxLeaderboardSize     = 100
+)
+
+// What a user needs to reach a trust level: an account old enough, published posts and
+// comments, reputation and, for the highest levels, no moderation penalty for a while
+type trustRequirement struct {
+	days          int
+	contributions int
+	reputation    int
+	cleanDays     int
+}
+
+// Requirements of the trust levels, in the order of auth.TrustLevels
+var trustRequirements = []trustRequirement{
+	{},
+	{days: 1, contributions: 1},
+	{days: 14, contributions: 10, reputation: 10},
+	{days: 60, contributions: 50, reputation: 50, cleanDays: 60},
+}
+
+// Entry of the leaderboard
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	Username   string `json:"username"`
+	AvatarPath string `json:"avatar_path"`
+	Reputation int    `json:"reputation"`
+	TrustLevel int    `json:"trust_level"`
+}
+
+// Function to build the SQL expression of the reputation of the user whose ID is in
+// column: likes and dislikes received from others, accepted answers and moderation outcomes
+func reputationOf(column string) string {
+	return `(
+		(SELECT COALESCE(SUM(CASE l.type WHEN 'like' THEN ` + strconv.Itoa(likeReputation) + ` WHEN 'dislike' THEN ` + strconv.Itoa(dislikeReputation) + ` ELSE 0 END), 0)
+			FROM likes l
+			LEFT JOIN posts lp ON l.post_id = lp.id
+			LEFT JOIN comments lc ON l.comment_id = lc.id
+			WHERE (lp.user_id = ` + column + ` OR lc.user_id = ` + column + `) AND l.user_id != ` + column + `)
+		+ ` + strconv.Itoa(acceptedAnswerReputation) + ` * (SELECT COUNT(*) FROM posts ap JOIN comments ac ON ac.id = ap.accepted_comment_id
+			WHERE ac.user_id = ` + column + ` AND ap.user_id != ac.user_id AND ap.deleted_at IS NULL AND ap.scheduled_at IS NULL)
+		+ (SELECT COALESCE(SUM(re.points), 0) FROM reputation_events re WHERE re.user_id = ` + column + `)
+	)`
+}
+
+// Function to compute the reputation of a user
+func userReputation(userID string) int {
+	var reputation int
+	auth.DB.QueryRow("SELECT "+reputationOf("u.id")+" FROM users u WHERE u.id = ?", userID).Scan(&reputation)
+	return reputation
+}
+
+// Function to record a moderation outcome changing the reputation of the author of a post
+func recordReputationEvent(userID, postID, kind string, points int) {
+	if userID == "" {
+		return
+	}
+	_, err := auth.DB.Exec("INSERT INTO reputation_events (user_id, post_id, kind, points, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, postID, kind, points, time.Now())
+	if err != nil {
+		log.Println("Error recording reputation event:", err)
+	}
+}
+
+// Function to compute the trust level of a user from their account, activity and
+// reputation, the moderators and admins have the highest one. The checks read the level
+// stored by updateTrustLevels instead, this query is too heavy for every request.
+func computeTrustLevel(userID, role string) int {
+	if auth.HasRole(role, "moderator") {
+		return auth.MaxTrustLevel
+	}
+	var joinedAt time.Time
+	var contributions, reputation int
+	err := auth.DB.QueryRow(`SELECT u.created_at,
+		(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL)
+		+ (SELECT COUNT(*) FROM comments c WHERE c.user_id = u.id), `+reputationOf("u.id")+`
+		FROM users u WHERE u.id = ?`, userID).Scan(&joinedAt, &contributions, &reputation)
+	if err != nil {
+		return 0
+	}
+	level := 0
+	for next := 1; next < len(trustRequirements); next++ {
+		requirement := trustRequirements[next]
+		if time.Since(joinedAt) < time.Duration(requirement.days)*24*time.Hour ||
+			contributions < requirement.contributions || reputation < requirement.reputation {
+			break
+		}
+		if requirement.cleanDays > 0 {
+			var penalties int
+			auth.DB.QueryRow("SELECT COUNT(*) FROM reputation_events WHERE user_id = ? AND points < 0 AND created_at >= ?",
+				userID, time.Now().AddDate(0, 0, -requirement.cleanDays)).Scan(&penalties)
+			if penalties > 0 {
+				break
+			}
+		}
+		level = next
+	}
+	return level
+}
+
+// Function to return the reputation ranking of the users
+func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLeaderboardSize
+	}
+	if limit > maxLeaderboardSize {
+		limit = maxLeaderboardSize
+	}
+	rows, err := auth.DB.Query(`SELECT u.username, COALESCE(u.avatar_path, ''), COALESCE(u.role, 'user'), COALESCE(u.trust_level, 0), `+reputationOf("u.id")+` AS reputation
+		FROM users u ORDER BY reputation DESC, u.username LIMIT ?`, limit)
+	if err != nil {
+		http.Error(w, "Error retrieving leaderboard", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	leaderboard := []LeaderboardEntry{}
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var role string
+		if err := rows.Scan(&entry.Username, &entry.AvatarPath, &role, &entry.TrustLevel, &entry.Reputation); err != nil {
+			http.Error(w, "Error reading leaderboard", http.StatusInternalServerError)
+			return
+		}
+		// The stored level, the moderators and admins have the highest like in auth.TrustLevelOf
+		if auth.HasRole(role, "moderator") {
+			entry.TrustLevel = auth.MaxTrustLevel
+		}
+		entry.Rank = len(leaderboard) + 1
+		leaderboard = append(leaderboard, entry)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+// Function to update the stored trust level of the users, telling those who reached a
+// new one, then to award the badges whose rules they now meet
+func updateTrustLevels() {
+	rows, err := auth.DB.Query("SELECT id, COALESCE(role, 'user'), COALESCE(trust_level, 0) FROM users")
+	if err != nil {
+		log.Println("Error listing users for trust levels:", err)
+		return
+	}
+	type member struct {
+		id, role string
+		level    int
+	}
+	var members []member
+	for rows.Next() {
+		var m member
+		if rows.Scan(&m.id, &m.role, &m.level) == nil {
+			members = append(members, m)
+		}
+	}
+	rows.Close()
+	for _, m := range members {
+		level := computeTrustLevel(m.id, m.role)
+		if level == m.level {
+			continue
+		}
+		if _, err := auth.DB.Exec("UPDATE users SET trust_level = ? WHERE id = ?", level, m.id); err != nil {
+			log.Println("Error updating trust level:", err)
+			continue
+		}
+		// Only a promotion is worth a notification
+		if level > m.level {
+			CreateNotification(NotificationEvent{
+				UserID:     m.id,
+				Action:     "achievement",
+				TargetType: "user",
+				TargetID:   m.id,
+				Template:   "trust_level",
+				Params:     map[string]string{"level": strconv.Itoa(level), "name": auth.TrustLevels[level]},
+			})
+		}
+	}
+	awardBadges()
+}
+
+// Function to start the worker updating the trust levels and badges
+func StartReputationWorker(interval time.Duration) {
+	go func() {
+		for {
+			updateTrustLevels()
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Links in a text, the new users cannot post them
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.[a-z0-9-]+\.`)
+
+// Post waiting for the review of a moderator
+type PendingPost struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	HeldAt    time.Time `json:"held_at"`
+}
+
+// Function to check whether a text contains a link
+func containsLink(text string) bool {
+	return linkPattern.MatchString(text)
+}
+
+// Function to list the posts held for review, the oldest first
+func GetPendingPosts(w http.ResponseWriter, r *http.Request) {
+	rows, err := auth.DB.Query(`SELECT p.id, COALESCE(u.username, 'deleted user'), p.title, p.content, p.created_at, p.held_at
+		FROM posts p LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.held_at IS NOT NULL AND p.deleted_at IS NULL ORDER BY p.held_at`)
+	if err != nil {
+		http.Error(w, "Error retrieving pending posts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	posts := []PendingPost{}
+	for rows.Next() {
+		var post PendingPost
+		if err := rows.Scan(&post.ID, &post.Username, &post.Title, &post.Content, &post.CreatedAt, &post.HeldAt); err != nil {
+			http.Error(w, "Error reading pending posts", http.StatusInternalServerError)
+			return
+		}
+		posts = append(posts, post)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+// Function to find the author of a post held for review
+func heldPostAuthor(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return "", "", false
+	}
+	postID := r.FormValue("post_id")
+	var authorID string
+	err := auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ? AND held_at IS NOT NULL AND deleted_at IS NULL", postID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found in the review queue", http.StatusNotFound)
+		return "", "", false
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return "", "", false
+	}
+	return postID, authorID, true
+}
+
+// Function to approve a post held for review, it goes live now or at its scheduled date
+func ApprovePost(w http.ResponseWriter, r *http.Request) {
+	postID, authorID, ok := heldPostAuthor(w, r)
+	if !ok {
+		return
+	}
+	if _, err := auth.DB.Exec("UPDATE posts SET held_at = NULL WHERE id = ?", postID); err != nil {
+		http.Error(w, "Error approving post", http.StatusInternalServerError)
+		return
+	}
+	CreateNotification(NotificationEvent{
+		UserID:     authorID,
+		Action:     "moderation",
+		PostID:     postID,
+		TargetType: "post",
+		TargetID:   postID,
+		Template:   "post_approved",
+	})
+	// The scheduler publishes the post and tells its readers
+	publishScheduledPosts()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post approved successfully"})
+}
+
+// Function to reject a post held for review, it goes to the trash
+func RejectPost(w http.ResponseWriter, r *http.Request) {
+	postID, authorID, ok := heldPostAuthor(w, r)
+	if !ok {
+		return
+	}
+	moderatorID, _ := auth.GetUserFromSession(r)
+	if _, err := trashPost(postID, moderatorID); err != nil {
+		http.Error(w, "Error rejecting post", http.StatusInternalServerError)
+		return
+	}
+	recordReputationEvent(authorID, postID, "post_rejected", postRejectedReputation)
+	CreateNotification(NotificationEvent{
+		UserID:     authorID,
+		Action:     "moderation",
+		PostID:     postID,
+		TargetType: "post",
+		TargetID:   postID,
+		Template:   "post_rejected",
+		Params:     map[string]string{"reason": r.FormValue("reason")},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Post rejected successfully"})
+}
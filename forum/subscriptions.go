@@ -0,0 +1,198 @@
+package forum
+
+import (
+	"Forum/auth"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Posts of the followed posts, categories, users and tags, the user ID is given four times
+const followingCondition = `(p.id IN (SELECT target_id FROM subscriptions WHERE user_id = ? AND target_type = 'post')
+	OR p.user_id IN (SELECT target_id FROM subscriptions WHERE user_id = ? AND target_type = 'user')
+	OR p.id IN (SELECT fpc.post_id FROM post_categories fpc JOIN subscriptions fs ON fs.target_type = 'category' AND fs.target_id = CAST(fpc.category_id AS TEXT) WHERE fs.user_id = ?)
+	OR p.id IN (SELECT fpt.post_id FROM post_tags fpt JOIN subscriptions ft ON ft.target_type = 'tag' AND ft.target_id = CAST(fpt.tag_id AS TEXT) WHERE ft.user_id = ?))`
+
+// Query checking that the target of a subscription exists, by type
+var subscriptionTargets = map[string]string{
+	"post":     "SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL AND scheduled_at IS NULL",
+	"category": "SELECT 1 FROM categories WHERE id = ?",
+	"user":     "SELECT 1 FROM users WHERE id = ?",
+	"tag":      "SELECT 1 FROM tags WHERE id = ?",
+}
+
+// Subscription of a user, with the name of what is followed
+type Subscription struct {
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Function to subscribe a user, nothing happens when already subscribed
+func subscribe(userID, targetType, targetID string) error {
+	_, err := auth.DB.Exec("INSERT OR IGNORE INTO subscriptions (user_id, target_type, target_id, created_at) VALUES (?, ?, ?, ?)", userID, targetType, targetID, time.Now())
+	return err
+}
+
+// Function to list the subscribers of a target
+func subscribers(targetType, targetID string) []string {
+	rows, err := auth.DB.Query("SELECT user_id FROM subscriptions WHERE target_type = ? AND target_id = ?", targetType, targetID)
+	if err != nil {
+		log.Println("Error listing subscribers:", err)
+		return nil
+	}
+	defer rows.Close()
+	var users []string
+	for rows.Next() {
+		var userID string
+		if rows.Scan(&userID) == nil {
+			users = append(users, userID)
+		}
+	}
+	return users
+}
+
+// Function to notify the followers of the author, of the categories and of the tags of
+// a new post, the users in skip are already notified
+func notifyNewPost(authorID, postID string, categoryIDs, tagIDs, skip []string) {
+	recipients := subscribers("user", authorID)
+	for _, categoryID := range categoryIDs {
+		recipients = append(recipients, subscribers("category", strings.TrimSpace(categoryID))...)
+	}
+	for _, tagID := range tagIDs {
+		recipients = append(recipients, subscribers("tag", tagID)...)
+	}
+	notified := map[string]bool{authorID: true}
+	for _, userID := range recipients {
+		if notified[userID] || slices.Contains(skip, userID) {
+			continue
+		}
+		notified[userID] = true
+		CreateNotification(NotificationEvent{
+			UserID:     userID,
+			ActorID:    authorID,
+			Action:     "subscription",
+			PostID:     postID,
+			TargetType: "post",
+			TargetID:   postID,
+			Template:   "new_post",
+		})
+	}
+}
+
+// Function to notify the subscribers of a post about a new comment, the users in skip
+// are already notified
+func notifyNewComment(authorID, postID, commentID, content string, skip []string) {
+	for _, userID := range subscribers("post", postID) {
+		if userID == authorID || slices.Contains(skip, userID) {
+			continue
+		}
+		CreateNotification(NotificationEvent{
+			UserID:     userID,
+			ActorID:    authorID,
+			Action:     "reply",
+			PostID:     postID,
+			TargetType: "post",
+			TargetID:   postID,
+			Template:   "reply",
+			Content:    content,
+			Params:     map[string]string{"comment_id": commentID},
+		})
+	}
+}
+
+// Function to list the subscriptions of the user
+func GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := auth.DB.Query(`SELECT s.target_type, s.target_id, s.created_at,
+		COALESCE(CASE s.target_type
+			WHEN 'post' THEN (SELECT title FROM posts WHERE id = s.target_id)
+			WHEN 'category' THEN (SELECT name FROM categories WHERE CAST(id AS TEXT) = s.target_id)
+			WHEN 'user' THEN (SELECT username FROM users WHERE id = s.target_id)
+			WHEN 'tag' THEN (SELECT name FROM tags WHERE CAST(id AS TEXT) = s.target_id)
+		END, '')
+		FROM subscriptions s WHERE s.user_id = ? ORDER BY s.created_at DESC`, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving subscriptions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.TargetType, &sub.TargetID, &sub.CreatedAt, &sub.Name); err != nil {
+			http.Error(w, "Error reading subscriptions", http.StatusInternalServerError)
+			return
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// Function to follow a post, a category, a user or a tag
+func AddSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetType := r.FormValue("target_type")
+	targetID := r.FormValue("target_id")
+	query, ok := subscriptionTargets[targetType]
+	if !ok || targetID == "" {
+		http.Error(w, "Invalid subscription target", http.StatusBadRequest)
+		return
+	}
+	if targetType == "user" && targetID == userID {
+		http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+	if targetType == "user" && isBlocked(userID, targetID) {
+		http.Error(w, "You cannot follow this user", http.StatusForbidden)
+		return
+	}
+	var exists int
+	if auth.DB.QueryRow(query, targetID).Scan(&exists) != nil {
+		http.Error(w, "Subscription target not found", http.StatusNotFound)
+		return
+	}
+	if err := subscribe(userID, targetType, targetID); err != nil {
+		http.Error(w, "Error saving subscription", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Subscribed successfully"})
+}
+
+// Function to stop following a post, a category, a user or a tag
+func RemoveSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := auth.GetUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	_, err = auth.DB.Exec("DELETE FROM subscriptions WHERE user_id = ? AND target_type = ? AND target_id = ?", userID, r.FormValue("target_type"), r.FormValue("target_id"))
+	if err != nil {
+		http.Error(w, "Error removing subscription", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Unsubscribed successfully"})
+}
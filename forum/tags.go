@@ -0,0 +1,435 @@
+package forum
+
+import (
+	"Forum/auth"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxPostTags  = 5
+	maxTagLength = 30
+)
+
+// Queries merging a tag (?1) into another one (?2): the posts, the followers and the
+// synonyms go to the target, the name of the merged tag becomes a synonym of it
+var tagMergeQueries = []string{
+	"INSERT OR IGNORE INTO post_tags (post_id, tag_id) SELECT post_id, ?2 FROM post_tags WHERE tag_id = ?1",
+	"DELETE FROM post_tags WHERE tag_id = ?1",
+	`INSERT OR IGNORE INTO subscriptions (user_id, target_type, target_id, created_at)
+	SELECT user_id, 'tag', ?2, created_at FROM subscriptions WHERE target_type = 'tag' AND target_id = ?1`,
+	"DELETE FROM subscriptions WHERE target_type = 'tag' AND target_id = ?1",
+	"UPDATE tag_synonyms SET tag_id = ?2 WHERE tag_id = ?1",
+	"INSERT OR REPLACE INTO tag_synonyms (synonym, tag_id) SELECT name, ?2 FROM tags WHERE id = ?1",
+	"DELETE FROM tags WHERE id = ?1",
+}
+
+// Tag of a post, with its number of posts when listed
+type Tag struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	PostCount int      `json:"post_count,omitempty"`
+	Synonyms  []string `json:"synonyms,omitempty"`
+}
+
+// Function to normalize a tag: lowercase without accents nor leading #, the words
+// joined by dashes, only letters, digits and the signs of names like c++, c# or node.js kept
+func normalizeTag(tag string) string {
+	tag = accentReplacer.Replace(strings.ToLower(strings.TrimSpace(tag)))
+	var normalized strings.Builder
+	dash := false
+	for _, c := range tag {
+		switch {
+		case (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '+' || c == '#' || c == '.':
+			normalized.WriteRune(c)
+			dash = false
+		case (c == ' ' || c == '-' || c == '_') && !dash && normalized.Len() > 0:
+			normalized.WriteRune('-')
+			dash = true
+		}
+	}
+	result := strings.Trim(strings.TrimLeft(normalized.String(), "#"), "-.")
+	if len(result) > maxTagLength {
+		result = strings.TrimRight(result[:maxTagLength], "-.")
+	}
+	return result
+}
+
+// Function to read a comma separated list of tags, normalized and without duplicates
+func parseTags(value string) ([]string, error) {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxPostTags {
+		return nil, fmt.Errorf("A post can have at most %d tags", maxPostTags)
+	}
+	return tags, nil
+}
+
+// Function to find the tag of a normalized name, through its synonyms too
+func resolveTag(name string) (Tag, bool) {
+	tag := Tag{}
+	err := auth.DB.QueryRow(`SELECT CAST(t.id AS TEXT), t.name FROM tags t
+		WHERE t.name = ?1 OR t.id = (SELECT tag_id FROM tag_synonyms WHERE synonym = ?1) LIMIT 1`, name).Scan(&tag.ID, &tag.Name)
+	return tag, err == nil
+}
+
+// Function to replace the tags of a post, the unknown tags are created
+func setPostTags(postID string, names []string) ([]Tag, error) {
+	var tags []Tag
+	for _, name := range names {
+		tag, ok := resolveTag(name)
+		if !ok {
+			result, err := auth.DB.Exec("INSERT INTO tags (name, created_at) VALUES (?, ?)", name, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			id, _ := result.LastInsertId()
+			tag = Tag{ID: strconv.FormatInt(id, 10), Name: name}
+		}
+		// Two synonyms of the same tag are applied once
+		if !slices.ContainsFunc(tags, func(t Tag) bool { return t.ID == tag.ID }) {
+			tags = append(tags, tag)
+		}
+	}
+	if _, err := auth.DB.Exec("DELETE FROM post_tags WHERE post_id = ?", postID); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		if _, err := auth.DB.Exec("INSERT OR IGNORE INTO post_tags (post_id, tag_id) VALUES (?, ?)", postID, tag.ID); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// Function to load the tags of several posts at once
+func loadPostTags(postIDs []string) map[string][]Tag {
+	result := map[string][]Tag{}
+	if len(postIDs) == 0 {
+		return result
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		args[i] = id
+	}
+	rows, err := auth.DB.Query(`SELECT pt.post_id, CAST(t.id AS TEXT), t.name FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id IN (`+placeholders+`) ORDER BY t.name`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID string
+		var tag Tag
+		if rows.Scan(&postID, &tag.ID, &tag.Name) == nil {
+			result[postID] = append(result[postID], tag)
+		}
+	}
+	return result
+}
+
+// Function to build the condition filtering the posts by tags, with all of them or any of
+// them, the unknown tags match no post
+func tagCondition(value, mode string) (string, []interface{}) {
+	names, _ := parseTags(value)
+	var ids []interface{}
+	for _, name := range names {
+		if tag, ok := resolveTag(name); ok {
+			ids = append(ids, tag.ID)
+		} else if mode == "all" {
+			return "0", nil
+		}
+	}
+	if len(ids) == 0 {
+		return "0", nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	if mode == "all" {
+		return fmt.Sprintf("p.id IN (SELECT post_id FROM post_tags WHERE tag_id IN (%s) GROUP BY post_id HAVING COUNT(*) = %d)", placeholders, len(ids)), ids
+	}
+	return "p.id IN (SELECT post_id FROM post_tags WHERE tag_id IN (" + placeholders + "))", ids
+}
+
+// Function to list the tags with their number of posts, the most used first, whose
+// name or a synonym starts with the text given by q
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	tags, err := listTags(r.URL.Query().Get("q"), limit, 0)
+	if err != nil {
+		http.Error(w, "Error retrieving tags", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Function to load the tags whose name or a synonym starts with a prefix, the most used first
+func listTags(prefix string, limit, offset int) ([]Tag, error) {
+	rows, err := auth.DB.Query(`SELECT CAST(t.id AS TEXT), t.name, COUNT(p.id) AS posts FROM tags t
+		LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		LEFT JOIN posts p ON p.id = pt.post_id AND p.deleted_at IS NULL AND p.scheduled_at IS NULL
+		WHERE ?1 = '' OR t.name LIKE ?1 || '%' ESCAPE '\' OR t.id IN (SELECT tag_id FROM tag_synonyms WHERE synonym LIKE ?1 || '%' ESCAPE '\')
+		GROUP BY t.id ORDER BY posts DESC, t.name LIMIT ?2 OFFSET ?3`, escapeLike(normalizeTag(prefix)), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.PostCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	rows.Close()
+	for i := range tags {
+		tags[i].Synonyms = tagSynonyms(tags[i].ID)
+	}
+	return tags, nil
+}
+
+// Function to list the synonyms of a tag
+func tagSynonyms(tagID string) []string {
+	rows, err := auth.DB.Query("SELECT synonym FROM tag_synonyms WHERE tag_id = ? ORDER BY synonym", tagID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var synonyms []string
+	for rows.Next() {
+		var synonym string
+		if rows.Scan(&synonym) == nil {
+			synonyms = append(synonyms, synonym)
+		}
+	}
+	return synonyms
+}
+
+// Function to escape the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// Function to list the tags most used by the posts of the last days, for the tag cloud
+func GetPopularTags(w http.ResponseWriter, r *http.Request) {
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 || days > 365 {
+		days = 30
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 30
+	}
+	rows, err := auth.DB.Query(`SELECT CAST(t.id AS TEXT), t.name, COUNT(*) AS posts FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id
+		JOIN posts p ON p.id = pt.post_id
+		WHERE p.created_at >= ? AND p.deleted_at IS NULL AND p.scheduled_at IS NULL GROUP BY t.id ORDER BY posts DESC, t.name LIMIT ?`, time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		http.Error(w, "Error retrieving tags", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.PostCount); err != nil {
+			http.Error(w, "Error reading tags", http.StatusInternalServerError)
+			return
+		}
+		tags = append(tags, tag)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Function to replace the tags of a post, allowed to its author and to the moderators
+func UpdatePostTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, role, err := auth.GetUserFromSessionRole(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	postID := r.FormValue("post_id")
+	var postOwner string
+	err = auth.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", postID).Scan(&postOwner)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+	// Only the author tags a post, unless the user has the trust level of the retag_posts
+	// capability, which the moderators always have
+	if postOwner != userID && !auth.HasRole(role, "moderator") && !auth.HasTrust(userID, role, "retag_posts") {
+		http.Error(w, "You can only tag your own posts", http.StatusForbidden)
+		return
+	}
+	names, err := parseTags(r.FormValue("tags"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags, err := setPostTags(postID, names)
+	if err != nil {
+		http.Error(w, "Error saving tags", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Function to find the tag of a form value given by name, through its synonyms too
+func formTag(w http.ResponseWriter, r *http.Request, key string) (Tag, bool) {
+	tag, ok := resolveTag(normalizeTag(r.FormValue(key)))
+	if !ok {
+		http.Error(w, "Tag not found: "+r.FormValue(key), http.StatusNotFound)
+	}
+	return tag, ok
+}
+
+// Function to merge a tag into another one
+func mergeTags(sourceID, targetID string) error {
+	tx, err := auth.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, query := range tagMergeQueries {
+		if _, err := tx.Exec(query, numberedArgs(query, sourceID, targetID)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Function to allows the admin to merge a tag into another one, the merged name stays
+// a synonym of the target
+func MergeTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	source, ok := formTag(w, r, "source")
+	if !ok {
+		return
+	}
+	target, ok := formTag(w, r, "target")
+	if !ok {
+		return
+	}
+	if source.ID == target.ID {
+		http.Error(w, "The tags are already the same", http.StatusBadRequest)
+		return
+	}
+	if err := mergeTags(source.ID, target.ID); err != nil {
+		http.Error(w, "Error merging tags", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Tags merged successfully"})
+}
+
+// Function to list the synonyms of every tag
+func GetTagSynonyms(w http.ResponseWriter, r *http.Request) {
+	rows, err := auth.DB.Query(`SELECT s.synonym, CAST(t.id AS TEXT), t.name FROM tag_synonyms s
+		JOIN tags t ON t.id = s.tag_id ORDER BY t.name, s.synonym`)
+	if err != nil {
+		http.Error(w, "Error retrieving synonyms", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	type TagSynonym struct {
+		Synonym string `json:"synonym"`
+		TagID   string `json:"tag_id"`
+		Tag     string `json:"tag"`
+	}
+	synonyms := []TagSynonym{}
+	for rows.Next() {
+		var synonym TagSynonym
+		if err := rows.Scan(&synonym.Synonym, &synonym.TagID, &synonym.Tag); err != nil {
+			http.Error(w, "Error reading synonyms", http.StatusInternalServerError)
+			return
+		}
+		synonyms = append(synonyms, synonym)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(synonyms)
+}
+
+// Function to allows the admin to add a synonym to a tag, an existing tag of that
+// name is merged into it
+func AddTagSynonym(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	synonym := normalizeTag(r.FormValue("synonym"))
+	if synonym == "" {
+		http.Error(w, "Synonym is required", http.StatusBadRequest)
+		return
+	}
+	tag, ok := formTag(w, r, "tag")
+	if !ok {
+		return
+	}
+	if existing, ok := resolveTag(synonym); ok {
+		if existing.ID == tag.ID {
+			http.Error(w, "The synonym already leads to this tag", http.StatusBadRequest)
+			return
+		}
+		if existing.Name == synonym {
+			if err := mergeTags(existing.ID, tag.ID); err != nil {
+				http.Error(w, "Error merging tags", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"message": "Existing tag merged as a synonym"})
+			return
+		}
+	}
+	_, err := auth.DB.Exec(`INSERT INTO tag_synonyms (synonym, tag_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(synonym) DO UPDATE SET tag_id = excluded.tag_id`, synonym, tag.ID, time.Now())
+	if err != nil {
+		http.Error(w, "Error saving synonym", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Synonym added successfully"})
+}
+
+// Function to allows the admin to remove a synonym, the posts keep their tag
+func RemoveTagSynonym(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	_, err := auth.DB.Exec("DELETE FROM tag_synonyms WHERE synonym = ?", normalizeTag(r.FormValue("synonym")))
+	if err != nil {
+		http.Error(w, "Error removing synonym", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Synonym removed successfully"})
+}
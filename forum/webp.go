@@ -0,0 +1,354 @@
+package forum
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Minimal lossless WebP (VP8L) encoder used for the webp output format.
+// The pixels go through the subtract-green and left-predictor transforms and are
+// written as Huffman-coded literals, without backward references or color cache.
+
+// Order in which the code length code lengths are written (VP8L specification)
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Size of the prediction blocks, as a power of two
+const webpPredictorBits = 9
+
+// Writer packing values least significant bit first
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint
+}
+
+// Function to append the n low bits of v
+func (bw *bitWriter) writeBits(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.n
+	bw.n += n
+	for bw.n >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.n -= 8
+	}
+}
+
+// Function to write the last partial byte
+func (bw *bitWriter) flush() {
+	if bw.n > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.n = 0, 0
+	}
+}
+
+// Huffman code of an alphabet, a zero length means the symbol is never written
+type huffmanCode struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+// Function to write a symbol, Huffman codes are read most significant bit first
+func (hc *huffmanCode) write(bw *bitWriter, symbol int) {
+	length := uint(hc.lengths[symbol])
+	if length == 0 {
+		return
+	}
+	code := uint32(hc.codes[symbol])
+	var reversed uint32
+	for i := uint(0); i < length; i++ {
+		reversed = reversed<<1 | (code>>i)&1
+	}
+	bw.writeBits(reversed, length)
+}
+
+// Function to compute Huffman code lengths limited to maxLength bits
+func huffmanLengths(hist []int, maxLength int) []uint8 {
+	counts := append([]int(nil), hist...)
+	for {
+		lengths, deepest := buildHuffmanTree(counts)
+		if deepest <= maxLength {
+			return lengths
+		}
+		// Flatten the distribution until the tree is shallow enough
+		for i, c := range counts {
+			if c > 0 {
+				counts[i] = (c + 1) / 2
+			}
+		}
+	}
+}
+
+// Function to build a Huffman tree and return the depth of every symbol
+func buildHuffmanTree(counts []int) ([]uint8, int) {
+	type node struct {
+		weight      int
+		symbol      int
+		left, right int
+	}
+	nodes := []node{}
+	active := []int{}
+	for symbol, c := range counts {
+		if c > 0 {
+			nodes = append(nodes, node{weight: c, symbol: symbol, left: -1, right: -1})
+			active = append(active, len(nodes)-1)
+		}
+	}
+	lengths := make([]uint8, len(counts))
+	if len(active) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths, 1
+	}
+	// Merge the two lightest nodes until one tree is left
+	for len(active) > 1 {
+		a, b := 0, 1
+		if nodes[active[b]].weight < nodes[active[a]].weight {
+			a, b = b, a
+		}
+		for i := 2; i < len(active); i++ {
+			w := nodes[active[i]].weight
+			if w < nodes[active[a]].weight {
+				a, b = i, a
+			} else if w < nodes[active[b]].weight {
+				b = i
+			}
+		}
+		nodes = append(nodes, node{weight: nodes[active[a]].weight + nodes[active[b]].weight, symbol: -1, left: active[a], right: active[b]})
+		merged := len(nodes) - 1
+		if a > b {
+			a, b = b, a
+		}
+		active = append(active[:b], active[b+1:]...)
+		active[a] = merged
+	}
+	deepest := 0
+	var walk func(index, depth int)
+	walk = func(index, depth int) {
+		n := nodes[index]
+		if n.symbol >= 0 {
+			lengths[n.symbol] = uint8(depth)
+			deepest = max(deepest, depth)
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	if len(active) == 1 {
+		walk(active[0], 0)
+	}
+	return lengths, deepest
+}
+
+// Function to assign the canonical codes of a set of code lengths
+func canonicalCode(lengths []uint8) *huffmanCode {
+	var count [16]int
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+	var next [16]int
+	code := 0
+	for bits := 1; bits < 16; bits++ {
+		code = (code + count[bits-1]) << 1
+		next[bits] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for symbol, l := range lengths {
+		if l > 0 {
+			codes[symbol] = uint16(next[l])
+			next[l]++
+		}
+	}
+	return &huffmanCode{lengths: lengths, codes: codes}
+}
+
+// Function to write the prefix code of an alphabet and return it for the pixel data
+func writeHuffmanCode(bw *bitWriter, hist []int) *huffmanCode {
+	used := []int{}
+	for symbol, c := range hist {
+		if c > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	// Simple code: one or two 8-bit symbols
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		bw.writeBits(1, 1)
+		bw.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(used[0]), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.writeBits(uint32(used[1]), 8)
+		}
+		lengths := make([]uint8, len(hist))
+		if len(used) == 2 {
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		return canonicalCode(lengths)
+	}
+
+	// Normal code: the code lengths are themselves Huffman coded
+	lengths := huffmanLengths(hist, 15)
+	lengthHist := make([]int, 19)
+	for _, l := range lengths {
+		lengthHist[l]++
+	}
+	// A code length code needs two symbols to be complete
+	nonZero := 0
+	for _, c := range lengthHist {
+		if c > 0 {
+			nonZero++
+		}
+	}
+	if nonZero < 2 {
+		if lengthHist[0] == 0 {
+			lengthHist[0] = 1
+		} else {
+			lengthHist[1] = 1
+		}
+	}
+	lengthCode := canonicalCode(huffmanLengths(lengthHist, 7))
+
+	count := 4
+	for i := len(codeLengthCodeOrder) - 1; i >= 4; i-- {
+		if lengthCode.lengths[codeLengthCodeOrder[i]] != 0 {
+			count = i + 1
+			break
+		}
+	}
+	bw.writeBits(0, 1)
+	bw.writeBits(uint32(count-4), 4)
+	for i := 0; i < count; i++ {
+		bw.writeBits(uint32(lengthCode.lengths[codeLengthCodeOrder[i]]), 3)
+	}
+	// Every code length of the alphabet is written
+	bw.writeBits(0, 1)
+	for _, l := range lengths {
+		lengthCode.write(bw, int(l))
+	}
+	return canonicalCode(lengths)
+}
+
+// Function to encode an image as a lossless WebP file
+func encodeWebP(w io.Writer, img *image.RGBA) error {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < 1 || height < 1 || width > 16384 || height > 16384 {
+		return fmt.Errorf("unsupported image size for webp")
+	}
+	// Un-premultiply and apply the subtract-green transform
+	n := width * height
+	argb := make([][4]uint8, n)
+	alphaUsed := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*img.Stride + x*4
+			r, g, b, a := img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]
+			if a != 255 {
+				alphaUsed = true
+				if a > 0 {
+					r = uint8(int(r) * 255 / int(a))
+					g = uint8(int(g) * 255 / int(a))
+					b = uint8(int(b) * 255 / int(a))
+				}
+			}
+			argb[y*width+x] = [4]uint8{a, r - g, g, b - g}
+		}
+	}
+	// Left predictor: the first pixel is predicted as opaque black, the left column from the top
+	residuals := make([][4]uint8, n)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var pred [4]uint8
+			switch {
+			case x == 0 && y == 0:
+				pred = [4]uint8{255, 0, 0, 0}
+			case x == 0:
+				pred = argb[(y-1)*width]
+			default:
+				pred = argb[y*width+x-1]
+			}
+			cur := argb[y*width+x]
+			residuals[y*width+x] = [4]uint8{cur[0] - pred[0], cur[1] - pred[1], cur[2] - pred[2], cur[3] - pred[3]}
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if alphaUsed {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3)
+
+	// Transforms are undone in reverse order by the decoder
+	bw.writeBits(1, 1)
+	bw.writeBits(2, 2) // subtract green
+	bw.writeBits(1, 1)
+	bw.writeBits(0, 2) // predictor
+	bw.writeBits(webpPredictorBits-2, 3)
+	// Prediction modes sub-image: every block uses mode 1, all its codes have a single symbol
+	bw.writeBits(0, 1) // no color cache
+	writeHuffmanCode(bw, []int{0, 1})
+	for i := 0; i < 4; i++ {
+		writeHuffmanCode(bw, []int{1})
+	}
+	bw.writeBits(0, 1) // end of transforms
+
+	// Main image
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // no meta prefix codes
+	green := make([]int, 256+24)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	for _, p := range residuals {
+		alpha[p[0]]++
+		red[p[1]]++
+		green[p[2]]++
+		blue[p[3]]++
+	}
+	greenCode := writeHuffmanCode(bw, green)
+	redCode := writeHuffmanCode(bw, red)
+	blueCode := writeHuffmanCode(bw, blue)
+	alphaCode := writeHuffmanCode(bw, alpha)
+	writeHuffmanCode(bw, make([]int, 40)) // distance codes are never used
+	for _, p := range residuals {
+		greenCode.write(bw, int(p[2]))
+		redCode.write(bw, int(p[1]))
+		blueCode.write(bw, int(p[3]))
+		alphaCode.write(bw, int(p[0]))
+	}
+	bw.flush()
+
+	// RIFF container
+	payload := bw.buf
+	padding := len(payload) % 2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(payload)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
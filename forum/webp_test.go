@@ -0,0 +1,107 @@
+package forum
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// Function to build an image of a size whose pixels are given by a function
+func testImage(width, height int, pixel func(x, y int) color.NRGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, pixel(x, y))
+		}
+	}
+	return img
+}
+
+// Function to tell whether two channels differ by more than the tolerance
+func channelDiffers(a, b uint8, tolerance int) bool {
+	diff := int(a) - int(b)
+	return diff > tolerance || diff < -tolerance
+}
+
+// The encoded images decode to the same pixels with the reference decoder. The opaque
+// pixels are exact, the translucent ones are un-premultiplied and may differ by one.
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	noise := make([]color.NRGBA, 40*30)
+	for i := range noise {
+		noise[i] = color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255}
+	}
+	cases := map[string]*image.RGBA{
+		"solid": testImage(16, 16, func(x, y int) color.NRGBA {
+			return color.NRGBA{200, 30, 90, 255}
+		}),
+		"single pixel": testImage(1, 1, func(x, y int) color.NRGBA {
+			return color.NRGBA{1, 2, 3, 255}
+		}),
+		"gradient": testImage(64, 48, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 4), uint8(y * 5), uint8(x + y), 255}
+		}),
+		"odd size": testImage(17, 9, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 15), 128, uint8(y * 28), 255}
+		}),
+		"single column": testImage(1, 13, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(y * 19), uint8(255 - y*19), 7, 255}
+		}),
+		// Wider than a prediction block
+		"across blocks": testImage(600, 3, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(x / 3), uint8(y * 100), 255}
+		}),
+		"noise": testImage(40, 30, func(x, y int) color.NRGBA {
+			return noise[y*40+x]
+		}),
+		"alpha": testImage(33, 21, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 7), uint8(y * 12), 200, uint8((x*8 + y*3) % 256)}
+		}),
+		"transparent": testImage(5, 7, func(x, y int) color.NRGBA {
+			return color.NRGBA{}
+		}),
+	}
+	for name, img := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+			bounds := img.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					want := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					tolerance := 0
+					if want.A != 255 {
+						tolerance = 1
+					}
+					if got.A != want.A || channelDiffers(got.R, want.R, tolerance) ||
+						channelDiffers(got.G, want.G, tolerance) || channelDiffers(got.B, want.B, tolerance) {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// The images out of the range of the format are refused
+func TestEncodeWebPInvalidSize(t *testing.T) {
+	for _, rect := range []image.Rectangle{image.Rect(0, 0, 0, 5), image.Rect(0, 0, 16385, 1)} {
+		if err := encodeWebP(&bytes.Buffer{}, image.NewRGBA(rect)); err == nil {
+			t.Errorf("%v image encoded", rect.Size())
+		}
+	}
+}
@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/google/uuid v1.6.0
 	golang.org/x/crypto v0.36.0
+	golang.org/x/image v0.27.0
 	golang.org/x/oauth2 v0.27.0
 )
 
@@ -0,0 +1,91 @@
+package security
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// CaptchaVerifier checks the response of a CAPTCHA challenge solved by a client
+type CaptchaVerifier interface {
+	Verify(response, ip string) (bool, error)
+}
+
+// Widget settings sent to the login page
+type CaptchaConfig struct {
+	SiteKey     string `json:"site_key"`
+	ScriptURL   string `json:"script_url"`
+	WidgetClass string `json:"widget_class"`
+}
+
+// Verifier for the siteverify API shared by hCaptcha, reCAPTCHA and Turnstile
+type SiteVerifyCaptcha struct {
+	VerifyURL string
+	Secret    string
+	Config    CaptchaConfig
+	client    *http.Client
+}
+
+// Function to create the verifier configured by CAPTCHA_SECRET and CAPTCHA_SITE_KEY,
+// hCaptcha unless CAPTCHA_VERIFY_URL, CAPTCHA_SCRIPT_URL and CAPTCHA_WIDGET_CLASS say otherwise.
+// It returns nil when no secret is set, the login then never asks for a CAPTCHA.
+func NewCaptchaFromEnv() *SiteVerifyCaptcha {
+	secret := os.Getenv("CAPTCHA_SECRET")
+	if secret == "" {
+		return nil
+	}
+	c := &SiteVerifyCaptcha{
+		VerifyURL: envOr("CAPTCHA_VERIFY_URL", "https://api.hcaptcha.com/siteverify"),
+		Secret:    secret,
+		Config: CaptchaConfig{
+			SiteKey:     os.Getenv("CAPTCHA_SITE_KEY"),
+			ScriptURL:   envOr("CAPTCHA_SCRIPT_URL", "https://js.hcaptcha.com/1/api.js"),
+			WidgetClass: envOr("CAPTCHA_WIDGET_CLASS", "h-captcha"),
+		},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	return c
+}
+
+// Function to read an environment variable with a default value
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Function to ask the provider whether the response is valid
+func (c *SiteVerifyCaptcha) Verify(response, ip string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+	resp, err := c.client.PostForm(c.VerifyURL, url.Values{
+		"secret":   {c.Secret},
+		"response": {response},
+		"remoteip": {ip},
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
+
+// Function to read the CAPTCHA response of a form, whatever the widget that produced it
+func CaptchaResponse(r *http.Request) string {
+	for _, field := range []string{"captcha_response", "h-captcha-response", "g-recaptcha-response", "cf-turnstile-response"} {
+		if value := r.FormValue(field); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package security
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Number of requests allowed per period, Burst requests may arrive at once
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// Function to return the burst of a rate, the limit when it is not set
+func (r Rate) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// Result of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // time until the key is back to a full budget
+	RetryAfter time.Duration // time until the next request is allowed, when refused
+}
+
+// State kept for a key, its meaning depends on the algorithm
+type State struct {
+	Time  time.Time
+	Value float64
+}
+
+// Algorithm computing the next state of a key and the decision for a request
+type Algorithm func(state State, found bool, rate Rate, now time.Time) (State, Decision)
+
+// Limiter decides whether a request identified by a key is allowed
+type Limiter interface {
+	Allow(key string, rate Rate) (Decision, error)
+}
+
+// Generic cell rate algorithm: State.Time is the theoretical arrival time of the next request
+func GCRA(state State, found bool, rate Rate, now time.Time) (State, Decision) {
+	burst := rate.burst()
+	interval := rate.Period / time.Duration(rate.Limit)
+	tolerance := interval * time.Duration(burst)
+	decision := Decision{Limit: burst}
+
+	tat := state.Time
+	if !found || tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-tolerance)
+	if now.Before(allowAt) {
+		decision.RetryAfter = allowAt.Sub(now)
+		decision.ResetAfter = tat.Sub(now)
+		return State{Time: tat}, decision
+	}
+	decision.Allowed = true
+	decision.ResetAfter = newTat.Sub(now)
+	decision.Remaining = int((tolerance - decision.ResetAfter) / interval)
+	return State{Time: newTat}, decision
+}
+
+// Token bucket: State.Value is the number of tokens left at State.Time
+func TokenBucket(state State, found bool, rate Rate, now time.Time) (State, Decision) {
+	capacity := float64(rate.burst())
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+	decision := Decision{Limit: rate.burst()}
+
+	tokens := capacity
+	if found {
+		tokens = math.Min(capacity, state.Value+now.Sub(state.Time).Seconds()*perSecond)
+	}
+	if tokens < 1 {
+		decision.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+		decision.ResetAfter = time.Duration((capacity - tokens) / perSecond * float64(time.Second))
+		return State{Time: now, Value: tokens}, decision
+	}
+	tokens--
+	decision.Allowed = true
+	decision.Remaining = int(tokens)
+	decision.ResetAfter = time.Duration((capacity - tokens) / perSecond * float64(time.Second))
+	return State{Time: now, Value: tokens}, decision
+}
+
+// Function to round a duration up to whole seconds for the headers
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Function to write the standard RateLimit-* headers and Retry-After when the request is refused
+func WriteHeaders(w http.ResponseWriter, rate Rate, d Decision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("RateLimit-Reset", ceilSeconds(d.ResetAfter))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(rate.Limit)+";w="+ceilSeconds(rate.Period))
+	if !d.Allowed {
+		w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+	}
+}
+
+// Function to create the limiter selected by RATE_LIMIT_BACKEND ("memory" or "sqlite")
+// and RATE_LIMIT_ALGORITHM ("gcra" or "token-bucket")
+func NewLimiterFromEnv(db *sql.DB) Limiter {
+	algorithm := GCRA
+	if os.Getenv("RATE_LIMIT_ALGORITHM") == "token-bucket" {
+		algorithm = TokenBucket
+	}
+	if os.Getenv("RATE_LIMIT_BACKEND") == "sqlite" && db != nil {
+		return NewSQLiteLimiter(db, algorithm)
+	}
+	return NewMemoryLimiter(algorithm)
+}
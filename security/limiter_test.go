@@ -0,0 +1,191 @@
+package security
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/xeodou/go-sqlcipher"
+)
+
+// Rate of the algorithm tests: one request per second with a burst of three
+var testRate = Rate{Limit: 10, Period: 10 * time.Second, Burst: 3}
+
+// Requests sent to an algorithm, its state is kept between them
+type algorithmRun struct {
+	algorithm Algorithm
+	state     State
+	found     bool
+}
+
+// Function to send a request at a given time
+func (run *algorithmRun) allow(rate Rate, now time.Time) Decision {
+	state, decision := run.algorithm(run.state, run.found, rate, now)
+	run.state, run.found = state, true
+	return decision
+}
+
+// Both algorithms let the burst through, refuse the next request until one interval passed
+// and tell when to retry
+func TestAlgorithmsAllowThenDeny(t *testing.T) {
+	for name, algorithm := range map[string]Algorithm{"gcra": GCRA, "token-bucket": TokenBucket} {
+		t.Run(name, func(t *testing.T) {
+			run := &algorithmRun{algorithm: algorithm}
+			now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i := 0; i < testRate.Burst; i++ {
+				decision := run.allow(testRate, now)
+				if !decision.Allowed {
+					t.Fatalf("request %d of the burst refused", i+1)
+				}
+				if want := testRate.Burst - i - 1; decision.Remaining != want {
+					t.Errorf("request %d: remaining = %d, want %d", i+1, decision.Remaining, want)
+				}
+				if decision.Limit != testRate.Burst {
+					t.Errorf("limit = %d, want %d", decision.Limit, testRate.Burst)
+				}
+			}
+			decision := run.allow(testRate, now)
+			if decision.Allowed {
+				t.Fatal("request over the burst allowed")
+			}
+			if decision.RetryAfter != time.Second {
+				t.Errorf("retry after = %v, want 1s", decision.RetryAfter)
+			}
+			if decision.Remaining != 0 {
+				t.Errorf("remaining of a refused request = %d", decision.Remaining)
+			}
+			// A refused request does not consume the budget
+			if decision := run.allow(testRate, now.Add(time.Second)); !decision.Allowed {
+				t.Error("request refused once the interval passed")
+			}
+			if decision := run.allow(testRate, now.Add(time.Second)); decision.Allowed {
+				t.Error("second request allowed within the same interval")
+			}
+			// The whole burst is back after a full refill
+			for i := 0; i < testRate.Burst; i++ {
+				if decision := run.allow(testRate, now.Add(time.Minute)); !decision.Allowed {
+					t.Errorf("request %d refused after the refill", i+1)
+				}
+			}
+		})
+	}
+}
+
+// Without a burst, the whole limit may arrive at once
+func TestAlgorithmsDefaultBurst(t *testing.T) {
+	rate := Rate{Limit: 5, Period: time.Minute}
+	for name, algorithm := range map[string]Algorithm{"gcra": GCRA, "token-bucket": TokenBucket} {
+		t.Run(name, func(t *testing.T) {
+			run := &algorithmRun{algorithm: algorithm}
+			now := time.Now()
+			for i := 0; i < rate.Limit; i++ {
+				if !run.allow(rate, now).Allowed {
+					t.Fatalf("request %d refused", i+1)
+				}
+			}
+			decision := run.allow(rate, now)
+			if decision.Allowed {
+				t.Fatal("request over the limit allowed")
+			}
+			if decision.RetryAfter != 12*time.Second {
+				t.Errorf("retry after = %v, want 12s", decision.RetryAfter)
+			}
+		})
+	}
+}
+
+// Retry-After is rounded up to whole seconds and only sent with a refusal
+func TestWriteHeadersRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteHeaders(w, testRate, Decision{Limit: 3, ResetAfter: 2500 * time.Millisecond, RetryAfter: 1200 * time.Millisecond})
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "3" {
+		t.Errorf("RateLimit-Reset = %q, want 3", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "10;w=10" {
+		t.Errorf("RateLimit-Policy = %q, want 10;w=10", got)
+	}
+	w = httptest.NewRecorder()
+	WriteHeaders(w, testRate, Decision{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second})
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After sent with an allowed request: %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "2" {
+		t.Errorf("RateLimit-Remaining = %q, want 2", got)
+	}
+}
+
+// The limiters keep one budget per key
+func TestLimitersSeparateKeys(t *testing.T) {
+	limiters := map[string]Limiter{
+		"memory": NewMemoryLimiter(GCRA),
+		"sqlite": NewSQLiteLimiter(openTestDB(t), GCRA),
+	}
+	rate := Rate{Limit: 2, Period: time.Hour}
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < rate.Limit; i++ {
+				if decision, err := limiter.Allow("user:a", rate); err != nil || !decision.Allowed {
+					t.Fatalf("request %d of a: %+v, %v", i+1, decision, err)
+				}
+			}
+			if decision, err := limiter.Allow("user:a", rate); err != nil || decision.Allowed {
+				t.Errorf("request over the limit of a: %+v, %v", decision, err)
+			}
+			if decision, err := limiter.Allow("user:b", rate); err != nil || !decision.Allowed {
+				t.Errorf("first request of b: %+v, %v", decision, err)
+			}
+		})
+	}
+}
+
+// Function to open an empty database for a test
+func openTestDB(tb testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(tb.TempDir(), "limits.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Function to send requests from parallel goroutines spread over many keys
+func benchmarkLimiter(b *testing.B, limiter Limiter) {
+	const keys = 10000
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "ip:10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+	rate := Rate{Limit: 100, Period: time.Minute, Burst: 10}
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := limiter.Allow(names[next.Add(1)%keys], rate); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// In-memory limiter, its shards are locked independently
+func BenchmarkMemoryLimiter(b *testing.B) {
+	benchmarkLimiter(b, NewMemoryLimiter(GCRA))
+}
+
+func BenchmarkMemoryLimiterTokenBucket(b *testing.B) {
+	benchmarkLimiter(b, NewMemoryLimiter(TokenBucket))
+}
+
+// SQLite limiter, one transaction per request
+func BenchmarkSQLiteLimiter(b *testing.B) {
+	benchmarkLimiter(b, NewSQLiteLimiter(openTestDB(b), GCRA))
+}
@@ -0,0 +1,211 @@
+package security
+
+import (
+	"Forum/db"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thresholds of the login protection
+type LoginPolicy struct {
+	Window          time.Duration // failures older than the window are forgotten
+	FreeAttempts    int           // failures allowed before any delay
+	BaseDelay       time.Duration // delay after the first counted failure, doubled at each new one
+	AccountMaxDelay time.Duration // kept short so an attacker cannot lock the owner out for long
+	IPMaxDelay      time.Duration
+	CaptchaAfter    int // failures after which a CAPTCHA is required, when a verifier is configured
+	BurstAlert      int // failures on an account that trigger a notification to its owner
+	IPAccountLimit  int // distinct accounts failing from one IP before it is treated as credential stuffing
+}
+
+// Function to return the default login policy
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		Window:          time.Hour,
+		FreeAttempts:    3,
+		BaseDelay:       2 * time.Second,
+		AccountMaxDelay: 5 * time.Minute,
+		IPMaxDelay:      time.Hour,
+		CaptchaAfter:    5,
+		BurstAlert:      10,
+		IPAccountLimit:  10,
+	}
+}
+
+// Result of the check made before a login attempt
+type LoginCheck struct {
+	RetryAfter      time.Duration // the attempt is refused until then when positive
+	CaptchaRequired bool
+	Stuffing        bool  // the IP failed on too many different accounts
+	Attempt         int64 // attempt reserved when it may proceed, passed to Failure or Release
+}
+
+// Result of a recorded failure
+type LoginFailure struct {
+	AccountFailures int
+	Suspicious      bool // the account just reached the alert threshold
+}
+
+// LoginLimiter tracks the failed logins per account and per IP in the login_failures
+// table, so the protection survives a restart. Nothing sleeps: a refused attempt
+// gets the time after which it may retry.
+type LoginLimiter struct {
+	db     *sql.DB
+	policy LoginPolicy
+	mu     sync.Mutex
+}
+
+// Function to create a login limiter on a database and start the cleanup of the old failures
+func NewLoginLimiter(db *sql.DB, policy LoginPolicy) *LoginLimiter {
+	db.Exec(`CREATE TABLE IF NOT EXISTS login_failures (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+	)`)
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_login_failures_account ON login_failures(account, created_at)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_login_failures_ip ON login_failures(ip, created_at)")
+	ll := &LoginLimiter{db: db, policy: policy}
+	go func() {
+		for {
+			time.Sleep(10 * time.Minute)
+			if _, err := db.Exec("DELETE FROM login_failures WHERE created_at < ?", time.Now().UTC().Add(-policy.Window)); err != nil {
+				log.Println("Error cleaning login failures:", err)
+			}
+		}
+	}()
+	return ll
+}
+
+// Function to normalize the account identifier, the email typed in the form
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// Function to compute the delay after n failures: none for the free attempts, then doubling up to max
+func (ll *LoginLimiter) delay(n int, max time.Duration) time.Duration {
+	counted := n - ll.policy.FreeAttempts
+	if counted <= 0 {
+		return 0
+	}
+	d := ll.policy.BaseDelay
+	for i := 1; i < counted && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Function to count the recent failures matching a column and return the time of the last one
+func (ll *LoginLimiter) recent(column, value string, since time.Time) (int, time.Time, error) {
+	var count int
+	var last sql.NullString
+	err := ll.db.QueryRow("SELECT COUNT(*), MAX(created_at) FROM login_failures WHERE "+column+" = ? AND created_at >= ?", value, since).Scan(&count, &last)
+	if err != nil || !last.Valid {
+		return count, time.Time{}, err
+	}
+	lastTime, err := db.ParseTime(last.String)
+	return count, lastTime, err
+}
+
+// Function to check whether a login attempt on an account from an IP may proceed.
+// A solved CAPTCHA lifts the account delay but never the IP one, so the owner can
+// still log in while an attacker keeps failing on the account.
+// An attempt that may proceed is reserved as a failure in the same step, so parallel
+// attempts count each other and a burst gets the delays too. Success clears it.
+func (ll *LoginLimiter) Check(ip, account string, captchaSolved bool) (LoginCheck, error) {
+	var check LoginCheck
+	now := time.Now().UTC()
+	since := now.Add(-ll.policy.Window)
+	account = normalizeAccount(account)
+
+	// Attempts of this process are checked and reserved one at a time
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ipFailures, ipLast, err := ll.recent("ip", ip, since)
+	if err != nil {
+		return check, err
+	}
+	var accounts int
+	if err := ll.db.QueryRow("SELECT COUNT(DISTINCT account) FROM login_failures WHERE ip = ? AND created_at >= ?", ip, since).Scan(&accounts); err != nil {
+		return check, err
+	}
+	if accounts >= ll.policy.IPAccountLimit {
+		// Credential stuffing: the IP stays blocked for a whole window after its last failure
+		check.Stuffing = true
+		check.RetryAfter = ipLast.Add(ll.policy.Window).Sub(now)
+		return check, nil
+	}
+	if wait := ipLast.Add(ll.delay(ipFailures, ll.policy.IPMaxDelay)).Sub(now); ipFailures > 0 && wait > check.RetryAfter {
+		check.RetryAfter = wait
+	}
+
+	accountFailures, accountLast, err := ll.recent("account", account, since)
+	if err != nil {
+		return check, err
+	}
+	if wait := accountLast.Add(ll.delay(accountFailures, ll.policy.AccountMaxDelay)).Sub(now); accountFailures > 0 && !captchaSolved && wait > check.RetryAfter {
+		check.RetryAfter = wait
+	}
+	check.CaptchaRequired = accountFailures >= ll.policy.CaptchaAfter || ipFailures >= ll.policy.CaptchaAfter
+	if check.RetryAfter > 0 {
+		return check, nil
+	}
+	result, err := ll.db.Exec("INSERT INTO login_failures (account, ip, created_at) VALUES (?, ?, ?)", account, ip, now)
+	if err != nil {
+		return check, err
+	}
+	check.Attempt, err = result.LastInsertId()
+	return check, err
+}
+
+// Function to record the failure of an attempt reserved by Check on an account from an IP
+func (ll *LoginLimiter) Failure(attempt int64, ip, account string) (LoginFailure, error) {
+	var result LoginFailure
+	now := time.Now().UTC()
+	account = normalizeAccount(account)
+
+	// The delays start from the end of the attempt, a reservation cleared meanwhile by
+	// a parallel success is recorded again
+	updated, err := ll.db.Exec("UPDATE login_failures SET created_at = ? WHERE id = ?", now, attempt)
+	if err != nil {
+		return result, err
+	}
+	if n, _ := updated.RowsAffected(); n == 0 {
+		inserted, err := ll.db.Exec("INSERT INTO login_failures (account, ip, created_at) VALUES (?, ?, ?)", account, ip, now)
+		if err != nil {
+			return result, err
+		}
+		if attempt, err = inserted.LastInsertId(); err != nil {
+			return result, err
+		}
+	}
+	// The rank of the attempt among the failures of the account, so only one of them
+	// reaches the alert threshold
+	err = ll.db.QueryRow("SELECT COUNT(*) FROM login_failures WHERE account = ? AND created_at >= ? AND id <= ?",
+		account, now.Add(-ll.policy.Window), attempt).Scan(&result.AccountFailures)
+	if err != nil {
+		return result, err
+	}
+	result.Suspicious = result.AccountFailures == ll.policy.BurstAlert
+	return result, nil
+}
+
+// Function to cancel an attempt reserved by Check that was refused before the password was checked
+func (ll *LoginLimiter) Release(attempt int64) error {
+	_, err := ll.db.Exec("DELETE FROM login_failures WHERE id = ?", attempt)
+	return err
+}
+
+// Function to forget the failures of an account after a successful login, those of the IP
+// on other accounts are kept for the credential stuffing detection
+func (ll *LoginLimiter) Success(ip, account string) error {
+	_, err := ll.db.Exec("DELETE FROM login_failures WHERE account = ?", normalizeAccount(account))
+	return err
+}
@@ -0,0 +1,66 @@
+package security
+
+import (
+	"sync"
+	"testing"
+)
+
+// Parallel attempts count each other, a burst gets the delays like sequential failures
+func TestLoginCheckReservesParallelAttempts(t *testing.T) {
+	policy := DefaultLoginPolicy()
+	ll := NewLoginLimiter(openTestDB(t), policy)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check, err := ll.Check("203.0.113.7", "victim@example.com", false)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if check.RetryAfter <= 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// The free attempts pass, the next one is the first to wait
+	if allowed != policy.FreeAttempts+1 {
+		t.Errorf("%d parallel attempts allowed, want %d", allowed, policy.FreeAttempts+1)
+	}
+}
+
+// A failure keeps the reservation, a success clears it and a release cancels it
+func TestLoginReservationLifecycle(t *testing.T) {
+	ll := NewLoginLimiter(openTestDB(t), DefaultLoginPolicy())
+	count := func() int {
+		var n int
+		ll.db.QueryRow("SELECT COUNT(*) FROM login_failures WHERE account = 'user@example.com'").Scan(&n)
+		return n
+	}
+	check, err := ll.Check("203.0.113.8", "User@Example.com ", false)
+	if err != nil || check.Attempt == 0 {
+		t.Fatalf("check = %+v, %v", check, err)
+	}
+	failure, err := ll.Failure(check.Attempt, "203.0.113.8", "User@Example.com ")
+	if err != nil || failure.AccountFailures != 1 || count() != 1 {
+		t.Fatalf("failure = %+v, %v, %d rows", failure, err, count())
+	}
+	check, _ = ll.Check("203.0.113.8", "user@example.com", false)
+	if err := ll.Release(check.Attempt); err != nil || count() != 1 {
+		t.Errorf("release: %v, %d rows", err, count())
+	}
+	check, _ = ll.Check("203.0.113.8", "user@example.com", false)
+	if err := ll.Success("203.0.113.8", "user@example.com"); err != nil || count() != 0 {
+		t.Errorf("success: %v, %d rows", err, count())
+	}
+	// A reservation cleared by a parallel success is recorded again on failure
+	if failure, err := ll.Failure(check.Attempt, "203.0.113.8", "user@example.com"); err != nil || failure.AccountFailures != 1 {
+		t.Errorf("failure after a success = %+v, %v", failure, err)
+	}
+}
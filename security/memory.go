@@ -0,0 +1,77 @@
+package security
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Number of independently locked parts of the in-memory limiter
+const memoryShards = 64
+
+// Entry of a key in memory
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// Part of the keys with its own lock, so concurrent requests rarely wait for each other
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// Limiter keeping the state of the keys in memory, split in shards
+type MemoryLimiter struct {
+	algorithm Algorithm
+	shards    [memoryShards]memoryShard
+}
+
+// Function to create an in-memory limiter and start the cleanup of the expired keys
+func NewMemoryLimiter(algorithm Algorithm) *MemoryLimiter {
+	ml := &MemoryLimiter{algorithm: algorithm}
+	for i := range ml.shards {
+		ml.shards[i].entries = make(map[string]memoryEntry)
+	}
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			ml.cleanup()
+		}
+	}()
+	return ml
+}
+
+// Function to find the shard of a key
+func (ml *MemoryLimiter) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &ml.shards[h.Sum32()%memoryShards]
+}
+
+// Function to check and record a request, the lock is only held for the computation
+func (ml *MemoryLimiter) Allow(key string, rate Rate) (Decision, error) {
+	s := ml.shard(key)
+	now := time.Now()
+	s.mu.Lock()
+	entry, found := s.entries[key]
+	state, decision := ml.algorithm(entry.state, found, rate, now)
+	s.entries[key] = memoryEntry{state: state, expires: now.Add(decision.ResetAfter)}
+	s.mu.Unlock()
+	return decision, nil
+}
+
+// Function to remove the keys that are back to a full budget
+func (ml *MemoryLimiter) cleanup() {
+	now := time.Now()
+	for i := range ml.shards {
+		s := &ml.shards[i]
+		s.mu.Lock()
+		for key, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,183 @@
+package security
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Rate applied to a group of routes, the name separates the budgets of the groups
+type Policy struct {
+	Name string
+	Rate Rate
+}
+
+// Function returning the authenticated user and role of a request, empty when anonymous
+type IdentityFunc func(r *http.Request) (userID, role string)
+
+// PolicyLimiter applies per-route policies, keyed by user ID for authenticated
+// requests and by client IP otherwise
+type PolicyLimiter struct {
+	limiter        Limiter
+	identify       IdentityFunc
+	policies       map[string]Policy
+	fallback       Policy
+	trustedProxies []*net.IPNet
+	allowedIPs     []*net.IPNet
+	exemptRoles    map[string]bool
+	refusals       map[string]http.HandlerFunc
+}
+
+// Function to create a policy limiter, the trusted proxies, allowed IPs and exempt roles
+// come from TRUSTED_PROXIES, RATE_LIMIT_ALLOW_IPS and RATE_LIMIT_EXEMPT_ROLES (default "admin")
+func NewPolicyLimiter(limiter Limiter, identify IdentityFunc, fallback Policy) *PolicyLimiter {
+	pl := &PolicyLimiter{
+		limiter:        limiter,
+		identify:       identify,
+		policies:       map[string]Policy{},
+		fallback:       fallback,
+		trustedProxies: parseNetworks(os.Getenv("TRUSTED_PROXIES")),
+		allowedIPs:     parseNetworks(os.Getenv("RATE_LIMIT_ALLOW_IPS")),
+		exemptRoles:    map[string]bool{},
+		refusals:       map[string]http.HandlerFunc{},
+	}
+	roles := os.Getenv("RATE_LIMIT_EXEMPT_ROLES")
+	if roles == "" {
+		roles = "admin"
+	}
+	for _, role := range strings.Split(roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			pl.exemptRoles[role] = true
+		}
+	}
+	return pl
+}
+
+// Function to apply a policy to route patterns of the mux
+func (pl *PolicyLimiter) Route(policy Policy, patterns ...string) {
+	for _, pattern := range patterns {
+		pl.policies[pattern] = policy
+	}
+}
+
+// Function to answer the refused requests under a path prefix with their own handler, for
+// clients that expect another format than text
+func (pl *PolicyLimiter) RefuseWith(prefix string, refuse http.HandlerFunc) {
+	pl.refusals[prefix] = refuse
+}
+
+// Function to answer a request over its rate limit
+func (pl *PolicyLimiter) refuse(w http.ResponseWriter, r *http.Request) {
+	for prefix, refuse := range pl.refusals {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			refuse(w, r)
+			return
+		}
+	}
+	http.Error(w, "Trop de requêtes. Attendez un moment.", http.StatusTooManyRequests)
+}
+
+// Function to parse a comma separated list of IPs and CIDR ranges
+func parseNetworks(list string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if strings.Contains(item, ":") {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Println("Invalid network ignored:", item)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Function to check whether an IP belongs to one of the networks
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to find the client IP, X-Forwarded-For is only read behind a trusted proxy.
+// The header is walked from the right and the first address that is not a proxy is the client.
+func (pl *PolicyLimiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !contains(pl.trustedProxies, remote) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !contains(pl.trustedProxies, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// Function to rate limit every request of a mux with the policy of its route
+func (pl *PolicyLimiter) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		policy, ok := pl.policies[pattern]
+		if !ok {
+			policy = pl.fallback
+		}
+		// A policy without limit leaves the route unlimited
+		if policy.Rate.Limit <= 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		ip := pl.ClientIP(r)
+		if parsed := net.ParseIP(ip); parsed != nil && contains(pl.allowedIPs, parsed) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		key := policy.Name + ":ip:" + ip
+		if userID, role := pl.identify(r); userID != "" {
+			if pl.exemptRoles[role] {
+				mux.ServeHTTP(w, r)
+				return
+			}
+			key = policy.Name + ":user:" + userID
+		}
+		decision, err := pl.limiter.Allow(key, policy.Rate)
+		if err != nil {
+			// A broken backend must not take the whole forum down
+			log.Println("Rate limiter error:", err)
+			mux.ServeHTTP(w, r)
+			return
+		}
+		WriteHeaders(w, policy.Rate, decision)
+		if !decision.Allowed {
+			pl.refuse(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package security
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// Limiter persisting the state of the keys in the ip_rate_limit table, so budgets
+// survive a restart and are shared by every process using the same database.
+// last_request_time holds State.Time and request_count holds State.Value.
+type SQLiteLimiter struct {
+	db        *sql.DB
+	algorithm Algorithm
+	mu        sync.Mutex
+}
+
+// Function to create a limiter on a database and start the cleanup of the expired keys
+func NewSQLiteLimiter(db *sql.DB, algorithm Algorithm) *SQLiteLimiter {
+	db.Exec(`CREATE TABLE IF NOT EXISTS ip_rate_limit (
+	ip_address TEXT PRIMARY KEY,
+	last_request_time DATETIME,
+	request_count INTEGER
+	)`)
+	db.Exec("ALTER TABLE ip_rate_limit ADD COLUMN expires_at DATETIME")
+	sl := &SQLiteLimiter{db: db, algorithm: algorithm}
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			if _, err := db.Exec("DELETE FROM ip_rate_limit WHERE expires_at < ?", time.Now().UTC()); err != nil {
+				log.Println("Error cleaning rate limits:", err)
+			}
+		}
+	}()
+	return sl
+}
+
+// Function to check and record a request in a transaction
+func (sl *SQLiteLimiter) Allow(key string, rate Rate) (Decision, error) {
+	// Requests of this process are serialized to avoid busy transactions
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	tx, err := sl.db.Begin()
+	if err != nil {
+		return Decision{}, err
+	}
+	defer tx.Rollback()
+
+	var state State
+	found := true
+	err = tx.QueryRow("SELECT last_request_time, request_count FROM ip_rate_limit WHERE ip_address = ?", key).Scan(&state.Time, &state.Value)
+	if err == sql.ErrNoRows {
+		found = false
+	} else if err != nil {
+		return Decision{}, err
+	}
+	now := time.Now().UTC()
+	state, decision := sl.algorithm(state, found, rate, now)
+	_, err = tx.Exec(`INSERT INTO ip_rate_limit (ip_address, last_request_time, request_count, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(ip_address) DO UPDATE SET last_request_time = excluded.last_request_time, request_count = excluded.request_count, expires_at = excluded.expires_at`,
+		key, state.Time.UTC(), state.Value, now.Add(decision.ResetAfter))
+	if err != nil {
+		return Decision{}, err
+	}
+	return decision, tx.Commit()
+}
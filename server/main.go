@@ -6,11 +6,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	auth "Forum/auth"
 	forum "Forum/forum"
+	"Forum/mailer"
+	"Forum/storage"
 
 	rate "Forum/security"
 	_ "github.com/xeodou/go-sqlcipher"
@@ -53,66 +56,207 @@ func main() {
 	// Initialize the authentication and database
 	auth.InitDB()
 	auth.InitOAuth()
+	// Select the blob store of the uploaded files
+	storage.Init()
+	// Give a slug to the categories created before slugs existed
+	forum.InitCategorySlugs()
+	// Execute the account deletions whose grace period is over
+	auth.StartAccountDeletionWorker(time.Hour)
+	// Remove the uploaded files that nothing references anymore
+	forum.StartBlobCollector(time.Hour)
+	// Purge the trash after the restore window and archive the inactive threads
+	forum.StartPostLifecycleWorker(time.Hour)
+	// Publish the scheduled posts when their date comes
+	forum.StartPostScheduler(time.Minute)
+	// Trust levels come from the activity and reputation, they are stored and the badges awarded hourly
+	forum.StartReputationWorker(time.Hour)
+	// Send the notification digests by email
+	mailer.Init()
+	forum.StartDigestWorker(time.Hour)
 
-	// Create a rate limiter
-	limiter := rate.NewRateLimiter(200, 60*time.Second) 
+	// Rate limit policies: every route not listed below uses the standard one,
+	// requests are counted per user when logged in and per client IP otherwise
+	standard := rate.Policy{Name: "standard", Rate: rate.Rate{Limit: 200, Period: time.Minute}}
+	limiter := rate.NewPolicyLimiter(rate.NewLimiterFromEnv(auth.DB), auth.SessionIdentity, standard)
+	// Login protection: client IP behind the trusted proxies, CAPTCHA and warning of the owner
+	auth.ClientIP = limiter.ClientIP
+	if captcha := rate.NewCaptchaFromEnv(); captcha != nil {
+		auth.LoginCaptcha = captcha
+		auth.CaptchaWidget = captcha.Config
+	}
+	auth.OnSuspiciousLogin = func(userID, ip string, failures int) {
+		forum.CreateNotification(forum.NotificationEvent{
+			UserID:     userID,
+			Action:     "security",
+			TargetType: "user",
+			TargetID:   userID,
+			Template:   "login_burst",
+			Params:     map[string]string{"failures": strconv.Itoa(failures), "ip": ip},
+		})
+	}
+	// Credentials are the target of brute force
+	limiter.Route(rate.Policy{Name: "auth", Rate: rate.Rate{Limit: 10, Period: time.Minute}},
+		"/login", "/register", "/auth/google", "/auth/github", "/auth/callback/google", "/auth/callback/github")
+	// Content creation and reports, through the site or the API
+	write := rate.Policy{Name: "write", Rate: rate.Rate{Limit: 20, Period: time.Minute, Burst: 5}}
+	limiter.Route(write, forum.APIPatterns(true)...)
+	limiter.Route(write,
+		"/post/create", "/comment/create", "/report/post", "/profile/update", "/request-moderator",
+		"/messages/send", "/conversations/create", "/report/message", "/post/tags", "/poll/vote")
+	// Autosave runs while typing
+	limiter.Route(rate.Policy{Name: "autosave", Rate: rate.Rate{Limit: 60, Period: time.Minute, Burst: 10}},
+		"/drafts/save")
+	limiter.Route(rate.Policy{Name: "export", Rate: rate.Rate{Limit: 5, Period: time.Hour, Burst: 2}},
+		"/account/export")
+	// Pages and API clients poll these endpoints, they get a large budget
+	read := rate.Policy{Name: "read", Rate: rate.Rate{Limit: 600, Period: time.Minute}}
+	limiter.Route(read, forum.APIPatterns(false)...)
+	limiter.Route(read,
+		"/posts", "/comments", "/likes", "/categories", "/notifications", "/notifications/unread-count", "/users/autocomplete", "/subscriptions", "/comments/new", "/check-session",
+		"/conversations", "/conversations/messages", "/messages/unread-count", "/tags", "/tags/popular", "/leaderboard", "/badges", "/bookmarks", "/bookmarks/collections",
+		"GET /feeds/posts", "GET /feeds/category/{id}", "GET /feeds/tag/{name}", "GET /feeds/user/{username}", "GET /feeds/post/{id}/comments")
+	// Static files are not limited
+	limiter.Route(rate.Policy{Name: "static"}, "/uploads/", "/web/")
+	// The API answers its refusals in its error envelope
+	limiter.RefuseWith("/api/", forum.RefuseAPIRequest)
 	// Create a new HTTP multiplexer
 	mux := http.NewServeMux()
 	
 	// Define routes and associate them with aithmidlleware and with rate limiting
-	mux.Handle("/", limiter.Limit(http.HandlerFunc(auth.ServeHTML)))   
-	mux.Handle("/register", limiter.Limit(http.HandlerFunc(auth.RegisterUser)))
-	mux.Handle("/login", limiter.Limit(http.HandlerFunc(auth.LoginUser)))
-	mux.Handle("/logout", limiter.Limit(http.HandlerFunc(auth.LogoutUser)))
-	mux.Handle("/edit_user", limiter.Limit(http.HandlerFunc(auth.AuthMiddleware(auth.EditUser))))
-	mux.Handle("/check-session", limiter.Limit(http.HandlerFunc(auth.CheckSession)))
-	mux.Handle("/auth/google", limiter.Limit(http.HandlerFunc((auth.AuthGoogle))))
-	mux.Handle("/auth/github", limiter.Limit(http.HandlerFunc((auth.AuthGithub))))
-	mux.Handle("/auth/callback/google", limiter.Limit(http.HandlerFunc((auth.GoogleCallback))))
-	mux.Handle("/auth/callback/github", limiter.Limit(http.HandlerFunc((auth.GithubCallback))))
-	mux.Handle("/admin", limiter.Limit(auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.ServeAdmin))))
-	mux.Handle("/request-moderator", limiter.Limit(http.HandlerFunc(forum.RequestModerator)))
-	mux.Handle("/moderator-requests", limiter.Limit(http.HandlerFunc(forum.GetModeratorRequests)))
-	mux.Handle("/approve-moderator", limiter.Limit(http.HandlerFunc(forum.ApproveModerator)))
-	mux.Handle("/reject-moderator", limiter.Limit(http.HandlerFunc(forum.RejectModerator)))
-	mux.Handle("/update-role", limiter.Limit(http.HandlerFunc(forum.UpdateUserRole)))
-	mux.Handle("/remove-moderator-role", limiter.Limit(http.HandlerFunc(forum.RemoveModeratorRole)))
-	mux.Handle("/get-moderators", limiter.Limit(http.HandlerFunc(forum.GetModerators)))
-	mux.Handle("/post/delete_admin", limiter.Limit(http.HandlerFunc(forum.DeletePostByAdmin)))
-	mux.Handle("/comments/delete_admin", limiter.Limit(http.HandlerFunc(forum.DeleteCommentAdmin)))
-	mux.Handle("/report/post", limiter.Limit(http.HandlerFunc(forum.ReportPost)))
-	mux.Handle("/report", limiter.Limit(http.HandlerFunc(forum.GetReports)))
-	mux.Handle("/report/resolve", limiter.Limit(http.HandlerFunc(forum.ResolveReport)))
-	mux.Handle("/report/reject", limiter.Limit(http.HandlerFunc(forum.RejectReport)))
-	mux.Handle("/moderator", limiter.Limit(http.HandlerFunc(auth.AuthMiddleware(auth.RoleMiddleware("moderator",forum.ServeModerator)))))
-	mux.Handle("/forum", limiter.Limit(http.HandlerFunc(auth.AuthMiddleware(forum.ServeForum))))
-	mux.Handle("/notifications", limiter.Limit(http.HandlerFunc(forum.GetNotifications)))
-	mux.Handle("/notifications/mark-seen", limiter.Limit(http.HandlerFunc(forum.MarkNotificationsAsSeen)))
-	mux.Handle("/notifications/delete", limiter.Limit(http.HandlerFunc(forum.DeleteNotification)))
-	mux.Handle("/activity", limiter.Limit(http.HandlerFunc(auth.AuthMiddleware(auth.ServeActivity))))
-	mux.Handle("/user/activity", limiter.Limit(http.HandlerFunc(auth.AuthMiddleware(auth.GetUserActivity))))
-	mux.Handle("/comments/new", limiter.Limit(http.HandlerFunc(forum.GetNewComments)))
-	mux.Handle("/forum_invite", limiter.Limit(http.HandlerFunc(forum.ServeForumInvite)))
-	mux.Handle("/post/create", limiter.Limit(http.HandlerFunc(forum.CreatePost)))
-	mux.Handle("/posts", limiter.Limit(http.HandlerFunc(forum.GetAllPosts)))
-	mux.Handle("/categories", limiter.Limit(http.HandlerFunc(forum.GetCategories)))
-	mux.Handle("/categories/create", limiter.Limit(http.HandlerFunc(forum.CreateCategory)))
-	mux.Handle("/categories/delete", limiter.Limit(http.HandlerFunc(forum.DeleteCategory)))
-	mux.Handle("/comments", limiter.Limit(http.HandlerFunc(forum.GetComments)))
-	mux.Handle("/like/comment", limiter.Limit(http.HandlerFunc(forum.LikeComment)))
-	mux.Handle("/comment/create", limiter.Limit(http.HandlerFunc(forum.CreateComment)))
-	mux.Handle("/post/delete", limiter.Limit(http.HandlerFunc(forum.DeletePost)))
-	mux.Handle("/comment/delete", limiter.Limit(http.HandlerFunc(forum.DeleteComment)))
-	mux.Handle("/like/post", limiter.Limit(http.HandlerFunc(forum.Like_Post)))
-	mux.Handle("/likes", limiter.Limit(http.HandlerFunc(forum.GetLikesAndDislike)))
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	mux.Handle("/", http.HandlerFunc(auth.ServeHTML))
+	mux.Handle("/register", http.HandlerFunc(auth.RegisterUser))
+	mux.Handle("/login", http.HandlerFunc(auth.LoginUser))
+	mux.Handle("/login/captcha", http.HandlerFunc(auth.CaptchaSettings))
+	mux.Handle("/logout", http.HandlerFunc(auth.LogoutUser))
+	mux.Handle("/edit_user", http.HandlerFunc(auth.AuthMiddleware(auth.EditUser)))
+	mux.Handle("/check-session", http.HandlerFunc(auth.CheckSession))
+	mux.Handle("/auth/google", http.HandlerFunc((auth.AuthGoogle)))
+	mux.Handle("/auth/github", http.HandlerFunc((auth.AuthGithub)))
+	mux.Handle("/auth/callback/google", http.HandlerFunc((auth.GoogleCallback)))
+	mux.Handle("/auth/callback/github", http.HandlerFunc((auth.GithubCallback)))
+	mux.Handle("/admin", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.ServeAdmin)))
+	mux.Handle("/request-moderator", http.HandlerFunc(forum.RequestModerator))
+	mux.Handle("/moderator-requests", http.HandlerFunc(forum.GetModeratorRequests))
+	mux.Handle("/approve-moderator", http.HandlerFunc(forum.ApproveModerator))
+	mux.Handle("/reject-moderator", http.HandlerFunc(forum.RejectModerator))
+	mux.Handle("/update-role", http.HandlerFunc(forum.UpdateUserRole))
+	mux.Handle("/remove-moderator-role", http.HandlerFunc(forum.RemoveModeratorRole))
+	mux.Handle("/get-moderators", http.HandlerFunc(forum.GetModerators))
+	mux.Handle("/post/delete_admin", http.HandlerFunc(forum.DeletePostByAdmin))
+	mux.Handle("/comments/delete_admin", http.HandlerFunc(forum.DeleteCommentAdmin))
+	mux.Handle("/report/post", http.HandlerFunc(forum.ReportPost))
+	mux.Handle("/report", http.HandlerFunc(forum.GetReports))
+	mux.Handle("/report/resolve", http.HandlerFunc(forum.ResolveReport))
+	mux.Handle("/report/reject", http.HandlerFunc(forum.RejectReport))
+	mux.Handle("/moderator", http.HandlerFunc(auth.AuthMiddleware(auth.RoleMiddleware("moderator",forum.ServeModerator))))
+	mux.Handle("/forum", http.HandlerFunc(auth.AuthMiddleware(forum.ServeForum)))
+	mux.Handle("/notifications", http.HandlerFunc(forum.GetNotifications))
+	mux.Handle("/notifications/mark-seen", http.HandlerFunc(forum.MarkNotificationsAsSeen))
+	mux.Handle("/notifications/delete", http.HandlerFunc(forum.DeleteNotification))
+	mux.Handle("/notifications/mark-read", http.HandlerFunc(auth.AuthMiddleware(forum.MarkNotificationsRead)))
+	mux.Handle("/notifications/delete-read", http.HandlerFunc(auth.AuthMiddleware(forum.DeleteReadNotifications)))
+	mux.Handle("/notifications/unread-count", http.HandlerFunc(auth.AuthMiddleware(forum.GetUnreadCount)))
+	mux.Handle("/notifications/preferences", http.HandlerFunc(auth.AuthMiddleware(forum.GetNotificationPreferences)))
+	mux.Handle("/notifications/preferences/update", http.HandlerFunc(auth.AuthMiddleware(forum.UpdateNotificationPreferences)))
+	mux.Handle("/activity", http.HandlerFunc(auth.AuthMiddleware(auth.ServeActivity)))
+	mux.Handle("/user/activity", http.HandlerFunc(auth.AuthMiddleware(auth.GetUserActivity)))
+	mux.Handle("/subscriptions", http.HandlerFunc(auth.AuthMiddleware(forum.GetSubscriptions)))
+	mux.Handle("/subscriptions/add", http.HandlerFunc(auth.AuthMiddleware(forum.AddSubscription)))
+	mux.Handle("/subscriptions/remove", http.HandlerFunc(auth.AuthMiddleware(forum.RemoveSubscription)))
+	mux.Handle("/messages", http.HandlerFunc(auth.AuthMiddleware(forum.ServeMessages)))
+	mux.Handle("/conversations", http.HandlerFunc(auth.AuthMiddleware(forum.GetConversations)))
+	mux.Handle("/conversations/create", auth.AuthMiddleware(auth.TrustMiddleware("start_conversation", forum.CreateConversation)))
+	mux.Handle("/conversations/members/add", http.HandlerFunc(auth.AuthMiddleware(forum.AddConversationMembers)))
+	mux.Handle("/conversations/leave", http.HandlerFunc(auth.AuthMiddleware(forum.LeaveConversation)))
+	mux.Handle("/conversations/read", http.HandlerFunc(auth.AuthMiddleware(forum.MarkConversationRead)))
+	mux.Handle("/conversations/messages", http.HandlerFunc(auth.AuthMiddleware(forum.GetMessages)))
+	mux.Handle("/messages/send", http.HandlerFunc(auth.AuthMiddleware(forum.SendMessage)))
+	mux.Handle("/messages/unread-count", http.HandlerFunc(auth.AuthMiddleware(forum.GetUnreadMessagesCount)))
+	mux.Handle("/report/message", http.HandlerFunc(auth.AuthMiddleware(forum.ReportMessage)))
+	mux.Handle("/report/messages", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.GetMessageReports)))
+	mux.Handle("/report/message/resolve", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.ResolveMessageReport)))
+	mux.Handle("/report/message/reject", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.RejectMessageReport)))
+	mux.Handle("/blocks", http.HandlerFunc(auth.AuthMiddleware(forum.GetBlockedUsers)))
+	mux.Handle("/blocks/add", http.HandlerFunc(auth.AuthMiddleware(forum.BlockUser)))
+	mux.Handle("/blocks/remove", http.HandlerFunc(auth.AuthMiddleware(forum.UnblockUser)))
+	mux.Handle("/users/autocomplete", http.HandlerFunc(auth.AuthMiddleware(forum.AutocompleteUsers)))
+	// The routes under /user/ take precedence, their names are reserved at registration
+	mux.Handle("/user/{username}", http.HandlerFunc(forum.ServeProfile))
+	mux.Handle("/user/{username}/profile", http.HandlerFunc(forum.GetProfile))
+	mux.Handle("/profile/update", http.HandlerFunc(auth.AuthMiddleware(forum.UpdateProfile)))
+	mux.Handle("/account/export", http.HandlerFunc(auth.AuthMiddleware(auth.ExportAccount)))
+	mux.Handle("/account/delete", http.HandlerFunc(auth.AuthMiddleware(auth.RequestAccountDeletion)))
+	mux.Handle("/account/delete/cancel", http.HandlerFunc(auth.AuthMiddleware(auth.CancelAccountDeletion)))
+	mux.Handle("/comments/new", http.HandlerFunc(forum.GetNewComments))
+	mux.Handle("/forum_invite", http.HandlerFunc(forum.ServeForumInvite))
+	mux.Handle("/post/create", http.HandlerFunc(forum.CreatePost))
+	mux.Handle("/posts", http.HandlerFunc(forum.GetAllPosts))
+	mux.Handle("/posts/scheduled", http.HandlerFunc(auth.AuthMiddleware(forum.GetScheduledPosts)))
+	mux.Handle("/posts/read", http.HandlerFunc(auth.AuthMiddleware(forum.MarkPostRead)))
+	mux.Handle("/posts/read-all", http.HandlerFunc(auth.AuthMiddleware(forum.MarkAllRead)))
+	mux.Handle("GET /feeds/posts", http.HandlerFunc(forum.GetPostsFeed))
+	mux.Handle("GET /feeds/category/{id}", http.HandlerFunc(forum.GetCategoryFeed))
+	mux.Handle("GET /feeds/tag/{name}", http.HandlerFunc(forum.GetTagFeed))
+	mux.Handle("GET /feeds/user/{username}", http.HandlerFunc(forum.GetUserFeed))
+	mux.Handle("GET /feeds/post/{id}/comments", http.HandlerFunc(forum.GetCommentsFeed))
+	forum.RegisterAPI(mux)
+	mux.Handle("/drafts", http.HandlerFunc(auth.AuthMiddleware(forum.GetDrafts)))
+	mux.Handle("/drafts/save", http.HandlerFunc(auth.AuthMiddleware(forum.SaveDraft)))
+	mux.Handle("/drafts/delete", http.HandlerFunc(auth.AuthMiddleware(forum.DeleteDraft)))
+	mux.Handle("/bookmarks", http.HandlerFunc(auth.AuthMiddleware(forum.GetBookmarks)))
+	mux.Handle("/bookmarks/save", http.HandlerFunc(auth.AuthMiddleware(forum.SaveBookmark)))
+	mux.Handle("/bookmarks/remove", http.HandlerFunc(auth.AuthMiddleware(forum.RemoveBookmark)))
+	mux.Handle("/bookmarks/collections", http.HandlerFunc(auth.AuthMiddleware(forum.GetBookmarkCollections)))
+	mux.Handle("/bookmarks/collections/create", http.HandlerFunc(auth.AuthMiddleware(forum.CreateBookmarkCollection)))
+	mux.Handle("/bookmarks/collections/rename", http.HandlerFunc(auth.AuthMiddleware(forum.RenameBookmarkCollection)))
+	mux.Handle("/bookmarks/collections/delete", http.HandlerFunc(auth.AuthMiddleware(forum.DeleteBookmarkCollection)))
+	mux.Handle("/categories", http.HandlerFunc(forum.GetCategories))
+	mux.Handle("/categories/create", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.CreateCategory)))
+	mux.Handle("/categories/update", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.UpdateCategory)))
+	mux.Handle("/categories/merge", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.MergeCategory)))
+	mux.Handle("/categories/delete", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.DeleteCategory)))
+	mux.Handle("/tags", http.HandlerFunc(forum.GetTags))
+	mux.Handle("/tags/popular", http.HandlerFunc(forum.GetPopularTags))
+	mux.Handle("/post/tags", http.HandlerFunc(forum.UpdatePostTags))
+	mux.Handle("/poll/vote", http.HandlerFunc(auth.AuthMiddleware(forum.VotePoll)))
+	mux.Handle("/tags/merge", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.MergeTags)))
+	mux.Handle("/tags/synonyms", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.GetTagSynonyms)))
+	mux.Handle("/tags/synonyms/add", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.AddTagSynonym)))
+	mux.Handle("/tags/synonyms/remove", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.RemoveTagSynonym)))
+	mux.Handle("/comments", http.HandlerFunc(forum.GetComments))
+	mux.Handle("/like/comment", http.HandlerFunc(forum.LikeComment))
+	mux.Handle("/comment/create", http.HandlerFunc(forum.CreateComment))
+	mux.Handle("/post/delete", http.HandlerFunc(forum.DeletePost))
+	mux.Handle("/post/pin", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.PinPost)))
+	mux.Handle("/post/lock", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.LockPost)))
+	mux.Handle("/post/archive", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.ArchivePost)))
+	mux.Handle("/trash", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.GetTrash)))
+	mux.Handle("/post/restore", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.RestorePost)))
+	mux.Handle("/post/purge", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.PurgePost)))
+	mux.Handle("/posts/pending", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.GetPendingPosts)))
+	mux.Handle("/post/approve", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.ApprovePost)))
+	mux.Handle("/post/reject", auth.AuthMiddleware(auth.RoleMiddleware("moderator", forum.RejectPost)))
+	mux.Handle("/leaderboard", http.HandlerFunc(forum.GetLeaderboard))
+	mux.Handle("/badges", http.HandlerFunc(forum.GetBadges))
+	mux.Handle("/badges/save", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.SaveBadge)))
+	mux.Handle("/badges/delete", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.DeleteBadge)))
+	mux.Handle("/comment/delete", http.HandlerFunc(forum.DeleteComment))
+	mux.Handle("/comment/accept", http.HandlerFunc(auth.AuthMiddleware(forum.AcceptAnswer)))
+	mux.Handle("/like/post", http.HandlerFunc(forum.Like_Post))
+	mux.Handle("/likes", http.HandlerFunc(forum.GetLikesAndDislike))
+	mux.Handle("/attachments/quota", http.HandlerFunc(auth.AuthMiddleware(forum.GetStorageQuota)))
+	mux.Handle("/attachments/url", http.HandlerFunc(forum.GetAttachmentURL))
+	mux.Handle("/attachment-types", http.HandlerFunc(forum.GetAttachmentTypes))
+	mux.Handle("/attachment-types/save", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.SaveAttachmentType)))
+	mux.Handle("/attachment-types/delete", auth.AuthMiddleware(auth.RoleMiddleware("admin", forum.DeleteAttachmentType)))
+	mux.Handle("/uploads/", http.HandlerFunc(forum.ServeUpload))
 	mux.Handle("/web/", http.StripPrefix("/web/", http.FileServer(http.Dir("web"))))
 
 	// Print a message indicating the server is running (debug)
 	fmt.Println("✅ Serveur lancé sur https://localhost:8080") // Commande Docker :  sudo docker compose up --build
 
 	// Start the server on port 8080 with the provided certificates for HTTPS
-	err := http.ListenAndServeTLS(":8080", "localhost+2.pem", "localhost+2-key.pem", mux)
+	err := http.ListenAndServeTLS(":8080", "localhost+2.pem", "localhost+2-key.pem", limiter.Middleware(mux))
 	if err != nil {
 		log.Fatal("❌ Erreur HTTPS :", err)
 	}
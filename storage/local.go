@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Blob store writing the files in a local directory
+type LocalStore struct {
+	dir       string
+	urlPrefix string
+}
+
+// Function to create a store in dir, whose files are served under urlPrefix
+func NewLocalStore(dir, urlPrefix string) *LocalStore {
+	return &LocalStore{dir: dir, urlPrefix: urlPrefix}
+}
+
+// Function to write a blob, through a temporary file so readers never see a partial one
+func (s *LocalStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, key))
+}
+
+// Function to open a blob
+func (s *LocalStore) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	info, err := s.Stat(key)
+	if err != nil {
+		return nil, info, err
+	}
+	file, err := os.Open(filepath.Join(s.dir, key))
+	if err != nil {
+		return nil, info, ErrNotFound
+	}
+	return file, info, nil
+}
+
+// Function to retrieves the metadata of a blob
+func (s *LocalStore) Stat(key string) (BlobInfo, error) {
+	if !ValidKey(key) {
+		return BlobInfo{}, ErrNotFound
+	}
+	fi, err := os.Stat(filepath.Join(s.dir, key))
+	if os.IsNotExist(err) || (err == nil && fi.IsDir()) {
+		return BlobInfo{}, ErrNotFound
+	} else if err != nil {
+		return BlobInfo{}, err
+	}
+	return s.info(fi), nil
+}
+
+// Function to remove a blob
+func (s *LocalStore) Delete(key string) error {
+	if !ValidKey(key) {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.dir, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Function to list the blobs of the directory
+func (s *LocalStore) List(fn func(BlobInfo) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !ValidKey(entry.Name()) {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if err := fn(s.info(fi)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to build a URL of the forum signed with the upload signing key
+func (s *LocalStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	return s.urlPrefix + key + "?" + SignQuery(key, ttl), nil
+}
+
+// Function to convert file information to blob metadata
+func (s *LocalStore) info(fi os.FileInfo) BlobInfo {
+	return BlobInfo{
+		Key:         fi.Name(),
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(fi.Name())),
+		ModTime:     fi.ModTime(),
+	}
+}
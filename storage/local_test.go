@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to use a local store in a temporary directory for the test
+func useLocalStore(t *testing.T) string {
+	dir := t.TempDir()
+	previous := Blobs
+	Blobs = NewLocalStore(dir, "/uploads/")
+	signingKey = []byte("test signing key")
+	t.Cleanup(func() { Blobs = previous })
+	return dir
+}
+
+// Identical contents share one blob named after their SHA-256
+func TestSaveContentAddressing(t *testing.T) {
+	dir := useLocalStore(t)
+	content := []byte("même contenu")
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:]) + ".txt"
+
+	first, size, err := Save(bytes.NewReader(content), ".txt", "text/plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != want || size != int64(len(content)) {
+		t.Errorf("Save = %s, %d, want %s, %d", first, size, want, len(content))
+	}
+	second, _, err := Save(bytes.NewReader(content), ".txt", "text/plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Errorf("the same content got two keys: %s and %s", first, second)
+	}
+	other, _, err := Save(strings.NewReader("autre contenu"), ".txt", "text/plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("two contents share a key")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("%d files stored, want 2", len(entries))
+	}
+}
+
+// A private blob never shares its key with a public one of the same content
+func TestSavePrivateNamespace(t *testing.T) {
+	useLocalStore(t)
+	content := []byte("contenu partagé")
+	public, _, err := Save(bytes.NewReader(content), ".pdf", "application/pdf", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, _, err := Save(bytes.NewReader(content), ".pdf", "application/pdf", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private == public || !IsPrivateKey(private) || IsPrivateKey(public) {
+		t.Errorf("public key %s, private key %s", public, private)
+	}
+	again, _, _ := Save(bytes.NewReader(content), ".pdf", "application/pdf", true)
+	if again != private {
+		t.Errorf("private blobs are not deduplicated: %s and %s", private, again)
+	}
+}
+
+// Blobs go through Put, Get, List and Delete, invalid keys are refused
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "/uploads/")
+	content := []byte("contenu")
+	if err := store.Put("abc.txt", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	blob, info, err := store.Get("abc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(blob)
+	blob.Close()
+	if !bytes.Equal(data, content) || info.Size != int64(len(content)) {
+		t.Errorf("get = %q, %+v", data, info)
+	}
+	var keys []string
+	store.List(func(info BlobInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if len(keys) != 1 || keys[0] != "abc.txt" {
+		t.Errorf("list = %v", keys)
+	}
+	if err := store.Delete("abc.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat("abc.txt"); err != ErrNotFound {
+		t.Errorf("stat after delete = %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"../abc.txt", "a/b.txt", ".hidden", ""} {
+		if err := store.Put(key, bytes.NewReader(content), int64(len(content)), ""); err != ErrInvalidKey {
+			t.Errorf("put %q = %v, want ErrInvalidKey", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(store.dir), "abc.txt")); err == nil {
+		t.Error("a key escaped the directory")
+	}
+}
+
+// The signed URLs of the local store are checked by VerifySignature until they expire
+func TestLocalSignedURL(t *testing.T) {
+	useLocalStore(t)
+	signed, err := Blobs.SignedURL("private-abc.pdf", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if u.Path != "/uploads/private-abc.pdf" || !VerifySignature("private-abc.pdf", query.Get("expires"), query.Get("signature")) {
+		t.Errorf("signed URL %s is not valid", signed)
+	}
+	if VerifySignature("other.pdf", query.Get("expires"), query.Get("signature")) {
+		t.Error("the signature is valid for another key")
+	}
+	expired := SignQuery("private-abc.pdf", -time.Minute)
+	values, _ := url.ParseQuery(expired)
+	if VerifySignature("private-abc.pdf", values.Get("expires"), values.Get("signature")) {
+		t.Error("an expired signature is valid")
+	}
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Error returned when a key is not a plain file name
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Metadata of a stored blob
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Place where the uploaded files are kept, blobs are identified by a key
+// made of the SHA-256 of their content and their extension
+type BlobStore interface {
+	// Put stores the content of a blob under its key
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens a blob, the reader is an io.ReadSeeker when the backend allows it
+	Get(key string) (io.ReadCloser, BlobInfo, error)
+	// Stat returns the metadata of a blob or ErrNotFound
+	Stat(key string) (BlobInfo, error)
+	// Delete removes a blob, deleting a missing blob is not an error
+	Delete(key string) error
+	// List calls fn for every stored blob
+	List(fn func(BlobInfo) error) error
+	// SignedURL returns a URL giving access to a blob until it expires
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// Age under which an unreferenced blob is kept, it may belong to an upload in progress
+const OrphanGrace = 2 * time.Hour
+
+// Store used by the forum, set by Init
+var Blobs BlobStore
+
+// Secret of the signed URLs served by the forum itself
+var signingKey []byte
+
+// Function to create the blob store selected by STORAGE_BACKEND ("local" or "s3")
+func Init() {
+	signingKey = []byte(os.Getenv("UPLOAD_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		// Signed URLs will not survive a restart
+		signingKey = make([]byte, 32)
+		rand.Read(signingKey)
+		log.Println("UPLOAD_SIGNING_KEY is not set, using a random key")
+	}
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Prefix:    os.Getenv("S3_PREFIX"),
+			PathStyle: os.Getenv("S3_VIRTUAL_HOST") != "true",
+		})
+		if err != nil {
+			log.Fatal("Error configuring the S3 storage: ", err)
+		}
+		Blobs = store
+	default:
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		Blobs = NewLocalStore(dir, "/uploads/")
+	}
+}
+
+// Function to return the lifetime of the signed URLs, configurable with SIGNED_URL_TTL_MINUTES
+func SignedURLTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("SIGNED_URL_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Prefix of the upload paths recorded in the database, which are also their URLs
+const pathPrefix = "uploads/"
+
+// Function to convert a blob key to the path recorded in the database
+func PathFromKey(key string) string {
+	return pathPrefix + key
+}
+
+// Function to convert a recorded upload path back to its blob key
+func KeyFromPath(path string) string {
+	return strings.TrimPrefix(path, pathPrefix)
+}
+
+// Prefix of the keys of private blobs. They are deduplicated among themselves only, so that
+// a private file never shares its blob, and its URL, with a public one.
+const privatePrefix = "private-"
+
+// Function to check whether a key belongs to a private blob
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(key, privatePrefix)
+}
+
+// Function to check that a key is a plain file name
+func ValidKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "/\\") && !strings.HasPrefix(key, ".")
+}
+
+// Function to store content under its SHA-256, identical uploads of the same visibility
+// share the same blob
+func Save(r io.Reader, extension, contentType string, private bool) (string, int64, error) {
+	// Spool the content to know its hash before choosing the key
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return "", 0, err
+	}
+	key := hex.EncodeToString(hash.Sum(nil)) + extension
+	if private {
+		key = privatePrefix + key
+	}
+	// An old blob may be unreferenced and about to be collected, writing it again renews it
+	if info, err := Blobs.Stat(key); err == nil && time.Since(info.ModTime) < OrphanGrace/2 {
+		return key, size, nil
+	} else if err != nil && err != ErrNotFound {
+		return "", 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	if err := Blobs.Put(key, tmp, size, contentType); err != nil {
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// Function to compute the signature of a key valid until expires
+func signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, signingKey)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function to build the query string of a URL signed by the forum
+func SignQuery(key string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+	return fmt.Sprintf("expires=%d&signature=%s", expires, signature(key, expires))
+}
+
+// Function to check a signature produced by SignQuery
+func VerifySignature(key, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signature(key, exp)))
+}
@@ -0,0 +1,51 @@
+// Load the pending deletion once the page is ready
+document.addEventListener("DOMContentLoaded", function() {
+    fetchDeletionStatus();
+});
+
+// Function to display the pending account deletion, if any
+function fetchDeletionStatus() {
+    fetch("/account/delete")
+        .then(response => response.json())
+        .then(data => {
+            let status = document.getElementById("account-deletion-status");
+            let cancelButton = document.getElementById("cancel-deletion");
+            if (data.pending) {
+                status.innerText = `Suppression programmée le ${new Date(data.scheduled_at).toLocaleString()}`;
+                cancelButton.style.display = "inline-block";
+            } else {
+                status.innerText = "";
+                cancelButton.style.display = "none";
+            }
+        })
+        .catch(error => console.error("Erreur lors de la récupération de la suppression :", error));
+}
+
+// Function to schedule the deletion of the account
+function requestAccountDeletion(event) {
+    event.preventDefault();
+    if (!confirm("Votre compte sera supprimé à la fin du délai de grâce. Continuer ?")) {
+        return;
+    }
+    const mode = document.getElementById("deletion-mode").value;
+    const password = document.getElementById("deletion-password").value;
+    fetch("/account/delete", {
+        method: "POST",
+        headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        body: `mode=${encodeURIComponent(mode)}&password=${encodeURIComponent(password)}`
+    })
+    .then(async response => {
+        if (!response.ok) {
+            alert(await response.text());
+        }
+        fetchDeletionStatus();
+    })
+    .catch(error => console.error("Erreur lors de la demande de suppression :", error));
+}
+
+// Function to cancel the scheduled deletion
+function cancelAccountDeletion() {
+    fetch("/account/delete/cancel", { method: "POST" })
+        .then(() => fetchDeletionStatus())
+        .catch(error => console.error("Erreur lors de l'annulation :", error));
+}
@@ -1,3 +1,10 @@
+// Function to escape a text before putting it in HTML
+function escapeHtml(text) {
+    const div = document.createElement("div");
+    div.textContent = text;
+    return div.innerHTML;
+}
+
 document.addEventListener("DOMContentLoaded", function () {
     checkSessionAndRedirectToAdmin();
     const postsContainer = document.getElementById("posts");
@@ -141,10 +148,11 @@ document.addEventListener("DOMContentLoaded", function () {
                 headers: { "Content-Type": "application/x-www-form-urlencoded" },
                 body: `id=${postID}`
             });
-            // reload posts after suppresion
+            // reload posts after suppresion, the post goes to the trash
             if (response.ok) {
-                alert("Post supprimé !");
+                alert("Post placé dans la corbeille !");
                 fetchPosts();  
+                fetchTrash();
             } 
         } catch (error) {
             console.error("Erreur lors de la suppression du post:", error);
@@ -304,10 +312,239 @@ function removeReportFromDisplay(reportID) {
 fetchReports();
 });
 
+// Function to fetch and display the reported private messages, only the reported message is shown
+async function fetchMessageReports() {
+    const container = document.getElementById("message-reports-list");
+    try {
+        const response = await fetch("/report/messages");
+        if (!response.ok) throw new Error("Erreur lors de la récupération des messages signalés");
+        const reports = await response.json();
+        container.innerHTML = reports.length === 0 ? "<p>Aucun message signalé.</p>" : "";
+        reports.forEach(report => {
+            const reportElement = document.createElement("div");
+            reportElement.className = "report";
+            reportElement.innerHTML = `
+                <h3>Message de <span class="sender"></span></h3>
+                <p class="content"></p>
+                <p>Raison : <span class="reason"></span></p>
+                <div class="report-buttons">
+                    <button class="resolve-btn">Supprimer le message</button>
+                    <button class="reject-btn">Rejeter</button>
+                </div>`;
+            reportElement.querySelector(".sender").textContent = report.sender;
+            reportElement.querySelector(".content").textContent = report.content;
+            reportElement.querySelector(".reason").textContent = report.reason;
+            reportElement.querySelector(".resolve-btn").onclick = () => handleMessageReport(report.id, "resolve");
+            reportElement.querySelector(".reject-btn").onclick = () => handleMessageReport(report.id, "reject");
+            container.appendChild(reportElement);
+        });
+    } catch (error) {
+        console.error("Erreur:", error);
+        container.innerHTML = "<p>Impossible de charger les messages signalés.</p>";
+    }
+}
+
+// Function to resolve or reject the report of a message
+async function handleMessageReport(reportID, action) {
+    try {
+        const response = await fetch(`/report/message/${action}`, {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: `id=${reportID}`
+        });
+        if (!response.ok) {
+            alert("Erreur lors du traitement du signalement !");
+        }
+        fetchMessageReports();
+    } catch (error) {
+        console.error("Erreur lors du traitement du signalement:", error);
+        alert("Une erreur s'est produite.");
+    }
+}
+
+document.addEventListener("DOMContentLoaded", fetchMessageReports);
+
+// Function to fetch and display the deleted posts, they can be restored or purged for good
+async function fetchTrash() {
+    const container = document.getElementById("trash-list");
+    try {
+        const response = await fetch("/trash");
+        if (!response.ok) throw new Error("Erreur lors de la récupération de la corbeille");
+        const posts = await response.json();
+        container.innerHTML = posts.length === 0 ? "<p>La corbeille est vide.</p>" : "";
+        posts.forEach(post => {
+            const postElement = document.createElement("div");
+            postElement.className = "post";
+            postElement.innerHTML = `
+                <h3>${escapeHtml(post.title)}</h3>
+                <p>${escapeHtml(post.content)}</p>
+                <small>Par ${escapeHtml(post.username)}, supprimé le ${new Date(post.deleted_at).toLocaleString()}
+                ${post.deleted_by ? `par ${escapeHtml(post.deleted_by)}` : ""},
+                restaurable jusqu'au ${new Date(post.restore_until).toLocaleDateString()}</small>
+                <div class="post-buttons">
+                    <button class="restore-btn">Restaurer</button>
+                    <button class="delete-btn">Supprimer définitivement</button>
+                </div>`;
+            postElement.querySelector(".restore-btn").onclick = () => handleTrashedPost(post.id, "/post/restore");
+            postElement.querySelector(".delete-btn").onclick = () => {
+                if (confirm("Supprimer définitivement ce post et ses commentaires ?")) {
+                    handleTrashedPost(post.id, "/post/purge");
+                }
+            };
+            container.appendChild(postElement);
+        });
+    } catch (error) {
+        console.error("Erreur:", error);
+        container.innerHTML = "<p>Impossible de charger la corbeille.</p>";
+    }
+}
+
+// Function to restore or purge a post of the trash
+async function handleTrashedPost(postID, route) {
+    const response = await fetch(route, {
+        method: "POST",
+        headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        body: `post_id=${postID}`
+    });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+    }
+    fetchTrash();
+}
+
+document.addEventListener("DOMContentLoaded", fetchTrash);
+
+// Function to fetch and display the posts of the new users waiting for a review
+async function fetchPending() {
+    const container = document.getElementById("pending-list");
+    try {
+        const response = await fetch("/posts/pending");
+        if (!response.ok) throw new Error("Erreur lors de la récupération des posts en attente");
+        const posts = await response.json();
+        container.innerHTML = posts.length === 0 ? "<p>Aucun post en attente.</p>" : "";
+        posts.forEach(post => {
+            const postElement = document.createElement("div");
+            postElement.className = "post";
+            postElement.innerHTML = `
+                <h3>${escapeHtml(post.title)}</h3>
+                <p>${escapeHtml(post.content)}</p>
+                <small>Par ${escapeHtml(post.username)}, soumis le ${new Date(post.held_at).toLocaleString()}</small>
+                <div class="post-buttons">
+                    <button class="approve-btn">Approuver</button>
+                    <button class="delete-btn">Refuser</button>
+                </div>`;
+            postElement.querySelector(".approve-btn").onclick = () => reviewPost(post.id, "/post/approve", "");
+            postElement.querySelector(".delete-btn").onclick = () => {
+                const reason = prompt("Raison du refus (optionnelle) :");
+                if (reason !== null) {
+                    reviewPost(post.id, "/post/reject", reason);
+                }
+            };
+            container.appendChild(postElement);
+        });
+    } catch (error) {
+        console.error("Erreur:", error);
+        container.innerHTML = "<p>Impossible de charger les posts en attente.</p>";
+    }
+}
+
+// Function to approve or reject a pending post
+async function reviewPost(postID, route, reason) {
+    const response = await fetch(route, {
+        method: "POST",
+        body: new URLSearchParams({ post_id: postID, reason: reason })
+    });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+    }
+    fetchPending();
+    fetchTrash();
+}
+
+document.addEventListener("DOMContentLoaded", fetchPending);
+
+// Labels of the badge rules
+const badgeRuleLabels = {
+    reputation: "réputation",
+    posts: "posts publiés",
+    comments: "commentaires",
+    likes_received: "likes reçus",
+    accepted_answers: "réponses acceptées",
+    trust_level: "niveau de confiance",
+    account_days: "jours d'ancienneté"
+};
+
+// Function to fetch and display the badges with the number of users holding them
+async function fetchBadges() {
+    const container = document.getElementById("badge-list");
+    try {
+        const response = await fetch("/badges");
+        if (!response.ok) throw new Error("Erreur lors de la récupération des badges");
+        const badges = await response.json();
+        container.innerHTML = badges.length === 0 ? "<p>Aucun badge.</p>" : "";
+        badges.forEach(badge => {
+            const item = document.createElement("div");
+            item.className = "category-item";
+            item.innerHTML = `
+                <span>${escapeHtml(badge.icon)} <strong>${escapeHtml(badge.name)}</strong> :
+                ${badge.threshold} ${badgeRuleLabels[badge.rule] || badge.rule}
+                (${badge.holders} membre(s)) ${escapeHtml(badge.description)}</span>
+                <button class="edit-btn">Modifier</button>
+                <button class="delete-btn">Supprimer</button>`;
+            item.querySelector(".edit-btn").onclick = () => {
+                document.getElementById("badge-id").value = badge.id;
+                document.getElementById("badge-icon").value = badge.icon;
+                document.getElementById("badge-name").value = badge.name;
+                document.getElementById("badge-description").value = badge.description;
+                document.getElementById("badge-rule").value = badge.rule;
+                document.getElementById("badge-threshold").value = badge.threshold;
+            };
+            item.querySelector(".delete-btn").onclick = () => deleteBadge(badge.id);
+            container.appendChild(item);
+        });
+    } catch (error) {
+        console.error("Erreur:", error);
+        container.innerHTML = "<p>Impossible de charger les badges.</p>";
+    }
+}
+
+// Function to create or update a badge, it is awarded at once to the users meeting its rule
+async function saveBadge() {
+    const fields = ["id", "icon", "name", "description", "rule", "threshold"];
+    const body = new URLSearchParams();
+    fields.forEach(field => body.set(field, document.getElementById(`badge-${field}`).value.trim()));
+    const response = await fetch("/badges/save", { method: "POST", body: body });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    fields.filter(field => field !== "rule").forEach(field => document.getElementById(`badge-${field}`).value = "");
+    fetchBadges();
+}
+
+// Function to delete a badge, its holders lose it
+async function deleteBadge(badgeID) {
+    if (!confirm("Supprimer ce badge ? Les membres qui l'ont le perdront.")) return;
+    const response = await fetch("/badges/delete", { method: "POST", body: new URLSearchParams({ id: badgeID }) });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+    }
+    fetchBadges();
+}
+
+document.addEventListener("DOMContentLoaded", function () {
+    document.getElementById("save-badge-btn").addEventListener("click", saveBadge);
+    fetchBadges();
+});
+
 document.addEventListener("DOMContentLoaded", function () {
     const categoryList = document.getElementById("category-list");
     const createCategoryBtn = document.getElementById("create-category-btn");
+    const cancelCategoryBtn = document.getElementById("cancel-category-btn");
     const categoryNameInput = document.getElementById("category-name");
+    const categoryParentSelect = document.getElementById("category-parent");
+    const statusLabels = { open: "Ouverte", read_only: "Lecture seule", archived: "Archivée" };
+    let categories = [];
 
     // Function to retrieves categories
     async function fetchCategories() {
@@ -315,7 +552,7 @@ document.addEventListener("DOMContentLoaded", function () {
             const response = await fetch("/categories");
             if (!response.ok) throw new Error("Erreur lors de la récupération des catégories");
 
-            const categories = await response.json();
+            categories = await response.json();
             if (!Array.isArray(categories)) throw new Error("Données invalides reçues du serveur.");
 
             displayCategories(categories);
@@ -325,33 +562,80 @@ document.addEventListener("DOMContentLoaded", function () {
         }
     }
 
+    // Function to build the options of the categories, indented under their parent
+    function categoryOptions(emptyLabel, excludedID = "") {
+        let options = `<option value="">${emptyLabel}</option>`;
+        categories.forEach(category => {
+            if (category.id !== excludedID) {
+                options += `<option value="${category.id}">${"— ".repeat(category.depth)}${escapeHtml(category.name)}</option>`;
+            }
+        });
+        return options;
+    }
+
     // Function to display categories
     function displayCategories(categories) {
         categoryList.innerHTML = "";
+        const selectedParent = categoryParentSelect.value;
+        categoryParentSelect.innerHTML = categoryOptions("Aucune");
+        categoryParentSelect.value = selectedParent;
 
         // Display the templates
         categories.forEach(category => {
             const categoryElement = document.createElement("div");
             categoryElement.className = "category";
+            categoryElement.style.marginLeft = `${category.depth * 20}px`;
+            const lastActivity = category.last_activity_at ? new Date(category.last_activity_at).toLocaleString() : "jamais";
             categoryElement.innerHTML = `
-                <p>${category.name}</p>
-                <button class="delete-category-btn" data-id="${category.id}">🗑️ Supprimer</button>
+                <p>${escapeHtml(category.icon)} <strong>${escapeHtml(category.name)}</strong> <small>/${escapeHtml(category.slug)}</small></p>
+                <p><small>${statusLabels[category.status] || category.status}${category.qa ? " · Q/R" : ""} · ${category.post_count} post(s) · dernière activité : ${lastActivity}</small></p>
+                <button class="edit-category-btn">✏️ Modifier</button>
+                <select class="category-target">${categoryOptions("Catégorie cible", category.id)}</select>
+                <button class="merge-category-btn">🔀 Fusionner</button>
+                <button class="delete-category-btn">🗑️ Supprimer</button>
             `;
 
             categoryList.appendChild(categoryElement);
 
             // Add event for the categories
-            const deleteCategoryButtons = categoryElement.querySelectorAll(".delete-category-btn");
-            deleteCategoryButtons.forEach(button => {
-                button.addEventListener("click", function() {
-                    const categoryID = this.getAttribute("data-id");
-                    deleteCategory(categoryID);
-                });
-            });
+            const target = categoryElement.querySelector(".category-target");
+            categoryElement.querySelector(".edit-category-btn").addEventListener("click", () => editCategory(category));
+            categoryElement.querySelector(".merge-category-btn").addEventListener("click", () => mergeCategory(category, target.value));
+            categoryElement.querySelector(".delete-category-btn").addEventListener("click", () => deleteCategory(category, target.value));
         });
     }
 
-    // Function to cretae the categories
+    // Function to fill the form with a category to edit it
+    function editCategory(category) {
+        document.getElementById("category-id").value = category.id;
+        categoryNameInput.value = category.name;
+        document.getElementById("category-slug").value = category.slug;
+        document.getElementById("category-icon").value = category.icon;
+        document.getElementById("category-description").value = category.description;
+        categoryParentSelect.innerHTML = categoryOptions("Aucune", category.id);
+        categoryParentSelect.value = category.parent_id;
+        document.getElementById("category-position").value = category.position;
+        document.getElementById("category-status").value = category.status;
+        document.getElementById("category-post-role").value = category.post_role;
+        document.getElementById("category-qa").checked = category.qa;
+        createCategoryBtn.textContent = "Enregistrer la catégorie";
+        cancelCategoryBtn.style.display = "inline-block";
+    }
+
+    // Function to empty the form after a creation or an edition
+    function resetCategoryForm() {
+        document.getElementById("category-id").value = "";
+        document.querySelectorAll("#create-category input, #create-category textarea").forEach(input => input.value = "");
+        document.getElementById("category-position").value = 0;
+        document.getElementById("category-status").value = "open";
+        document.getElementById("category-post-role").value = "user";
+        document.getElementById("category-qa").checked = false;
+        categoryParentSelect.innerHTML = categoryOptions("Aucune");
+        createCategoryBtn.textContent = "Créer la catégorie";
+        cancelCategoryBtn.style.display = "none";
+    }
+
+    // Function to cretae the categories, or to save the one being edited
     async function createCategory() {
     const categoryName = categoryNameInput.value.trim();
 
@@ -359,20 +643,31 @@ document.addEventListener("DOMContentLoaded", function () {
         alert("Le nom de la catégorie ne peut pas être vide");
         return;
     }
+    const categoryID = document.getElementById("category-id").value;
+    const body = new URLSearchParams({
+        name: categoryName,
+        slug: document.getElementById("category-slug").value,
+        icon: document.getElementById("category-icon").value,
+        description: document.getElementById("category-description").value,
+        parent_id: categoryParentSelect.value,
+        position: document.getElementById("category-position").value || "0",
+        status: document.getElementById("category-status").value,
+        post_role: document.getElementById("category-post-role").value,
+        qa: document.getElementById("category-qa").checked
+    });
+    if (categoryID) {
+        body.append("id", categoryID);
+    }
 
     try {
-        const response = await fetch("/categories/create", {
-            method: "POST",
-            headers: { "Content-Type": "application/x-www-form-urlencoded" },
-            body: `name=${categoryName}`
-        });
+        const response = await fetch(categoryID ? "/categories/update" : "/categories/create", { method: "POST", body });
         // Reload categorie after creation
         if (response.ok) {
-            const data = await response.json();
-            alert(`Catégorie créée avec succès ! ID de la catégorie : ${data.id}`);
+            alert(categoryID ? "Catégorie modifiée !" : "Catégorie créée avec succès !");
+            resetCategoryForm();
             fetchCategories();  
         } else {
-            alert("Erreur lors de la création de la catégorie !");
+            alert("Erreur : " + await response.text());
         }
     } catch (error) {
         console.error("Erreur lors de la création de la catégorie:", error);
@@ -380,22 +675,41 @@ document.addEventListener("DOMContentLoaded", function () {
     }
 }
 
-    // Function to delete a categorie
-    async function deleteCategory(categoryID) {
+    // Function to merge a categorie into the target one, which takes its posts and subcategories
+    async function mergeCategory(category, targetID) {
+        if (!targetID) {
+            alert("Choisissez la catégorie cible.");
+            return;
+        }
+        if (!confirm(`Fusionner « ${category.name} » dans la catégorie choisie ? Ses posts y seront déplacés.`)) return;
+
+        const response = await fetch("/categories/merge", {
+            method: "POST",
+            body: new URLSearchParams({ id: category.id, target_id: targetID })
+        });
+        alert(response.ok ? "Catégories fusionnées !" : "Erreur : " + await response.text());
+        fetchCategories();
+    }
+
+    // Function to delete a categorie, its posts are moved to the target one
+    async function deleteCategory(category, targetID) {
+        if (category.post_count > 0 && !targetID) {
+            alert("Cette catégorie contient des posts : choisissez la catégorie cible où les déplacer.");
+            return;
+        }
         if (!confirm("Voulez-vous vraiment supprimer cette catégorie ?")) return;
 
         try {
             const response = await fetch("/categories/delete", {
                 method: "POST",
-                headers: { "Content-Type": "application/x-www-form-urlencoded" },
-                body: `id=${categoryID}`
+                body: new URLSearchParams({ id: category.id, move_to: targetID })
             });
             //Reload categories after suprresion
             if (response.ok) {
                 alert("Catégorie supprimée !");
                 fetchCategories();  
             } else {
-                alert("Erreur lors de la suppression de la catégorie !");
+                alert("Erreur : " + await response.text());
             }
         } catch (error) {
             console.error("Erreur lors de la suppression de la catégorie:", error);
@@ -405,11 +719,74 @@ document.addEventListener("DOMContentLoaded", function () {
 
     // Add event for the categories
     createCategoryBtn.addEventListener("click", createCategory);
+    cancelCategoryBtn.addEventListener("click", resetCategoryForm);
 
     // Retrieves categories after DOM is loaded
     fetchCategories();
 });
 
+// Function to display the tags with their number of posts and their synonyms
+async function fetchTags() {
+    const tagList = document.getElementById("tag-list");
+    try {
+        const response = await fetch("/tags?limit=200");
+        const tags = await response.json();
+        tagList.innerHTML = tags.length === 0 ? "<p>Aucun tag.</p>" : "";
+        tags.forEach(tag => {
+            const element = document.createElement("div");
+            element.className = "tag-item";
+            const synonyms = (tag.synonyms || []).map(synonym =>
+                `<span class="synonym">${escapeHtml(synonym)} <button onclick="removeTagSynonym('${escapeHtml(synonym)}')">✖</button></span>`).join(" ");
+            element.innerHTML = `<strong>#${escapeHtml(tag.name)}</strong> (${tag.post_count || 0} post(s)) ${synonyms}`;
+            tagList.appendChild(element);
+        });
+    } catch (error) {
+        console.error("Erreur lors du chargement des tags :", error);
+        tagList.innerHTML = "<p>Impossible de charger les tags.</p>";
+    }
+}
+
+// Function to send a tag action of the admin and reload the tags
+async function sendTagAction(route, params, success) {
+    const response = await fetch(route, { method: "POST", body: new URLSearchParams(params) });
+    alert(response.ok ? success : "Erreur : " + await response.text());
+    fetchTags();
+}
+
+// Function to merge a tag into another one, its name stays a synonym
+function mergeTags() {
+    const source = document.getElementById("tag-merge-source").value.trim();
+    const target = document.getElementById("tag-merge-target").value.trim();
+    if (!source || !target) {
+        alert("Indiquez le tag à fusionner et le tag cible.");
+        return;
+    }
+    if (!confirm(`Fusionner #${source} dans #${target} ?`)) return;
+    sendTagAction("/tags/merge", { source, target }, "Tags fusionnés !");
+}
+
+// Function to add a synonym to a tag
+function addTagSynonym() {
+    const synonym = document.getElementById("tag-synonym").value.trim();
+    const tag = document.getElementById("tag-synonym-target").value.trim();
+    if (!synonym || !tag) {
+        alert("Indiquez le synonyme et le tag.");
+        return;
+    }
+    sendTagAction("/tags/synonyms/add", { synonym, tag }, "Synonyme ajouté !");
+}
+
+// Function to remove a synonym of a tag
+function removeTagSynonym(synonym) {
+    sendTagAction("/tags/synonyms/remove", { synonym }, "Synonyme supprimé !");
+}
+
+document.addEventListener("DOMContentLoaded", function () {
+    document.getElementById("merge-tags-btn").addEventListener("click", mergeTags);
+    document.getElementById("add-synonym-btn").addEventListener("click", addTagSynonym);
+    fetchTags();
+});
+
 document.addEventListener("DOMContentLoaded", function () {
     const modRequestList = document.getElementById("mod-request-list");
 
@@ -586,4 +963,72 @@ async function removeModeratorRole(userID) {
 }
 
 // Load the list of moderators when the page is ready
-document.addEventListener("DOMContentLoaded", loadModerators);
\ No newline at end of file
+document.addEventListener("DOMContentLoaded", loadModerators);
+document.addEventListener("DOMContentLoaded", function () {
+    const attachmentTypeList = document.getElementById("attachment-type-list");
+    const saveAttachmentTypeBtn = document.getElementById("save-attachment-type-btn");
+
+    // Function to retrieves the allowed attachment types
+    async function fetchAttachmentTypes() {
+        try {
+            const response = await fetch("/attachment-types");
+            if (!response.ok) throw new Error("Erreur lors de la récupération des types de pièces jointes");
+
+            const types = await response.json();
+            attachmentTypeList.innerHTML = "";
+            types.forEach(type => {
+                const typeElement = document.createElement("div");
+                typeElement.className = "category";
+                typeElement.innerHTML = `
+                    <p>${type.mime_type} (${type.extension}) - ${(type.max_size / 1048576).toFixed(1)} Mo max</p>
+                    <button class="delete-attachment-type-btn">🗑️ Supprimer</button>
+                `;
+                typeElement.querySelector(".delete-attachment-type-btn").addEventListener("click", () => deleteAttachmentType(type.mime_type));
+                attachmentTypeList.appendChild(typeElement);
+            });
+        } catch (error) {
+            console.error("Erreur:", error);
+            attachmentTypeList.innerHTML = "<p>Impossible de charger les types de pièces jointes.</p>";
+        }
+    }
+
+    // Function to add or update an allowed attachment type
+    async function saveAttachmentType() {
+        const mimeType = document.getElementById("attachment-mime-type").value.trim();
+        const extension = document.getElementById("attachment-extension").value.trim();
+        const maxSize = Math.round(parseFloat(document.getElementById("attachment-max-size").value) * 1048576);
+        if (!mimeType || !extension || !maxSize) {
+            alert("Veuillez remplir tous les champs.");
+            return;
+        }
+        const response = await fetch("/attachment-types/save", {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: `mime_type=${encodeURIComponent(mimeType)}&extension=${encodeURIComponent(extension)}&max_size=${maxSize}`
+        });
+        if (response.ok) {
+            fetchAttachmentTypes();
+        } else {
+            alert("Erreur: " + await response.text());
+        }
+    }
+
+    // Function to remove a type from the allow-list
+    async function deleteAttachmentType(mimeType) {
+        if (!confirm(`Voulez-vous vraiment interdire le type ${mimeType} ?`)) return;
+
+        const response = await fetch("/attachment-types/delete", {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: `mime_type=${encodeURIComponent(mimeType)}`
+        });
+        if (response.ok) {
+            fetchAttachmentTypes();
+        } else {
+            alert("Erreur lors de la suppression du type de pièce jointe !");
+        }
+    }
+
+    saveAttachmentTypeBtn.addEventListener("click", saveAttachmentType);
+    fetchAttachmentTypes();
+});
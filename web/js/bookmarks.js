@@ -0,0 +1,134 @@
+// Collections of the user, to file the bookmarks and filter the posts
+let bookmarkCollections = [];
+
+// Event listener that loads the collections and the bookmarks panel
+document.addEventListener("DOMContentLoaded", function() {
+    loadBookmarkCollections();
+    document.getElementById("bookmarks-panel").addEventListener("toggle", event => {
+        if (event.target.open) fetchBookmarks();
+    });
+});
+
+// Function to load the collections of the user into the filter
+async function loadBookmarkCollections() {
+    const response = await fetch("/bookmarks/collections");
+    bookmarkCollections = response.ok ? await response.json() : [];
+    const dropdown = document.getElementById("bookmark-collection-dropdown");
+    const selected = dropdown.value;
+    dropdown.innerHTML = `<option value="">Toutes les collections</option>` + bookmarkCollections.map(collection =>
+        `<option value="${collection.id}">${escapeHtml(collection.name)} (${collection.count})</option>`).join("");
+    dropdown.value = bookmarkCollections.some(collection => String(collection.id) === selected) ? selected : "";
+}
+
+// Function to build the bookmark button of a post or a comment
+function bookmarkButtonHtml(targetType, targetID, bookmarked) {
+    return `<button id="bookmark-${targetType}-${targetID}" data-bookmarked="${bookmarked}" onclick="toggleBookmark('${targetType}', '${targetID}')">${bookmarked ? "🔖 Retirer des favoris" : "🔖 Ajouter aux favoris"}</button>`;
+}
+
+// Function to bookmark a post or a comment with an optional note, or to remove its bookmark
+async function toggleBookmark(targetType, targetID) {
+    const button = document.getElementById(`bookmark-${targetType}-${targetID}`);
+    const bookmarked = button.dataset.bookmarked === "true";
+    const body = new URLSearchParams({ target_type: targetType, target_id: targetID });
+    if (!bookmarked) {
+        const note = prompt("Note pour ce favori (facultatif) :", "");
+        if (note === null) {
+            return;
+        }
+        body.set("note", note);
+    }
+    const response = await fetch(bookmarked ? "/bookmarks/remove" : "/bookmarks/save", { method: "POST", body: body });
+    if (!response.ok) {
+        alert("Erreur: " + await response.text());
+        return;
+    }
+    button.dataset.bookmarked = String(!bookmarked);
+    button.textContent = bookmarked ? "🔖 Ajouter aux favoris" : "🔖 Retirer des favoris";
+    loadBookmarkCollections();
+}
+
+// Function to list the bookmarks of the user with their note and collection
+async function fetchBookmarks() {
+    const list = document.getElementById("bookmarks-list");
+    const response = await fetch("/bookmarks");
+    if (!response.ok) {
+        list.textContent = "Impossible de charger les favoris.";
+        return;
+    }
+    const bookmarks = await response.json();
+    const collectionsHtml = bookmarkCollections.map(collection => `
+        <div class="bookmark-collection">
+            <strong>${escapeHtml(collection.name)}</strong> (${collection.count})
+            <button onclick="renameBookmarkCollection(${collection.id})">Renommer</button>
+            <button onclick="deleteBookmarkCollection(${collection.id})">Supprimer</button>
+        </div>`).join("");
+    const bookmarksHtml = bookmarks.length === 0 ? "<p>Aucun favori.</p>" : bookmarks.map(bookmark => `
+        <div class="bookmark">
+            <a href="/forum?post=${bookmark.post_id}${bookmark.target_type === "comment" ? "#comment-" + bookmark.target_id : ""}">
+                ${bookmark.target_type === "comment" ? "Commentaire sur " : ""}${escapeHtml(bookmark.title)}
+            </a>
+            <small>${escapeHtml(bookmark.excerpt)}</small>
+            <input type="text" value="${escapeHtml(bookmark.note)}" placeholder="Note"
+                onchange="updateBookmark('${bookmark.target_type}', '${bookmark.target_id}', this.value, this.nextElementSibling.value)">
+            <select onchange="updateBookmark('${bookmark.target_type}', '${bookmark.target_id}', this.previousElementSibling.value, this.value)">
+                <option value="">Sans collection</option>
+                ${bookmarkCollections.map(collection => `<option value="${collection.id}" ${collection.id === bookmark.collection_id ? "selected" : ""}>${escapeHtml(collection.name)}</option>`).join("")}
+            </select>
+        </div>`).join("");
+    list.innerHTML = `
+        ${collectionsHtml}
+        <button onclick="createBookmarkCollection()">Nouvelle collection</button>
+        ${bookmarksHtml}`;
+}
+
+// Function to change the note or the collection of a bookmark
+async function updateBookmark(targetType, targetID, note, collectionID) {
+    const response = await fetch("/bookmarks/save", {
+        method: "POST",
+        body: new URLSearchParams({ target_type: targetType, target_id: targetID, note: note, collection_id: collectionID })
+    });
+    if (!response.ok) {
+        alert("Erreur: " + await response.text());
+        return;
+    }
+    await loadBookmarkCollections();
+    fetchBookmarks();
+}
+
+// Function to create a collection
+async function createBookmarkCollection() {
+    const name = prompt("Nom de la collection :");
+    if (!name) {
+        return;
+    }
+    await sendCollectionRequest("/bookmarks/collections/create", { name: name });
+}
+
+// Function to rename a collection
+async function renameBookmarkCollection(collectionID) {
+    const collection = bookmarkCollections.find(c => c.id === collectionID);
+    const name = prompt("Nouveau nom de la collection :", collection ? collection.name : "");
+    if (!name) {
+        return;
+    }
+    await sendCollectionRequest("/bookmarks/collections/rename", { id: collectionID, name: name });
+}
+
+// Function to delete a collection, its bookmarks are kept without collection
+async function deleteBookmarkCollection(collectionID) {
+    if (!confirm("Supprimer cette collection ? Ses favoris sont conservés.")) {
+        return;
+    }
+    await sendCollectionRequest("/bookmarks/collections/delete", { id: collectionID });
+}
+
+// Function to send a change of collection then refresh the panel and the filter
+async function sendCollectionRequest(route, params) {
+    const response = await fetch(route, { method: "POST", body: new URLSearchParams(params) });
+    if (!response.ok) {
+        alert("Erreur: " + await response.text());
+        return;
+    }
+    await loadBookmarkCollections();
+    fetchBookmarks();
+}
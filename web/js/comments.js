@@ -10,11 +10,24 @@ function showCommentForm(postID) {
 // Function to post a comment for a specific post
 function postComment(postID) {
     let content = document.getElementById(`comment-text-${postID}`).value;
+    let fileInput = document.getElementById(`comment-files-${postID}`);
+    const formData = new FormData();
+    formData.append("post_id", postID);
+    formData.append("content", content);
+    for (const file of fileInput.files) {
+        formData.append("attachments", file);
+    }
     fetch("/comment/create", {
         method: "POST",
-        headers: { "Content-Type": "application/x-www-form-urlencoded" },
-        body: `post_id=${postID}&content=${encodeURIComponent(content)}`
-    }).then(() => fetchComments(postID));
+        body: formData
+    }).then(response => {
+        if (!response.ok) {
+            response.text().then(message => alert("Erreur: " + message));
+            return;
+        }
+        fileInput.value = "";
+        fetchComments(postID);
+    });
 }
 
 // Function to fetch and display comments
@@ -33,14 +46,33 @@ function fetchComments(postID) {
                     // Create a new comment element
                     let commentElement = document.createElement("div");
                     commentElement.classList.add("comment");
+                    if (comment.accepted) {
+                        commentElement.classList.add("accepted-answer");
+                    }
+                    if (comment.new) {
+                        commentElement.classList.add("new-comment");
+                    }
+                    commentElement.id = `comment-${commentID}`;
                     commentElement.innerHTML = `
-                        <p>${comment.content}</p>
+                        ${comment.accepted ? `<p class="accepted-label">✅ Réponse acceptée</p>` : ""}
+                        <p class="author">${authorHtml(comment.username, comment.avatar_path)}</p>
+                        <p>${mentionsHtml(comment.content)}</p>
+                        ${attachmentsHtml(comment.attachments)}
                         <button onclick="likeComment('${commentID}', 'like')">👍 <span id="like-count-${commentID}">${likeCount}</span></button>
                         <button onclick="likeComment('${commentID}', 'dislike')">👎 <span id="dislike-count-${commentID}">${dislikeCount}</span></button>
                         <button onclick="deleteComment('${commentID}')">🗑️ Supprimer</button>
+                        ${bookmarkButtonHtml("comment", commentID, comment.bookmarked)}
+                        ${answerablePosts.has(postID) ? `<button onclick="acceptAnswer('${postID}', '${commentID}', ${!comment.accepted})">${comment.accepted ? "Retirer la réponse acceptée" : "✅ Accepter la réponse"}</button>` : ""}
                     `;
-                    // Append the new comment to the container
-                    commentContainer.appendChild(commentElement);
+                    // Append the new comment to the container, the accepted answer stays first
+                    if (comment.accepted) {
+                        commentContainer.prepend(commentElement);
+                    } else {
+                        commentContainer.appendChild(commentElement);
+                    }
+                    if (window.location.hash === `#comment-${commentID}`) {
+                        showLinkedElement(commentElement);
+                    }
                 });
             });
         })
@@ -69,6 +101,19 @@ function deleteComment(commentID) {
         body: `id=${commentID}`
     }).then(() => fetchPosts());
 }
+// Function to accept a comment as the answer of a question, or to withdraw it
+async function acceptAnswer(postID, commentID, accepted) {
+    const response = await fetch("/comment/accept", {
+        method: "POST",
+        body: new URLSearchParams({ comment_id: commentID, accepted })
+    });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    applyFilter();
+}
+
 // Function to cancel comment creation
 function cancelCommentCreation(postID) {
     document.getElementById(`comment-form-${postID}`).style.display = "none";
@@ -34,7 +34,9 @@ function fetchComments(postID) {
                     let commentElement = document.createElement("div");
                     commentElement.classList.add("comment");
                     commentElement.innerHTML = `
+                        <p class="author">${authorHtml(comment.username, comment.avatar_path)}</p>
                         <p>${comment.content}</p>
+                        ${attachmentsHtml(comment.attachments)}
                         👍 <span id="like-count-${commentID}">${likeCount}</span>
                         👎 <span id="dislike-count-${commentID}">${dislikeCount}</span>
                     `;
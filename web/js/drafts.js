@@ -0,0 +1,153 @@
+// Draft the post form is saved to, empty until the first autosave
+let currentDraftID = "";
+// Timer of the autosave, restarted at each keystroke
+let draftTimer = null;
+// Delay without typing before the form is saved
+const draftDelay = 2000;
+
+// Event listener that saves the post form as a draft while it is written
+document.addEventListener("DOMContentLoaded", function() {
+    ["post-title", "post-content", "post-tags"].forEach(id => {
+        document.getElementById(id).addEventListener("input", scheduleDraftSave);
+    });
+    document.getElementById("post-category").addEventListener("change", scheduleDraftSave);
+    document.getElementById("drafts-panel").addEventListener("toggle", event => {
+        if (event.target.open) fetchDrafts();
+    });
+    document.getElementById("scheduled-panel").addEventListener("toggle", event => {
+        if (event.target.open) fetchScheduledPosts();
+    });
+});
+
+// Function to save the form once the user stops typing
+function scheduleDraftSave() {
+    clearTimeout(draftTimer);
+    draftTimer = setTimeout(saveDraft, draftDelay);
+}
+
+// Function to save the post form as a draft
+async function saveDraft() {
+    const title = document.getElementById("post-title").value;
+    const content = document.getElementById("post-content").value;
+    if (!currentDraftID && !title.trim() && !content.trim()) {
+        return;
+    }
+    const categories = Array.from(document.getElementById("post-category").selectedOptions).map(option => option.value);
+    const body = new URLSearchParams({
+        id: currentDraftID,
+        title: title,
+        content: content,
+        categories: categories.filter(id => id).join(","),
+        tags: document.getElementById("post-tags").value
+    });
+    const status = document.getElementById("draft-status");
+    try {
+        const response = await fetch("/drafts/save", { method: "POST", body: body });
+        if (!response.ok) {
+            status.textContent = "Brouillon non enregistré : " + await response.text();
+            return;
+        }
+        const draft = await response.json();
+        currentDraftID = draft.id;
+        status.textContent = "Brouillon enregistré à " + new Date(draft.updated_at).toLocaleTimeString();
+    } catch (error) {
+        console.error("Erreur lors de l'enregistrement du brouillon", error);
+        status.textContent = "Brouillon non enregistré";
+    }
+}
+
+// Function to forget the draft once the post is published or the form is cancelled
+function resetDraft() {
+    clearTimeout(draftTimer);
+    currentDraftID = "";
+    document.getElementById("draft-status").textContent = "";
+}
+
+// Function to list the drafts of the user
+async function fetchDrafts() {
+    const list = document.getElementById("drafts-list");
+    const response = await fetch("/drafts");
+    if (!response.ok) {
+        list.textContent = "Impossible de charger les brouillons.";
+        return;
+    }
+    const drafts = await response.json();
+    if (drafts.length === 0) {
+        list.textContent = "Aucun brouillon.";
+        return;
+    }
+    list.innerHTML = drafts.map(draft => `
+        <div class="draft" id="draft-${draft.id}">
+            <strong>${escapeHtml(draft.title || "(sans titre)")}</strong>
+            <small>modifié le ${new Date(draft.updated_at).toLocaleString()}</small>
+            <button onclick="resumeDraft('${draft.id}')">Reprendre</button>
+            <button onclick="deleteDraft('${draft.id}')">Supprimer</button>
+        </div>`).join("");
+    // The drafts are kept to resume them without another request
+    list.drafts = drafts;
+}
+
+// Function to fill the post form with a draft
+function resumeDraft(draftID) {
+    const draft = (document.getElementById("drafts-list").drafts || []).find(d => d.id === draftID);
+    if (!draft) {
+        return;
+    }
+    document.getElementById("post-form").style.display = "block";
+    document.getElementById("post-title").value = draft.title;
+    document.getElementById("post-content").value = draft.content;
+    document.getElementById("post-tags").value = draft.tags;
+    const categories = draft.categories.split(",");
+    Array.from(document.getElementById("post-category").options).forEach(option => {
+        option.selected = option.value !== "" && categories.includes(option.value);
+    });
+    clearTimeout(draftTimer);
+    currentDraftID = draft.id;
+    document.getElementById("draft-status").textContent = "Brouillon du " + new Date(draft.updated_at).toLocaleString();
+}
+
+// Function to delete a draft
+async function deleteDraft(draftID) {
+    if (!confirm("Supprimer ce brouillon ?")) {
+        return;
+    }
+    const response = await fetch("/drafts/delete", { method: "POST", body: new URLSearchParams({ id: draftID }) });
+    if (!response.ok) {
+        alert("Erreur: " + await response.text());
+        return;
+    }
+    if (draftID === currentDraftID) {
+        resetDraft();
+    }
+    fetchDrafts();
+}
+
+// Function to list the posts of the user waiting for their publication or their review
+async function fetchScheduledPosts() {
+    const list = document.getElementById("scheduled-list");
+    const response = await fetch("/posts/scheduled");
+    if (!response.ok) {
+        list.textContent = "Impossible de charger les posts programmés.";
+        return;
+    }
+    const posts = await response.json();
+    if (posts.length === 0) {
+        list.textContent = "Aucun post programmé.";
+        return;
+    }
+    list.innerHTML = posts.map(post => `
+        <div class="draft">
+            <strong>${escapeHtml(post.title)}</strong>
+            <small>${post.held ? "en attente de validation" : "publié le " + new Date(post.scheduled_at).toLocaleString()}</small>
+            <button onclick="cancelScheduledPost('${post.id}')">Annuler</button>
+        </div>`).join("");
+}
+
+// Function to cancel a scheduled post, it goes to the trash like a deleted post
+async function cancelScheduledPost(postID) {
+    if (!confirm("Annuler la publication de ce post ?")) {
+        return;
+    }
+    await fetch("/post/delete", { method: "POST", body: new URLSearchParams({ id: postID }) });
+    fetchScheduledPosts();
+}
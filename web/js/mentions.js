@@ -0,0 +1,80 @@
+// Suggestions shown while typing @username in a post or a comment
+document.addEventListener("DOMContentLoaded", function () {
+    const list = document.createElement("ul");
+    list.id = "mention-suggestions";
+    list.style.display = "none";
+    document.body.appendChild(list);
+
+    let timer = null;
+    document.addEventListener("input", function (event) {
+        const field = event.target;
+        if (field.tagName !== "TEXTAREA") {
+            return;
+        }
+        clearTimeout(timer);
+        const query = mentionQuery(field);
+        if (query === null) {
+            list.style.display = "none";
+            return;
+        }
+        // Wait for the user to stop typing before asking the server
+        timer = setTimeout(() => suggestMentions(field, query, list), 200);
+    });
+    document.addEventListener("click", function (event) {
+        if (!list.contains(event.target)) {
+            list.style.display = "none";
+        }
+    });
+});
+
+// Function to return the name typed after @ before the cursor, null when not in a mention
+function mentionQuery(field) {
+    const before = field.value.substring(0, field.selectionStart);
+    const match = before.match(/(?:^|[^\p{L}\p{N}_.@-])@([\p{L}\p{N}_.-]{1,32})$/u);
+    return match ? match[1] : null;
+}
+
+// Function to display the users matching the typed name under the field
+function suggestMentions(field, query, list) {
+    fetch(`/users/autocomplete?q=${encodeURIComponent(query)}`)
+        .then(response => response.json())
+        .then(users => {
+            list.innerHTML = "";
+            if (!users || users.length === 0) {
+                list.style.display = "none";
+                return;
+            }
+            users.forEach(user => {
+                const item = document.createElement("li");
+                item.textContent = "@" + user.username;
+                item.onclick = () => {
+                    insertMention(field, query, user.username);
+                    list.style.display = "none";
+                };
+                list.appendChild(item);
+            });
+            const rect = field.getBoundingClientRect();
+            list.style.left = `${rect.left + window.scrollX}px`;
+            list.style.top = `${rect.bottom + window.scrollY}px`;
+            list.style.display = "block";
+        })
+        .catch(error => console.error("Erreur lors de la recherche d'utilisateurs :", error));
+}
+
+// Function to replace the typed name by the chosen username
+function insertMention(field, query, username) {
+    const cursor = field.selectionStart;
+    const start = cursor - query.length;
+    field.value = field.value.substring(0, start) + username + " " + field.value.substring(cursor);
+    field.focus();
+    field.selectionStart = field.selectionEnd = start + username.length + 1;
+}
+
+// Function to turn the @username of a text into links to the profiles
+function mentionsHtml(text) {
+    return text.replace(/(^|[^\p{L}\p{N}_.@-])@([\p{L}\p{N}_.-]{1,32})/gu, (match, prefix, username) => {
+        username = username.replace(/\.+$/, "");
+        const rest = match.substring(prefix.length + 1 + username.length);
+        return `${prefix}<a class="mention" href="/user/${encodeURIComponent(username)}">@${username}</a>${rest}`;
+    });
+}
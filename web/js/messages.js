@@ -0,0 +1,308 @@
+// Conversation currently open, its members and the date of its oldest message loaded
+let currentConversation = null;
+let oldestMessage = null;
+// ID of the connected user, found in the member lists
+let currentUserID = null;
+
+// Load the conversations once the page is ready, the one of the URL is opened
+document.addEventListener("DOMContentLoaded", function() {
+    fetch("/check-session")
+    .then(response => response.json())
+    .then(session => { currentUserID = session.userID; })
+    .catch(error => console.error("Erreur lors de la vérification de la session :", error))
+    .then(fetchConversations)
+    .then(() => {
+        const conversationID = new URLSearchParams(window.location.search).get("conversation");
+        if (conversationID) {
+            openConversation(conversationID);
+        }
+    });
+    // Look for new messages regularly
+    setInterval(refreshConversation, 10000);
+});
+
+// Function to escape a text before putting it in HTML
+function escapeHtml(text) {
+    const div = document.createElement("div");
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+// Function to build the name of a conversation from its title or its members
+function conversationName(conversation) {
+    if (conversation.title) {
+        return conversation.title;
+    }
+    const others = conversation.members.filter(member => member.user_id !== currentUserID);
+    return others.map(member => member.username).join(", ") || "Conversation";
+}
+
+// Function to fetch and display the conversations of the user
+async function fetchConversations() {
+    try {
+        const response = await fetch("/conversations");
+        if (!response.ok) {
+            return;
+        }
+        const conversations = await response.json();
+        const list = document.getElementById("conversation-list");
+        list.innerHTML = "";
+        if (conversations.length === 0) {
+            list.innerHTML = "<p>Aucune conversation.</p>";
+        }
+        conversations.forEach(conversation => {
+            const element = document.createElement("div");
+            element.classList.add("conversation");
+            if (currentConversation && currentConversation.id === conversation.id) {
+                element.classList.add("active");
+                currentConversation = conversation;
+            }
+            element.innerHTML = `
+                ${conversation.unread > 0 ? `<span class="unread-count">${conversation.unread}</span>` : ""}
+                <strong>${escapeHtml(conversationName(conversation))}</strong>
+                <p>${escapeHtml(conversation.last_message)}</p>
+                <small>${new Date(conversation.updated_at).toLocaleString()}</small>`;
+            element.onclick = () => openConversation(conversation.id);
+            element.dataset.id = conversation.id;
+            list.appendChild(element);
+        });
+        window.conversations = conversations;
+    } catch (error) {
+        console.error("Erreur lors du chargement des conversations :", error);
+    }
+}
+
+// Function to open a conversation and mark it as read
+async function openConversation(conversationID) {
+    const conversation = (window.conversations || []).find(c => c.id === conversationID);
+    if (!conversation) {
+        return;
+    }
+    currentConversation = conversation;
+    oldestMessage = null;
+    history.replaceState(null, "", `/messages?conversation=${conversationID}`);
+    document.querySelectorAll(".conversation").forEach(element => {
+        element.classList.toggle("active", element.dataset.id === conversationID);
+    });
+    document.getElementById("conversation-view").style.display = "block";
+    document.getElementById("conversation-title").textContent = conversationName(conversation);
+    renderConversationActions(conversation);
+    document.getElementById("message-list").innerHTML = "";
+    await loadMessages();
+    markConversationRead();
+}
+
+// Function to display the actions on the open conversation
+function renderConversationActions(conversation) {
+    const actions = document.getElementById("conversation-actions");
+    actions.innerHTML = "";
+    if (conversation.is_group) {
+        const add = document.createElement("button");
+        add.textContent = "Ajouter des membres";
+        add.onclick = addMembers;
+        actions.appendChild(add);
+    } else {
+        // In a 1:1 conversation the other member can be blocked
+        const other = conversation.members.find(member => member.user_id !== currentUserID);
+        if (other) {
+            const block = document.createElement("button");
+            block.textContent = `Bloquer ${other.username}`;
+            block.onclick = () => blockUser(other.user_id, other.username);
+            actions.appendChild(block);
+        }
+    }
+    const leave = document.createElement("button");
+    leave.textContent = "Quitter";
+    leave.onclick = leaveConversation;
+    actions.appendChild(leave);
+}
+
+// Function to build the HTML of the attachments of a message, their URLs are signed
+function messageAttachmentsHtml(attachments) {
+    if (!attachments || attachments.length === 0) {
+        return "";
+    }
+    return attachments.map(attachment => {
+        const url = attachment.url || `/${attachment.path}`;
+        if (attachment.width > 0) {
+            const preview = (attachment.thumbnails && attachment.thumbnails["400"]) || url;
+            return `<a href="${url}" target="_blank"><img src="${preview}" alt="${escapeHtml(attachment.name)}"></a>`;
+        }
+        return `<a href="${url}" class="attachment-file">📎 ${escapeHtml(attachment.name)} (${Math.ceil(attachment.size / 1024)} Ko)</a>`;
+    }).join("");
+}
+
+// Function to build the element of a message
+function messageElement(message) {
+    const element = document.createElement("div");
+    element.classList.add("message");
+    element.id = `message-${message.id}`;
+    const mine = message.sender_id === currentUserID;
+    if (mine) {
+        element.classList.add("mine");
+    }
+    let receipt = "";
+    if (mine && message.read_by.length > 0) {
+        receipt = currentConversation.is_group ? `Lu par ${message.read_by.map(escapeHtml).join(", ")}` : "Lu";
+    }
+    element.innerHTML = `
+        <strong>${escapeHtml(message.username)}</strong>
+        <p>${escapeHtml(message.content)}</p>
+        ${messageAttachmentsHtml(message.attachments)}
+        <small>${new Date(message.created_at).toLocaleString()}</small>
+        <span class="read-receipt">${receipt}</span>
+        ${mine ? "" : `<button class="report-message" onclick="reportMessage('${message.id}')">Signaler</button>`}`;
+    return element;
+}
+
+// Function to load the last messages of the open conversation, or the older ones
+async function loadMessages(before = null) {
+    let url = `/conversations/messages?conversation_id=${currentConversation.id}`;
+    if (before) {
+        url += `&before=${encodeURIComponent(before)}`;
+    }
+    try {
+        const response = await fetch(url);
+        if (!response.ok) {
+            return;
+        }
+        const messages = await response.json();
+        const list = document.getElementById("message-list");
+        const elements = messages.map(messageElement);
+        if (before) {
+            list.prepend(...elements);
+        } else {
+            list.innerHTML = "";
+            list.append(...elements);
+            list.scrollTop = list.scrollHeight;
+        }
+        if (messages.length > 0) {
+            oldestMessage = messages[0].created_at;
+        }
+        document.getElementById("older-messages").style.display = messages.length >= 50 ? "block" : "none";
+    } catch (error) {
+        console.error("Erreur lors du chargement des messages :", error);
+    }
+}
+
+// Function to load the messages before the oldest one displayed
+function loadOlderMessages() {
+    if (currentConversation && oldestMessage) {
+        loadMessages(oldestMessage);
+    }
+}
+
+// Function to reload the conversations and the open one
+async function refreshConversation() {
+    if (!currentConversation) {
+        fetchConversations();
+        return;
+    }
+    await loadMessages();
+    markConversationRead();
+}
+
+// Function to tell the other members that the conversation was read
+function markConversationRead() {
+    fetch("/conversations/read", {
+        method: "POST",
+        headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        body: `conversation_id=${currentConversation.id}`
+    }).then(() => fetchConversations());
+}
+
+// Function to start a conversation with the usernames of the form
+async function createConversation(event) {
+    event.preventDefault();
+    const body = new URLSearchParams({
+        members: document.getElementById("new-conversation-members").value,
+        title: document.getElementById("new-conversation-title").value
+    });
+    const response = await fetch("/conversations/create", { method: "POST", body });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    const result = await response.json();
+    document.getElementById("new-conversation-form").reset();
+    await fetchConversations();
+    openConversation(result.id);
+}
+
+// Function to send a message with its files to the open conversation
+async function sendMessage(event) {
+    event.preventDefault();
+    const content = document.getElementById("message-content");
+    const files = document.getElementById("message-files");
+    const formData = new FormData();
+    formData.append("conversation_id", currentConversation.id);
+    formData.append("content", content.value);
+    for (const file of files.files) {
+        formData.append("attachments", file);
+    }
+    const response = await fetch("/messages/send", { method: "POST", body: formData });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    content.value = "";
+    files.value = "";
+    const list = document.getElementById("message-list");
+    list.appendChild(messageElement(await response.json()));
+    list.scrollTop = list.scrollHeight;
+    fetchConversations();
+}
+
+// Function to add members to the open group conversation
+async function addMembers() {
+    const members = prompt("Pseudos à ajouter, séparés par des virgules :");
+    if (!members) {
+        return;
+    }
+    const body = new URLSearchParams({ conversation_id: currentConversation.id, members });
+    const response = await fetch("/conversations/members/add", { method: "POST", body });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    await fetchConversations();
+    openConversation(currentConversation.id);
+}
+
+// Function to leave the open conversation
+async function leaveConversation() {
+    if (!confirm("Quitter cette conversation ?")) {
+        return;
+    }
+    const body = new URLSearchParams({ conversation_id: currentConversation.id });
+    const response = await fetch("/conversations/leave", { method: "POST", body });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    currentConversation = null;
+    document.getElementById("conversation-view").style.display = "none";
+    history.replaceState(null, "", "/messages");
+    fetchConversations();
+}
+
+// Function to block a user, they cannot write to the user anymore
+async function blockUser(userID, username) {
+    if (!confirm(`Bloquer ${username} ? Vous ne pourrez plus vous écrire.`)) {
+        return;
+    }
+    const body = new URLSearchParams({ user_id: userID });
+    const response = await fetch("/blocks/add", { method: "POST", body });
+    alert(response.ok ? `${username} est bloqué.` : "Erreur : " + await response.text());
+}
+
+// Function to report a message to the administrators
+async function reportMessage(messageID) {
+    const reason = prompt("Pourquoi signalez-vous ce message ?");
+    if (!reason) {
+        return;
+    }
+    const body = new URLSearchParams({ id: messageID, reason });
+    const response = await fetch("/report/message", { method: "POST", body });
+    alert(response.ok ? "Message signalé, merci." : "Erreur : " + await response.text());
+}
@@ -23,6 +23,8 @@ document.addEventListener("DOMContentLoaded", function () {
                 } else {
                     fetchPosts(); // Load posts if the user is authorized
                     fetchComments();
+                    fetchTrash();
+                    fetchPending();
                 }
             })
             .catch(error => {
@@ -137,10 +139,11 @@ document.addEventListener("DOMContentLoaded", function () {
                 headers: { "Content-Type": "application/x-www-form-urlencoded" },
                 body: `id=${postID}`
             });
-            // Reload posts after deletion
+            // Reload posts after deletion, the post is moved to the trash
             if (response.ok) {
-                alert("Post deleted!");
+                alert("Post moved to the trash!");
                 fetchPosts();  
+                fetchTrash();
             } else {
                 alert("Error deleting the post!");
             }
@@ -172,6 +175,116 @@ document.addEventListener("DOMContentLoaded", function () {
             alert("An error occurred.");
         }
     }
+    // Function to fetch and display the deleted posts that can still be restored
+    async function fetchTrash() {
+        const trashContainer = document.getElementById("trash");
+        try {
+            const response = await fetch("/trash");
+            if (!response.ok) throw new Error("Error fetching the trash");
+
+            const posts = await response.json();
+            trashContainer.innerHTML = posts.length === 0 ? "<p>The trash is empty.</p>" : "";
+            posts.forEach(post => {
+                const postElement = document.createElement("div");
+                postElement.className = "post";
+                postElement.innerHTML = `
+                    <h3></h3>
+                    <p class="content"></p>
+                    <small style="display: block; margin-top: 10px;">Supprimé le ${new Date(post.deleted_at).toLocaleDateString()},
+                    restaurable jusqu'au ${new Date(post.restore_until).toLocaleDateString()}</small>
+                    <div class="post-buttons">
+                        <button class="restore-btn">♻️ Restore</button>
+                    </div>
+                `;
+                postElement.querySelector("h3").textContent = post.title;
+                postElement.querySelector(".content").textContent = post.content;
+                postElement.querySelector(".restore-btn").addEventListener("click", () => restorePost(post.id));
+                trashContainer.appendChild(postElement);
+            });
+        } catch (error) {
+            console.error("Error:", error);
+            trashContainer.innerHTML = "<p>Unable to load the trash.</p>";
+        }
+    }
+
+    // Function to restore a deleted post
+    async function restorePost(postID) {
+        try {
+            const response = await fetch("/post/restore", {
+                method: "POST",
+                headers: { "Content-Type": "application/x-www-form-urlencoded" },
+                body: `post_id=${postID}`
+            });
+            if (response.ok) {
+                alert("Post restored!");
+                fetchPosts();
+                fetchTrash();
+            } else {
+                alert("Error restoring the post: " + await response.text());
+            }
+        } catch (error) {
+            console.error("Error restoring the post:", error);
+            alert("An error occurred.");
+        }
+    }
+
+    // Function to fetch the posts of the new users waiting for a review
+    async function fetchPending() {
+        const pendingContainer = document.getElementById("pending");
+        try {
+            const response = await fetch("/posts/pending");
+            if (!response.ok) throw new Error("Error fetching the pending posts");
+
+            const posts = await response.json();
+            pendingContainer.innerHTML = posts.length === 0 ? "<p>No post is waiting for a review.</p>" : "";
+            posts.forEach(post => {
+                const postElement = document.createElement("div");
+                postElement.className = "post";
+                postElement.innerHTML = `
+                    <h3></h3>
+                    <p class="content"></p>
+                    <small style="display: block; margin-top: 10px;">Par <span class="author"></span>, soumis le ${new Date(post.held_at).toLocaleString()}</small>
+                    <div class="post-buttons">
+                        <button class="approve-btn">✅ Approve</button>
+                        <button class="reject-btn">❌ Reject</button>
+                    </div>
+                `;
+                postElement.querySelector("h3").textContent = post.title;
+                postElement.querySelector(".content").textContent = post.content;
+                postElement.querySelector(".author").textContent = post.username;
+                postElement.querySelector(".approve-btn").addEventListener("click", () => reviewPost(post.id, "approve"));
+                postElement.querySelector(".reject-btn").addEventListener("click", () => reviewPost(post.id, "reject"));
+                pendingContainer.appendChild(postElement);
+            });
+        } catch (error) {
+            console.error("Error:", error);
+            pendingContainer.innerHTML = "<p>Unable to load the pending posts.</p>";
+        }
+    }
+
+    // Function to approve or reject a pending post, a rejection may give a reason to the author
+    async function reviewPost(postID, decision) {
+        const body = new URLSearchParams({ post_id: postID });
+        if (decision === "reject") {
+            const reason = prompt("Raison du refus (optionnelle) :");
+            if (reason === null) return;
+            body.set("reason", reason);
+        }
+        try {
+            const response = await fetch(`/post/${decision}`, { method: "POST", body: body });
+            if (response.ok) {
+                fetchPending();
+                fetchPosts();
+                fetchTrash();
+            } else {
+                alert("Error reviewing the post: " + await response.text());
+            }
+        } catch (error) {
+            console.error("Error reviewing the post:", error);
+            alert("An error occurred.");
+        }
+    }
+
     // Fetch and display posts as soon as the DOM is loaded
     fetchPosts();
 });
\ No newline at end of file
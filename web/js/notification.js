@@ -27,11 +27,6 @@ function toggleNotificationBox() {
     if (notifBox.classList.contains("hidden")) {
         notifBox.classList.remove("hidden");
         notifBox.style.display = "block";
-        markNotificationsAsSeen(); // Mark notifications as seen when the box is shown
-        
-        // Fetch new comments for each post linked in the notifications
-        const postIDs = Array.from(document.querySelectorAll('.notification-item a')).map(link => link.href.split('/').pop());
-        postIDs.forEach(postID => fetchNewComments(postID));
     } else {
         // Otherwise, hide the notification box
         notifBox.classList.add("hidden");
@@ -43,59 +38,70 @@ function toggleNotificationBox() {
 
 // Fetches the list of notifications
 function fetchNotifications() {
+    fetchUnreadCount();
+    fetchUnreadMessagesCount();
     fetch("/notifications")
         .then(response => response.json())
         .then(notifications => {
-            console.log("Notifications reçues :", notifications);
-
-            let notifIcon = document.getElementById("notification-icon");
             let notifDropdown = document.getElementById("notification-dropdown");
-
             notifDropdown.innerHTML = '';  
 
-            if (notifications.length === 0) {
+            if (!notifications || notifications.length === 0) {
                 notifDropdown.innerHTML += "<p>Aucune notification</p>";
                 return;
             }
-            // Display each notification
+            // Bulk actions on the notifications
+            let actions = document.createElement("div");
+            actions.classList.add("notification-actions");
+            actions.innerHTML = `
+                <button onclick="markNotificationsAsSeen()">Tout marquer comme lu</button>
+                <button onclick="markSelectedAsRead()">Marquer la sélection comme lue</button>
+                <button onclick="deleteReadNotifications()">Supprimer les lues</button>
+            `;
+            notifDropdown.appendChild(actions);
+
+            // Display each notification, the text and the link are built by the server
             notifications.forEach(notif => {
                 let notifElement = document.createElement("div");
                 notifElement.classList.add("notification-item");
+                if (!notif.seen) {
+                    notifElement.classList.add("unread");
+                }
                 notifElement.id = `notif-${notif.id}`; 
 
+                let checkbox = document.createElement("input");
+                checkbox.type = "checkbox";
+                checkbox.classList.add("notif-select");
+                checkbox.value = notif.id;
+
+                let link = document.createElement("a");
+                link.href = notif.link;
+                link.textContent = notif.text;
+                // Opening a notification marks it as read
+                link.onclick = () => {
+                    fetch("/notifications/mark-read", { method: "POST", body: new URLSearchParams({ id: notif.id }), keepalive: true });
+                };
+
+                let message = document.createElement("p");
+                message.append(checkbox, link);
+                notifElement.appendChild(message);
+
+                // Excerpt of the comment
+                if ((notif.action === "comment" || notif.action === "reply") && notif.count === 1) {
+                    let shortContent = notif.content.length > 50 ? notif.content.substring(0, 50) + "..." : notif.content;
+                    let excerpt = document.createElement("p");
+                    excerpt.textContent = `"${shortContent}"`;
+                    notifElement.appendChild(excerpt);
+                }
+                let date = document.createElement("small");
+                date.textContent = new Date(notif.created_at).toLocaleString();
+                notifElement.appendChild(date);
+
                 let deleteButton = document.createElement("button");
                 deleteButton.innerText = "Supprimer";
                 deleteButton.classList.add("delete-notif");
                 deleteButton.onclick = () => deleteNotification(notif.id, notifElement); 
 
-                // Check if username contain email adress
-                let username = notif.username;
-                const emailExtensions = [
-                    ".com", ".fr", ".net", ".org", ".edu", ".gov", ".io", ".co", ".biz", ".info", 
-                    ".us", ".uk", ".ca", ".de", ".ru", ".cn", ".jp", ".in", ".br", ".au", ".it", 
-                    ".mx", ".es", ".se", ".pl", ".nl", ".ch", ".be", ".tv", ".me", ".co.uk", ".xyz", 
-                    ".asia", ".top", ".cc", ".mobi", ".name", ".pro", ".tel", ".int", ".aero", ".coop", 
-                    ".cat", ".eu", ".tv", ".sh", ".ws", ".pm", ".ps", ".tk", ".li", ".me", ".so", ".cd", 
-                    ".cg", ".kp", ".hr", ".sk"
-                ];
-                // Check if username contain these character as email
-                if (username.includes("@") && emailExtensions.some(ext => username.endsWith(ext))) {
-                    username = "Quelqu'un"; // Remplace par "Quelqu'un"
-                }
-                // Fetch If the notification is for a comment or a like
-                if (notif.action === "comment") {
-                    let shortContent = notif.content.length > 50 ? notif.content.substring(0, 50) + "..." : notif.content;
-                    notifElement.innerHTML = `
-                        <p><strong>${username}</strong> a commenté votre post</p>
-                        <p>"${shortContent}"</p>
-                        <small>${new Date(notif.created_at).toLocaleString()}</small>
-                    `;
-                } else if (notif.action === "like") {
-                    notifElement.innerHTML = `
-                        <p><strong>${username}</strong> a ${notif.content} votre post/commentaire</p>
-                        <small>${new Date(notif.created_at).toLocaleString()}</small>
-                    `;
-                }
                 // Add the delete button to the notification element
                 notifElement.appendChild(deleteButton);
                 notifDropdown.appendChild(notifElement);
@@ -104,6 +110,51 @@ function fetchNotifications() {
         .catch(error => console.error("Erreur lors de la récupération des notifications :", error));
 }
 
+// Displays the number of unread notifications on the bell
+function fetchUnreadCount() {
+    fetch("/notifications/unread-count")
+        .then(response => response.json())
+        .then(data => {
+            let notifIcon = document.getElementById("notification-icon");
+            notifIcon.innerText = data.unread > 0 ? `🔔 ${data.unread}` : "🔔";
+        })
+        .catch(error => console.error("Erreur lors du comptage des notifications :", error));
+}
+
+// Displays the number of unread private messages on the messages link
+function fetchUnreadMessagesCount() {
+    let messagesLink = document.getElementById("messages-link");
+    if (!messagesLink) {
+        return;
+    }
+    fetch("/messages/unread-count")
+        .then(response => response.json())
+        .then(data => {
+            messagesLink.innerText = data.unread > 0 ? `✉️ Messages (${data.unread})` : "✉️ Messages";
+        })
+        .catch(error => console.error("Erreur lors du comptage des messages :", error));
+}
+
+// Marks the checked notifications as read
+function markSelectedAsRead() {
+    let ids = Array.from(document.querySelectorAll(".notif-select:checked")).map(box => box.value);
+    if (ids.length === 0) {
+        return;
+    }
+    let body = new URLSearchParams();
+    ids.forEach(id => body.append("id", id));
+    fetch("/notifications/mark-read", { method: "POST", body: body })
+        .then(() => fetchNotifications())
+        .catch(error => console.error("Erreur lors de la mise à jour des notifications :", error));
+}
+
+// Deletes every notification already read
+function deleteReadNotifications() {
+    fetch("/notifications/delete-read", { method: "POST" })
+        .then(() => fetchNotifications())
+        .catch(error => console.error("Erreur lors de la suppression des notifications :", error));
+}
+
 // Marks all notifications as seen
 function markNotificationsAsSeen() {
     fetch("/notifications/mark-seen", { method: "POST" })
@@ -1,6 +1,12 @@
+// Role of the connected user, the moderators get the moderation buttons of the posts
+let currentRole = "user";
+// Category of the filter, the moderators pin the posts to it
+let currentCategoryID = "";
+// Questions whose answer the user may accept, as author or moderator
+const answerablePosts = new Set();
+
 // Event listener that triggers when the DOM content is fully loaded
 document.addEventListener("DOMContentLoaded", function() {
-    fetchPosts();
     checkSessionAndFetchPosts();
 });
 
@@ -10,7 +16,14 @@ function checkSessionAndFetchPosts() {
         .then(response => {
             if (response.status === 401) { 
                 window.location.href = "/";
+                return;
             } 
+            return response.json();
+        })
+        .then(session => {
+            if (!session) return;
+            currentRole = session.role || "user";
+            fetchPosts();
         })
         .catch(error => {
             console.error("Erreur lors de la vérification de la session:", error);
@@ -20,16 +33,40 @@ function checkSessionAndFetchPosts() {
 
 // Function to fetch posts based on selected filter
 function fetchPosts(filter = "all", categoryID = "") {
-    let url = "/posts";
+    const params = new URLSearchParams();
+    currentCategoryID = filter === "category" ? categoryID : "";
     if (filter === "category" && categoryID) {
-        url += `?filter=category&category_id=${categoryID}`;
-    } else if (filter === "my_posts") {
-        url += "?filter=my_posts";
-    } else if (filter === "liked") {
-        url += "?filter=liked";
+        params.set("filter", "category");
+        params.set("category_id", categoryID);
+    } else if (filter === "my_posts" || filter === "liked" || filter === "following" || filter === "unread") {
+        params.set("filter", filter);
+    } else if (filter === "unanswered") {
+        // The questions without answer, of the category when one is chosen
+        params.set("filter", filter);
+        if (categoryID) {
+            params.set("category_id", categoryID);
+        }
+    } else if (filter === "bookmarked") {
+        // The bookmarked posts, of the collection when one is chosen
+        params.set("filter", filter);
+        const collectionID = document.getElementById("bookmark-collection-dropdown").value;
+        if (collectionID) {
+            params.set("collection_id", collectionID);
+        }
+    }
+    // The tags of the filter, with any or all of them
+    const tagFilter = document.getElementById("tag-filter");
+    if (tagFilter && tagFilter.value.trim()) {
+        params.set("tags", tagFilter.value.trim());
+        params.set("tag_mode", document.getElementById("tag-mode").value);
     }
+    const url = params.toString() ? `/posts?${params}` : "/posts";
     loadCategories();  // Load categories
-    fetch(url) // Fetch posts
+    loadPopularTags();
+    loadSubscriptions().then(() => {
+        updateTagFollowButton();
+        return fetch(url); // Fetch posts
+    })
     .then(response => response.json())
     .then(posts => {
         let postContainer = document.getElementById("posts");
@@ -38,31 +75,47 @@ function fetchPosts(filter = "all", categoryID = "") {
             fetchLikeDislikeCount(post.ID, "post", function(likeCount, dislikeCount) {
                 let postElement = document.createElement("div");
                 postElement.classList.add("post");
-
-                let imageHtml = "";
-                // If post has an image, display it
-                if (post.ImagePath && post.ImagePath.trim() !== "") {
-                    imageHtml = `<img src="/${post.ImagePath}" alt="Post Image" style="max-width: 300px;">`;
+                postElement.id = `post-${post.ID}`;
+                if (post.CanAccept) {
+                    answerablePosts.add(post.ID);
                 }
+
+                // Display the attached files, the original images open on click
+                let imageHtml = attachmentsHtml(post.Attachments);
                 // Create post HTML structure
                 postElement.innerHTML = `
-                     <h2>${post.Title}</h2>
-                    <p>${post.Content}</p>
+                     <h2>${lifecycleBadgesHtml(post)}${post.Title}${unreadHtml(post)}</h2>
+                     <p class="author">${authorHtml(post.Username, post.AvatarPath)}</p>
+                    <p>${mentionsHtml(post.Content)}</p>
                     ${imageHtml}
+                    ${pollHtml(post.ID, post.Poll)}
+                    ${tagsHtml(post.Tags)}
                     <div class="post-buttons">
                     <button onclick="likePost('${post.ID}', 'like')">👍 <span id="like-count-${post.ID}">${likeCount}</span></button>
                     <button onclick="likePost('${post.ID}', 'dislike')">👎 <span id="dislike-count-${post.ID}">${dislikeCount}</span></button>
-                    <button onclick="showCommentForm('${post.ID}')">Commenter</button>
+                    ${post.Locked || post.Archived ? "" : `<button onclick="showCommentForm('${post.ID}')">Commenter</button>`}
                     <button onclick="deletePost('${post.ID}')">🗑️ Supprimer</button>
+                    <button onclick="editPostTags('${post.ID}', '${(post.Tags || []).map(tag => tag.name).join(", ")}')">🏷️ Tags</button>
+                    ${post.Unread || post.UnreadComments ? `<button onclick="markPostRead('${post.ID}')">Marquer comme lu</button>` : ""}
+                    ${bookmarkButtonHtml("post", post.ID, post.Bookmarked)}
+                    ${followButtonHtml("post", post.ID, "le post")}
+                    ${followButtonHtml("user", post.UserID, "l'auteur")}
+                    ${feedLinkHtml(`/feeds/post/${post.ID}/comments`)}
+                    ${moderationButtonsHtml(post)}
                     </div>
                     <div id="comments-${post.ID}"></div>
                     <div id="comment-form-${post.ID}" style="display:none;">
                     <textarea id="comment-text-${post.ID}" placeholder="Votre commentaire"></textarea>
+                    <input type="file" id="comment-files-${post.ID}" multiple>
                     <button onclick="postComment('${post.ID}')">Publier</button>
                     </div>
                 `;
                 postContainer.appendChild(postElement);
                 fetchComments(post.ID); // Fetch and display comments
+                // Scroll to the post opened from a notification
+                if (new URLSearchParams(window.location.search).get("post") === post.ID && !window.location.hash) {
+                    showLinkedElement(postElement);
+                }
             });
         });
     })
@@ -96,13 +149,23 @@ function loadCategories() {
                 console.error("❌ Erreur : Un des menus de sélection des catégories est introuvable !");
                 return;
             }
+            // Subcategories are indented under their parent, posts can only go to the open categories of the user
             let optionsHTML = `<option value="">Sélectionner une catégorie</option>`;
+            let postOptionsHTML = "";
             categories.forEach(category => {
-                optionsHTML += `<option value="${category.id}">${category.name}</option>`;
+                const label = `${"— ".repeat(category.depth)}${category.icon ? category.icon + " " : ""}${category.name}`;
+                const title = `${category.description} (${category.post_count} post(s))`;
+                optionsHTML += `<option value="${category.id}" title="${title}">${label}</option>`;
+                if (category.can_post) {
+                    postOptionsHTML += `<option value="${category.id}" title="${category.description}">${label}</option>`;
+                }
             });
 
+            let selectedCategory = filterSelect.value;
             filterSelect.innerHTML = optionsHTML;   // Populate category dropdown for filter
-            postFormSelect.innerHTML = optionsHTML; // Populate category dropdown for posts
+            filterSelect.value = selectedCategory;  // Keep the category of the current filter
+            postFormSelect.innerHTML = postOptionsHTML; // Populate category dropdown for posts
+            updateCategoryFollowButton();
         })
         .catch(error => console.error("❌ Erreur lors du chargement des catégories :", error));
 }
@@ -136,15 +199,17 @@ function applyFilter() {
     }
     let categoryContainer = categorySelect.parentElement; 
 
-     // Display category filter if "category" filter is selected, and mask others
-    if (filter === "category") {
+     // Display category filter if "category" filter is selected, and mask others,
+     // the unanswered questions can be narrowed to a category too
+    if (filter === "category" || filter === "unanswered") {
         categoryContainer.style.display = "inline-block"; 
     } else {
         categoryContainer.style.display = "none"; 
     }
+    document.getElementById("bookmark-filter-container").style.display = filter === "bookmarked" ? "inline-block" : "none";
     let categoryID = categorySelect.value;
 
-    if (filter !== "category") {
+    if (filter !== "category" && filter !== "unanswered") {
         categoryID = ""; 
     }
     fetchPosts(filter, categoryID);
@@ -155,9 +220,18 @@ function cancelPostCreation() {
     document.getElementById("post-form").style.display = "none";
     document.getElementById("post-title").value = "";
     document.getElementById("post-content").value = "";
-    document.getElementById("post-category").selectedIndex = 0;
+    document.getElementById("post-category").selectedIndex = -1;
+    document.getElementById("post-tags").value = "";
+    document.getElementById("poll-question").value = "";
+    document.getElementById("poll-options").value = "";
+    document.getElementById("poll-multiple").checked = false;
+    document.getElementById("poll-anonymous").checked = false;
+    document.getElementById("poll-closes-at").value = "";
+    document.getElementById("poll-results").value = "always";
+    document.getElementById("post-publish-at").value = "";
     document.getElementById("post-image").value = "";
     document.getElementById("image-preview").style.display = "none";
+    resetDraft();
 }
 
 // Function to delete a post
@@ -187,9 +261,28 @@ async function createPost() {
     formData.append("title", title);
     formData.append("content", content);
     formData.append("categories", selectedCategories.join(",")); 
+    formData.append("tags", document.getElementById("post-tags").value);
+    // The poll is sent when it has a question
+    const pollQuestion = document.getElementById("poll-question").value.trim();
+    if (pollQuestion) {
+        formData.append("poll_question", pollQuestion);
+        formData.append("poll_options", document.getElementById("poll-options").value);
+        formData.append("poll_multiple", document.getElementById("poll-multiple").checked);
+        formData.append("poll_anonymous", document.getElementById("poll-anonymous").checked);
+        formData.append("poll_results", document.getElementById("poll-results").value);
+        formData.append("poll_closes_at", document.getElementById("poll-closes-at").value);
+    }
+    // The post is published later when a date is given, and replaces its draft
+    const publishAt = document.getElementById("post-publish-at").value;
+    if (publishAt) {
+        formData.append("publish_at", new Date(publishAt).toISOString());
+    }
+    if (currentDraftID) {
+        formData.append("draft_id", currentDraftID);
+    }
 
-    if (imageInput.files.length > 0) {
-        formData.append("image", imageInput.files[0]);
+    for (const file of imageInput.files) {
+        formData.append("attachments", file);
     }
     try {
         const response = await fetch("/post/create", {
@@ -198,6 +291,14 @@ async function createPost() {
         });
         // Reload posts after successful creation
         if (response.ok) {
+            clearTimeout(draftTimer);
+            if (response.status === 202) {
+                // The posts of the new members wait for a moderator
+                alert("Votre post sera publié après validation par un modérateur.");
+            } else if (publishAt) {
+                alert("Post programmé pour le " + new Date(publishAt).toLocaleString());
+            }
+            cancelPostCreation();
             fetchPosts(); 
         } else {
             const errorMessage = await response.text();
@@ -257,4 +358,310 @@ function removeImage() {
     // Clear image input
     fileInput.value = ""; 
     previewContainer.style.display = "none"; 
-}
\ No newline at end of file
+}
+
+// Function to build the author line of a post or comment with a link to the profile
+function authorHtml(username, avatarPath) {
+    let avatar = avatarPath ? `<img src="/${avatarPath}" alt="" class="author-avatar">` : "";
+    return `${avatar}<a href="/user/${encodeURIComponent(username)}">${username}</a>`;
+}
+
+// Function to display the attachments of a post or comment, images as thumbnails and other files as links
+function attachmentsHtml(attachments) {
+    if (!attachments || attachments.length === 0) {
+        return "";
+    }
+    let items = attachments.map(attachment => {
+        if (attachment.width > 0) {
+            let preview = (attachment.thumbnails && attachment.thumbnails["400"]) || attachment.path;
+            return `<a href="/${attachment.path}" target="_blank"><img src="/${preview}" alt="${attachment.name}" style="max-width: 300px;"></a>`;
+        }
+        return `<a href="/${attachment.path}" class="attachment-file">📎 ${attachment.name} (${Math.ceil(attachment.size / 1024)} Ko)</a>`;
+    });
+    return `<div class="attachments">${items.join("")}</div>`;
+}
+
+// Function to scroll to the post or comment targeted by a link and highlight it
+function showLinkedElement(element) {
+    element.classList.add("linked");
+    element.scrollIntoView({ behavior: "smooth", block: "center" });
+}
+
+// Subscriptions of the connected user, keyed by "type:id"
+let followed = new Set();
+
+// Function to load the posts, categories and users followed by the user
+function loadSubscriptions() {
+    return fetch("/subscriptions")
+        .then(response => response.ok ? response.json() : [])
+        .then(subscriptions => {
+            followed = new Set((subscriptions || []).map(sub => `${sub.target_type}:${sub.target_id}`));
+            updateCategoryFollowButton();
+        })
+        .catch(() => { followed = new Set(); });
+}
+
+// Function to build the follow / unfollow button of a target
+function followButtonHtml(targetType, targetID, label) {
+    const isFollowed = followed.has(`${targetType}:${targetID}`);
+    return `<button class="follow-button" data-follow="${targetType}:${targetID}" data-label="${label}" onclick="toggleFollow('${targetType}', '${targetID}')">${isFollowed ? "🔕 Ne plus suivre" : "🔔 Suivre"} ${label}</button>`;
+}
+
+// Function to build the link to the RSS feed of a category, a tag or a post
+function feedLinkHtml(url) {
+    return ` <a class="feed-link" href="${url}" title="Flux RSS, ajouter ?format=atom pour Atom">RSS</a>`;
+}
+
+// Function to follow or unfollow a post, a category or a user
+function toggleFollow(targetType, targetID) {
+    const key = `${targetType}:${targetID}`;
+    const route = followed.has(key) ? "/subscriptions/remove" : "/subscriptions/add";
+    fetch(route, {
+        method: "POST",
+        headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        body: `target_type=${targetType}&target_id=${encodeURIComponent(targetID)}`
+    })
+    .then(response => {
+        if (!response.ok) {
+            return response.text().then(text => { throw new Error(text); });
+        }
+        if (followed.has(key)) {
+            followed.delete(key);
+        } else {
+            followed.add(key);
+        }
+        // Refresh every button of the same target
+        document.querySelectorAll(`[data-follow="${key}"]`).forEach(button => {
+            button.textContent = `${followed.has(key) ? "🔕 Ne plus suivre" : "🔔 Suivre"} ${button.dataset.label}`;
+        });
+    })
+    .catch(error => alert("Erreur : " + error.message));
+}
+
+// Function to show the follow button of the selected category in the filter
+function updateCategoryFollowButton() {
+    const container = document.getElementById("category-follow");
+    const categorySelect = document.getElementById("post-category-dropdown");
+    if (!container || !categorySelect) {
+        return;
+    }
+    container.innerHTML = categorySelect.value ? followButtonHtml("category", categorySelect.value, "la catégorie") + feedLinkHtml(`/feeds/category/${categorySelect.value}`) : "";
+}
+
+// Function to build the HTML of the tags of a post, a click filters the posts by the tag
+function tagsHtml(tags) {
+    if (!tags || tags.length === 0) {
+        return "";
+    }
+    return `<div class="post-tags">${tags.map(tag => `<span class="tag" onclick="filterByTag('${tag.name}')">#${tag.name}</span>`).join(" ")}</div>`;
+}
+
+// Function to add a tag to the tag filter
+function filterByTag(name) {
+    const tagFilter = document.getElementById("tag-filter");
+    const tags = tagFilter.value.split(",").map(tag => tag.trim()).filter(tag => tag);
+    if (!tags.includes(name)) {
+        tags.push(name);
+    }
+    tagFilter.value = tags.join(", ");
+    applyFilter();
+}
+
+// Function to display the tags most used lately, sized by their number of posts
+function loadPopularTags() {
+    const cloud = document.getElementById("tag-cloud");
+    if (!cloud) {
+        return;
+    }
+    fetch("/tags/popular")
+        .then(response => response.json())
+        .then(tags => {
+            const max = Math.max(1, ...tags.map(tag => tag.post_count));
+            cloud.innerHTML = tags.map(tag => {
+                const size = 0.8 + 0.8 * tag.post_count / max;
+                return `<span class="tag" style="font-size:${size}em" title="${tag.post_count} post(s)" onclick="filterByTag('${tag.name}')">#${tag.name}</span>`;
+            }).join(" ");
+        })
+        .catch(error => console.error("Erreur lors du chargement des tags :", error));
+}
+
+// Function to show the follow button of the tag of the filter when there is only one
+function updateTagFollowButton() {
+    const container = document.getElementById("tag-follow");
+    const tagFilter = document.getElementById("tag-filter");
+    if (!container || !tagFilter) {
+        return;
+    }
+    const tags = tagFilter.value.split(",").map(tag => tag.trim().toLowerCase()).filter(tag => tag);
+    container.innerHTML = "";
+    if (tags.length !== 1) {
+        return;
+    }
+    fetch(`/tags?q=${encodeURIComponent(tags[0])}&limit=10`)
+        .then(response => response.json())
+        .then(found => {
+            const tag = found.find(t => t.name === tags[0] || (t.synonyms || []).includes(tags[0]));
+            container.innerHTML = tag ? followButtonHtml("tag", tag.id, `#${tag.name}`) + feedLinkHtml(`/feeds/tag/${encodeURIComponent(tag.name)}`) : "";
+        })
+        .catch(error => console.error("Erreur lors de la recherche du tag :", error));
+}
+
+// Function to change the tags of a post, allowed to its author and to the moderators
+async function editPostTags(postID, currentTags) {
+    const tags = prompt("Tags du post, séparés par des virgules :", currentTags);
+    if (tags === null) {
+        return;
+    }
+    const response = await fetch("/post/tags", {
+        method: "POST",
+        body: new URLSearchParams({ post_id: postID, tags })
+    });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    applyFilter();
+}
+
+// Function to build the badges of a pinned, locked or archived post
+function lifecycleBadgesHtml(post) {
+    let badges = "";
+    if (post.Pinned) badges += `<span class="badge" title="Épinglé">📌</span>`;
+    if (post.Locked) badges += `<span class="badge" title="Verrouillé">🔒</span>`;
+    if (post.Archived) badges += `<span class="badge" title="Archivé">📦</span>`;
+    if (post.QA) badges += post.Solved ? `<span class="badge" title="Résolu">✅</span>` : `<span class="badge" title="Sans réponse acceptée">❓</span>`;
+    return badges;
+}
+
+// Function to build the moderation buttons of a post, shown to the moderators and the administrators
+function moderationButtonsHtml(post) {
+    if (currentRole !== "moderator" && currentRole !== "admin") {
+        return "";
+    }
+    return `
+        <button onclick="pinPost('${post.ID}', ${!post.Pinned})">${post.Pinned ? "Désépingler" : "📌 Épingler"}</button>
+        <button onclick="moderatePost('/post/lock', '${post.ID}', 'locked', ${!post.Locked})">${post.Locked ? "Déverrouiller" : "🔒 Verrouiller"}</button>
+        <button onclick="moderatePost('/post/archive', '${post.ID}', 'archived', ${!post.Archived})">${post.Archived ? "Désarchiver" : "📦 Archiver"}</button>`;
+}
+
+// Function to pin a post to the category of the filter, or to the whole forum without filter.
+// A post is unpinned from both, since both pin it in the category.
+async function pinPost(postID, pinned) {
+    const scopes = pinned ? [currentCategoryID] : ["", currentCategoryID].filter((scope, i, all) => all.indexOf(scope) === i);
+    for (const scope of scopes) {
+        const response = await fetch("/post/pin", {
+            method: "POST",
+            body: new URLSearchParams({ post_id: postID, category_id: scope, pinned })
+        });
+        if (!response.ok) {
+            alert("Erreur : " + await response.text());
+            return;
+        }
+    }
+    applyFilter();
+}
+
+// Function to lock or archive a post, or to undo it
+async function moderatePost(route, postID, field, value) {
+    const response = await fetch(route, {
+        method: "POST",
+        body: new URLSearchParams({ post_id: postID, [field]: value })
+    });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    applyFilter();
+}
+
+// Function to escape a text before putting it in HTML
+function escapeHtml(text) {
+    const div = document.createElement("div");
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+// Function to build the HTML of the poll of a post: the choices while the user can vote,
+// then the results when they are visible to them
+function pollHtml(postID, poll) {
+    if (!poll) {
+        return "";
+    }
+    const canVote = !poll.voted && !poll.closed;
+    const inputType = poll.multiple ? "checkbox" : "radio";
+    const options = poll.options.map(option => {
+        const chosen = (poll.my_choices || []).includes(option.id);
+        let result = "";
+        if (poll.results_visible) {
+            const percent = poll.voters > 0 ? Math.round(100 * option.votes / poll.voters) : 0;
+            const voters = option.voters ? ` title="${option.voters.map(escapeHtml).join(", ")}"` : "";
+            result = `<div class="poll-bar"${voters}><span style="width:${percent}%"></span></div> ${option.votes} (${percent}%)`;
+        }
+        const input = canVote ? `<input type="${inputType}" name="poll-${postID}" value="${option.id}"> ` : (chosen ? "✔ " : "");
+        return `<label class="poll-option">${input}${escapeHtml(option.label)} ${result}</label>`;
+    }).join("");
+    let status = `${poll.voters} votant(s)`;
+    if (poll.anonymous) status += " · anonyme";
+    if (poll.closes_at) status += poll.closed ? " · clôturé" : ` · clôture le ${new Date(poll.closes_at).toLocaleString()}`;
+    if (!poll.results_visible) status += poll.results_visibility === "after_close" ? " · résultats après la clôture" : " · résultats après le vote";
+    return `
+        <div class="poll" id="poll-${postID}">
+            <strong>📊 ${escapeHtml(poll.question)}</strong>
+            ${options}
+            ${canVote ? `<button onclick="votePoll('${postID}')">Voter</button>` : ""}
+            <small>${status}</small>
+        </div>`;
+}
+
+// Function to send the choices of the user in the poll of a post
+async function votePoll(postID) {
+    const chosen = Array.from(document.querySelectorAll(`input[name="poll-${postID}"]:checked`));
+    if (chosen.length === 0) {
+        alert("Choisissez au moins une option.");
+        return;
+    }
+    const body = new URLSearchParams({ post_id: postID });
+    chosen.forEach(input => body.append("option_id", input.value));
+    const response = await fetch("/poll/vote", { method: "POST", body });
+    if (!response.ok) {
+        alert("Erreur : " + await response.text());
+        return;
+    }
+    // The poll is replaced by the one returned, with the results now visible
+    document.getElementById(`poll-${postID}`).outerHTML = pollHtml(postID, await response.json());
+}
+
+// Function to show what the user has not read of a post
+function unreadHtml(post) {
+    if (post.Unread) {
+        return ` <span class="unread">Nouveau</span>`;
+    }
+    if (post.UnreadComments) {
+        return ` <span class="unread">${post.UnreadComments} nouveau${post.UnreadComments > 1 ? "x" : ""} commentaire${post.UnreadComments > 1 ? "s" : ""}</span>`;
+    }
+    return "";
+}
+
+// Function to mark a post and its comments read
+function markPostRead(postID) {
+    fetch("/posts/read", { method: "POST", body: new URLSearchParams({ post_id: postID }) })
+        .then(response => {
+            if (!response.ok) {
+                return response.text().then(text => { throw new Error(text); });
+            }
+            applyFilter();
+        })
+        .catch(error => alert("Erreur : " + error.message));
+}
+
+// Function to mark every post read
+function markAllRead() {
+    fetch("/posts/read-all", { method: "POST" })
+        .then(response => {
+            if (!response.ok) {
+                return response.text().then(text => { throw new Error(text); });
+            }
+            applyFilter();
+        })
+        .catch(error => alert("Erreur : " + error.message));
+}
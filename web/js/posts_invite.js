@@ -25,14 +25,12 @@ function fetchPosts(filter = "all", categoryID = "") {
                     // Create a new div element for the post
                     let postElement = document.createElement("div");
                     postElement.classList.add("post");
-                    let imageHtml = "";
-                    // If the post has an image, display it
-                    if (post.ImagePath && post.ImagePath.trim() !== "") {
-                        imageHtml = `<img src="/${post.ImagePath}" alt="Post Image"  class="post-image">`;
-                    }
+                    // Display the attached files of the post
+                    let imageHtml = attachmentsHtml(post.Attachments);
                      // Set the HTML content of the post element
                     postElement.innerHTML = `
                         <h2>${post.Title}</h2>
+                        <p class="author">${authorHtml(post.Username, post.AvatarPath)}</p>
                         <p>${post.Content}</p>
                         ${imageHtml}
                         <div class="like-dislike-buttons"> 
@@ -87,4 +85,25 @@ function showPostForm() {
     } else {
         form.style.display = "none";
     }
-}
\ No newline at end of file
+}
+
+// Function to build the author line of a post or comment with a link to the profile
+function authorHtml(username, avatarPath) {
+    let avatar = avatarPath ? `<img src="/${avatarPath}" alt="" class="author-avatar">` : "";
+    return `${avatar}<a href="/user/${encodeURIComponent(username)}">${username}</a>`;
+}
+
+// Function to display the attachments of a post or comment, images as thumbnails and other files as links
+function attachmentsHtml(attachments) {
+    if (!attachments || attachments.length === 0) {
+        return "";
+    }
+    let items = attachments.map(attachment => {
+        if (attachment.width > 0) {
+            let preview = (attachment.thumbnails && attachment.thumbnails["400"]) || attachment.path;
+            return `<a href="/${attachment.path}" target="_blank"><img src="/${preview}" alt="${attachment.name}" class="post-image"></a>`;
+        }
+        return `<a href="/${attachment.path}" class="attachment-file">📎 ${attachment.name} (${Math.ceil(attachment.size / 1024)} Ko)</a>`;
+    });
+    return `<div class="attachments">${items.join("")}</div>`;
+}
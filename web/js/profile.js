@@ -0,0 +1,263 @@
+// Load the profile once the page is ready
+document.addEventListener("DOMContentLoaded", function() {
+    fetchProfile();
+    fetchLeaderboard();
+});
+
+// Labels of the trust levels
+const trustLevelLabels = ["Nouveau", "Membre", "Membre confirmé", "Habitué"];
+
+// Function to get the username from the URL /user/{username}
+function profileUsername() {
+    return decodeURIComponent(window.location.pathname.split("/")[2] || "");
+}
+
+// Function to fetch and display the profile
+async function fetchProfile() {
+    try {
+        let response = await fetch(`/user/${encodeURIComponent(profileUsername())}/profile`);
+        if (!response.ok) {
+            document.getElementById("profile-username").innerText = "Utilisateur introuvable";
+            return;
+        }
+        let profile = await response.json();
+
+        document.getElementById("profile-username").innerText = profile.username;
+        document.getElementById("profile-bio").innerText = profile.bio || "";
+        document.getElementById("profile-feed").href = `/feeds/user/${encodeURIComponent(profile.username)}`;
+        document.getElementById("profile-stats").innerText =
+            `Membre depuis le ${new Date(profile.joined_at).toLocaleDateString()} · ${profile.post_count} posts · ${profile.comment_count} commentaires · Réputation : ${profile.reputation} · Niveau ${profile.trust_level} (${trustLevelLabels[profile.trust_level] || profile.trust_level_name})`;
+        // The badges show their description on hover
+        let badges = document.getElementById("profile-badges");
+        badges.innerHTML = "";
+        (profile.badges || []).forEach(badge => {
+            let span = document.createElement("span");
+            span.className = "badge";
+            span.textContent = `${badge.icon} ${badge.name}`;
+            span.title = `${badge.description} (obtenu le ${new Date(badge.awarded_at).toLocaleDateString()})`;
+            badges.appendChild(span);
+        });
+
+        let avatar = document.getElementById("profile-avatar");
+        if (profile.avatar_path) {
+            avatar.src = `/${profile.avatar_path}`;
+            avatar.style.display = "block";
+        }
+        // Display the edit form on our own profile
+        if (profile.is_self) {
+            document.getElementById("profile-form").style.display = "flex";
+            document.getElementById("profile-bio-input").value = profile.bio || "";
+            fetchNotificationPreferences();
+            fetchBlockedUsers();
+        } else {
+            document.getElementById("profile-actions").style.display = "block";
+            fetchBlockStatus();
+        }
+
+        let postContainer = document.getElementById("profile-posts");
+        postContainer.innerHTML = "";
+        if (profile.recent_posts && profile.recent_posts.length > 0) {
+            profile.recent_posts.forEach(post => {
+                let div = document.createElement("div");
+                div.classList.add("post");
+                div.innerHTML = `<h3>${post.title}</h3><p>${post.content}</p><small>${new Date(post.created_at).toLocaleString()}</small>`;
+                postContainer.appendChild(div);
+            });
+        } else {
+            postContainer.innerHTML = "<p>Aucun post trouvé.</p>";
+        }
+
+        let commentContainer = document.getElementById("profile-comments");
+        commentContainer.innerHTML = "";
+        if (profile.recent_comments && profile.recent_comments.length > 0) {
+            profile.recent_comments.forEach(comment => {
+                let div = document.createElement("div");
+                div.classList.add("comment");
+                div.innerHTML = `<p>Commenté sur : <strong>${comment.title}</strong></p><p>"${comment.comment}"</p><small>${new Date(comment.created_at).toLocaleString()}</small>`;
+                commentContainer.appendChild(div);
+            });
+        } else {
+            commentContainer.innerHTML = "<p>Aucun commentaire trouvé.</p>";
+        }
+    } catch (error) {
+        console.error("Erreur lors du chargement du profil :", error);
+    }
+}
+
+// Function to update the bio and avatar
+async function updateProfile(event) {
+    event.preventDefault();
+    const formData = new FormData();
+    formData.append("bio", document.getElementById("profile-bio-input").value);
+    const avatarInput = document.getElementById("profile-avatar-input");
+    if (avatarInput.files.length > 0) {
+        formData.append("avatar", avatarInput.files[0]);
+    }
+    try {
+        const response = await fetch("/profile/update", { method: "POST", body: formData });
+        if (!response.ok) {
+            alert(await response.text());
+        }
+        fetchProfile();
+    } catch (error) {
+        console.error("Erreur lors de la mise à jour du profil :", error);
+    }
+}
+
+// Labels of the notification types
+const notificationTypeLabels = {
+    comment: "Commentaires sur mes posts",
+    reply: "Réponses dans les discussions",
+    reaction: "Likes et dislikes",
+    mention: "Mentions",
+    subscription: "Nouveaux posts suivis",
+    message: "Messages privés",
+    moderation: "Décisions de modération",
+    achievement: "Niveaux de confiance et badges"
+};
+
+// Function to display the notification preferences of the user
+async function fetchNotificationPreferences() {
+    try {
+        const response = await fetch("/notifications/preferences");
+        if (!response.ok) {
+            return;
+        }
+        const settings = await response.json();
+        const body = document.getElementById("notification-preferences-body");
+        body.innerHTML = "";
+        settings.preferences.forEach(pref => {
+            const row = document.createElement("tr");
+            row.innerHTML = `<td>${notificationTypeLabels[pref.type] || pref.type}</td>
+                <td><input type="checkbox" ${pref.in_app ? "checked" : ""} onchange="updateNotificationPreference('${pref.type}', 'in_app', this.checked)"></td>
+                <td><input type="checkbox" ${pref.email ? "checked" : ""} onchange="updateNotificationPreference('${pref.type}', 'email', this.checked)"></td>`;
+            body.appendChild(row);
+        });
+        document.getElementById("digest-frequency").value = settings.digest;
+        document.getElementById("allow-mentions").checked = settings.allow_mentions;
+        document.getElementById("notification-preferences").style.display = "block";
+    } catch (error) {
+        console.error("Erreur lors du chargement des préférences :", error);
+    }
+}
+
+// Function to save a channel of a notification type
+async function updateNotificationPreference(type, channel, enabled) {
+    await saveNotificationSettings({ type: type, channel: channel, enabled: enabled });
+}
+
+// Function to save the digest frequency
+async function updateDigest(digest) {
+    await saveNotificationSettings({ digest: digest });
+}
+
+// Function to send the changed preferences
+async function saveNotificationSettings(values) {
+    try {
+        const response = await fetch("/notifications/preferences/update", {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: new URLSearchParams(values)
+        });
+        if (!response.ok) {
+            alert(await response.text());
+            fetchNotificationPreferences();
+        }
+    } catch (error) {
+        console.error("Erreur lors de l'enregistrement des préférences :", error);
+    }
+}
+
+// Kind of block of the user of the profile by the connected user: "", "mute" or "block"
+let profileBlockKind = "";
+
+// Function to find whether the user of the profile is blocked or muted
+async function fetchBlockStatus() {
+    try {
+        const response = await fetch("/blocks");
+        if (!response.ok) {
+            return;
+        }
+        const blocked = await response.json();
+        const entry = blocked.find(user => user.username === profileUsername());
+        profileBlockKind = entry ? entry.kind : "";
+        document.getElementById("mute-button").innerText = profileBlockKind === "mute" ? "Ne plus masquer" : "Masquer";
+        document.getElementById("block-button").innerText = profileBlockKind === "block" ? "Débloquer" : "Bloquer";
+    } catch (error) {
+        console.error("Erreur lors du chargement des blocages :", error);
+    }
+}
+
+// Function to block or mute the user of the profile, or to undo it
+async function toggleBlock(kind) {
+    const body = new URLSearchParams({ username: profileUsername(), kind });
+    const route = profileBlockKind === kind ? "/blocks/remove" : "/blocks/add";
+    const response = await fetch(route, { method: "POST", body });
+    if (!response.ok) {
+        alert(await response.text());
+    }
+    fetchBlockStatus();
+}
+
+// Function to list the users blocked or muted by the connected user on their profile
+async function fetchBlockedUsers() {
+    try {
+        const response = await fetch("/blocks");
+        if (!response.ok) {
+            return;
+        }
+        const blocked = await response.json();
+        const list = document.getElementById("blocked-users-list");
+        list.innerHTML = "";
+        document.getElementById("blocked-users").style.display = blocked.length > 0 ? "block" : "none";
+        blocked.forEach(user => {
+            const item = document.createElement("li");
+            item.textContent = `${user.username} (${user.kind === "mute" ? "masqué" : "bloqué"}) `;
+            const button = document.createElement("button");
+            button.textContent = user.kind === "mute" ? "Ne plus masquer" : "Débloquer";
+            button.onclick = async () => {
+                await fetch("/blocks/remove", { method: "POST", body: new URLSearchParams({ user_id: user.user_id }) });
+                fetchBlockedUsers();
+            };
+            item.appendChild(button);
+            list.appendChild(item);
+        });
+    } catch (error) {
+        console.error("Erreur lors du chargement des blocages :", error);
+    }
+}
+
+// Function to open the conversation with the user of the profile
+async function messageUser() {
+    const response = await fetch("/conversations/create", { method: "POST", body: new URLSearchParams({ members: profileUsername() }) });
+    if (!response.ok) {
+        alert(await response.text());
+        return;
+    }
+    const conversation = await response.json();
+    window.location.href = `/messages?conversation=${conversation.id}`;
+}
+
+// Function to display the members with the highest reputation
+async function fetchLeaderboard() {
+    try {
+        const response = await fetch("/leaderboard?limit=10");
+        if (!response.ok) {
+            return;
+        }
+        const entries = await response.json();
+        const list = document.getElementById("leaderboard-list");
+        list.innerHTML = "";
+        entries.forEach(entry => {
+            const item = document.createElement("li");
+            const link = document.createElement("a");
+            link.href = `/user/${encodeURIComponent(entry.username)}`;
+            link.textContent = entry.username;
+            item.appendChild(link);
+            item.append(` · ${entry.reputation} points · ${trustLevelLabels[entry.trust_level]}`);
+            list.appendChild(item);
+        });
+    } catch (error) {
+        console.error("Erreur lors du chargement du classement :", error);
+    }
+}
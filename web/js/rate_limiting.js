@@ -4,7 +4,7 @@ document.addEventListener("DOMContentLoaded", function () {
     const errorMessage = document.getElementById("error-message");
 
     // Check if there is an "error" parameter in the URL
-    if (params.has("error")) {
+    if (params.has("error") && errorMessage) {
         errorMessage.style.display = "block";
           
         // Switch case to display specific error messages based on the error type
@@ -15,6 +15,35 @@ document.addEventListener("DOMContentLoaded", function () {
             case "invalid": // Invalid credentials error
                 errorMessage.textContent = "Identifiants incorrects. Veuillez réessayer.";
                 break;
+            case "captcha": // A CAPTCHA is required after repeated failures
+                errorMessage.textContent = "Veuillez résoudre le CAPTCHA avant de vous connecter.";
+                showCaptcha();
+                break;
         }
     }
-});
\ No newline at end of file
+});
+
+// Adds the CAPTCHA widget to the login form
+function showCaptcha() {
+    const form = document.querySelector("form[action='/login']");
+    if (!form) {
+        return;
+    }
+    fetch("/login/captcha")
+        .then(response => response.json())
+        .then(config => {
+            if (!config.enabled) {
+                return;
+            }
+            const widget = document.createElement("div");
+            widget.className = config.widget.widget_class;
+            widget.dataset.sitekey = config.widget.site_key;
+            form.insertBefore(widget, form.querySelector("button[type='submit']"));
+
+            const script = document.createElement("script");
+            script.src = config.widget.script_url;
+            script.async = true;
+            document.head.appendChild(script);
+        })
+        .catch(error => console.error("Erreur lors du chargement du CAPTCHA :", error));
+}